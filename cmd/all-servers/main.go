@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
@@ -13,10 +15,16 @@ import (
 	"time"
 
 	"mangahub/internal/auth"
+	"mangahub/internal/broker"
 	"mangahub/internal/database"
+	"mangahub/internal/events"
 	"mangahub/internal/grpc"
 	"mangahub/internal/manga"
+	"mangahub/internal/notify"
+	"mangahub/internal/releases"
+	"mangahub/internal/sse"
 	"mangahub/internal/tcp"
+	"mangahub/internal/tlsutil"
 	"mangahub/internal/udp"
 	"mangahub/internal/user"
 
@@ -47,16 +55,43 @@ type ChatHub struct {
 	Unregister chan *websocket.Conn
 	mu         sync.RWMutex
 	history    []ChatPayload
+
+	// Messages broadcast here are published to the other nodes; theirs
+	// arrive on remote and are only delivered locally.
+	broker broker.Broker
+	remote chan ChatPayload
 }
 
-func newHub() *ChatHub {
-	return &ChatHub{
+// chatTopic is the broker topic chat messages travel on.
+const chatTopic = "chat"
+
+// chatWriteTimeout bounds a write to one WebSocket client, so a stalled
+// client cannot hold up the hub.
+const chatWriteTimeout = 5 * time.Second
+
+func newHub(b broker.Broker) *ChatHub {
+	h := &ChatHub{
 		Clients:    make(map[*websocket.Conn]string),
 		Broadcast:  make(chan ChatPayload, 128),
 		Register:   make(chan Client),
 		Unregister: make(chan *websocket.Conn),
 		history:    make([]ChatPayload, 0, 50),
+		broker:     b,
+		remote:     make(chan ChatPayload, 128),
 	}
+	b.Subscribe(chatTopic, func(m broker.Message) {
+		var msg ChatPayload
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			return
+		}
+		// The broker's delivery goroutine must not wait on a busy hub.
+		select {
+		case h.remote <- msg:
+		default:
+			log.Printf("WebSocket: hub busy, dropped %s from node %s", msg.Type, m.Node)
+		}
+	})
+	return h
 }
 
 func (h *ChatHub) run() {
@@ -85,23 +120,51 @@ func (h *ChatHub) run() {
 				Room:      "general",
 			}
 		case msg := <-h.Broadcast:
-			if msg.Type == "chat" {
-				h.mu.Lock()
-				if len(h.history) >= 50 {
-					h.history = h.history[1:]
-				}
-				h.history = append(h.history, msg)
-				h.mu.Unlock()
-			}
-			h.mu.RLock()
-			for conn := range h.Clients {
-				_ = conn.WriteJSON(msg)
+			h.deliver(msg)
+			data, _ := json.Marshal(msg)
+			if err := h.broker.Publish(chatTopic, data); err != nil {
+				log.Printf("WebSocket: failed to publish %s to other nodes: %v", msg.Type, err)
 			}
-			h.mu.RUnlock()
+		case msg := <-h.remote:
+			h.deliver(msg)
 		}
 	}
 }
 
+// deliver sends msg to this node's clients and keeps chat in the history.
+func (h *ChatHub) deliver(msg ChatPayload) {
+	if msg.Type == "chat" {
+		h.mu.Lock()
+		if len(h.history) >= 50 {
+			h.history = h.history[1:]
+		}
+		h.history = append(h.history, msg)
+		h.mu.Unlock()
+	}
+	h.mu.RLock()
+	for conn := range h.Clients {
+		_ = conn.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+		_ = conn.WriteJSON(msg)
+	}
+	h.mu.RUnlock()
+}
+
+// serverTLS loads the TLS settings for a server from the environment and
+// reloads its certificate on SIGHUP. It returns nil when TLS is not
+// configured.
+func serverTLS(ctx context.Context, name string) *tls.Config {
+	cfg := tlsutil.ServerFromEnv(name)
+	if cfg == nil {
+		return nil
+	}
+	reloader, err := tlsutil.NewReloader(*cfg)
+	if err != nil {
+		log.Fatalf("%s TLS: %v", name, err)
+	}
+	go reloader.WatchSIGHUP(ctx, name)
+	return reloader.TLSConfig()
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
@@ -134,8 +197,98 @@ func main() {
 	authSvc := auth.NewService(db)
 	mangaSvc := manga.NewService(db)
 	userSvc := user.NewService(db)
+	userSvc.JWTSecret = jwtSecret
 	userSvc.SetMangaService(mangaSvc)
 
+	// Services publish progress, library, subscription and release events;
+	// the TCP, UDP, SSE and WebSocket servers consume them in-process.
+	bus := events.NewBus()
+	userSvc.Bus = bus
+
+	// Browser event stream
+	sseHub := sse.NewHub()
+	sseHub.Consume(bus)
+
+	tcpSrv := tcp.FromEnv()
+	tcpSrv.JWTSecret = jwtSecret
+	tcpSrv.Bus = bus
+	tcpSrv.Replay = tcp.NewDBLog(db, tcpSrv.ReplaySize)
+	// Progress goes from the outbox straight to the TCP server, so an update
+	// is only removed from the outbox once it has been recorded.
+	userSvc.DeliverProgress = tcpSrv.DeliverProgress
+	tcpSrv.Snapshots = func(userID string) ([]tcp.LibraryEntry, []string, error) {
+		items, err := userSvc.GetLibrary(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		library := make([]tcp.LibraryEntry, 0, len(items))
+		for _, p := range items {
+			library = append(library, tcp.LibraryEntry{
+				MangaID:   p.MangaID,
+				Chapter:   p.CurrentChapter,
+				Status:    p.Status,
+				Version:   p.Version,
+				UpdatedAt: p.UpdatedAt.Unix(),
+			})
+		}
+		subscriptions, err := userSvc.ListSubscriptions(userID)
+		return library, subscriptions, err
+	}
+	tcpSrv.Progress = func(upd tcp.ProgressUpdate) (*tcp.ProgressResult, error) {
+		res, err := userSvc.UpdateProgress(upd.UserID, user.UpdateProgressRequest{
+			MangaID:         upd.MangaID,
+			CurrentChapter:  upd.Chapter,
+			Status:          upd.Status,
+			ClientTimestamp: upd.ClientTimestamp,
+			BaseVersion:     upd.BaseVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &tcp.ProgressResult{
+			Applied:         res.Applied,
+			Rejected:        res.Rejected,
+			Chapter:         res.Progress.CurrentChapter,
+			Status:          res.Progress.Status,
+			Version:         res.Progress.Version,
+			ClientTimestamp: res.Progress.ClientTimestamp,
+		}, nil
+	}
+
+	udpSrv := udp.FromEnv()
+	udpSrv.JWTSecret = jwtSecret
+	udpSrv.DB = db
+	udpSrv.Bus = bus
+	udpSrv.GenreLookup = func(mangaID string) []string {
+		if m, err := mangaSvc.GetMangaByID(mangaID); err == nil {
+			return m.Genres
+		}
+		return nil
+	}
+
+	// Background jobs stop when ctx is cancelled during shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Per-user delivery over UDP, email and webhooks
+	dispatcher := notify.NewDispatcher(db,
+		notify.NewUDPChannel(udpSrv),
+		notify.NewEmailChannel(notify.MailerFromEnv()),
+		notify.NewWebhookChannel(),
+	)
+	udpSrv.Dispatcher = dispatcher
+
+	// Fan-out of TCP and chat broadcasts to the other instances, through the
+	// relay at MANGAHUB_RELAY_ADDR if set
+	nodes := broker.FromEnv(ctx)
+	defer nodes.Close()
+	tcpSrv.Broker = nodes
+	log.Printf("Node ID: %s", nodes.NodeID())
+
+	// Optional TLS for the TCP sync and gRPC servers, reloaded on SIGHUP
+	tcpSrv.TLSConfig = serverTLS(ctx, "TCP")
+	grpcTLS := serverTLS(ctx, "GRPC")
+
 	var wg sync.WaitGroup
 
 	// Store server references for graceful shutdown
@@ -148,7 +301,9 @@ func main() {
 	go func() {
 		defer wg.Done()
 		gin.SetMode(gin.ReleaseMode)
-		r := gin.Default()
+		r := gin.New()
+		// SSE clients may pass their JWT as ?token=, which must not be logged.
+		r.Use(gin.LoggerWithFormatter(sse.LogFormatter), gin.Recovery())
 
 		// CORS configuration - allow requests from frontend
 		corsOrigins := os.Getenv("MANGAHUB_CORS_ORIGINS")
@@ -244,11 +399,21 @@ func main() {
 		})
 
 		authMiddleware := auth.RegisterRoutes(r, authSvc, jwtSecret)
+		sse.RegisterRoutes(r, sseHub, jwtSecret)
 		authGroup := r.Group("/")
 		authGroup.Use(authMiddleware)
 		{
 			manga.RegisterRoutes(authGroup, mangaSvc)
 			user.RegisterRoutes(authGroup, userSvc)
+			notify.RegisterRoutes(authGroup, dispatcher)
+		}
+
+		// Admin-only diagnostics
+		adminGroup := r.Group("/admin")
+		adminGroup.Use(authMiddleware, auth.RequireRole(auth.RoleAdmin))
+		{
+			udp.RegisterAdminRoutes(adminGroup, udpSrv)
+			user.RegisterAdminRoutes(adminGroup, userSvc)
 		}
 
 		// Bind to all interfaces (0.0.0.0) to allow network access
@@ -260,6 +425,9 @@ func main() {
 			Addr:    bindAddr,
 			Handler: r,
 		}
+		// Shutdown waits for requests without cancelling them; end the
+		// SSE streams so it does not wait out the whole timeout.
+		httpServer.RegisterOnShutdown(sseHub.Close)
 		log.Printf("✅ HTTP API server listening on %s", bindAddr)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Printf("HTTP API server error: %v", err)
@@ -270,9 +438,13 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		grpcServer = grpc.NewServer(mangaSvc, userSvc)
-		log.Println("✅ gRPC server listening on :9092")
-		if err := grpcServer.Start(":9092"); err != nil {
+		grpcServer = grpc.NewServer(mangaSvc, userSvc, grpcTLS)
+		grpcAddr := os.Getenv("MANGAHUB_GRPC_ADDR")
+		if grpcAddr == "" {
+			grpcAddr = ":9092"
+		}
+		log.Println("✅ gRPC server listening on " + grpcAddr)
+		if err := grpcServer.Start(grpcAddr); err != nil {
 			log.Printf("gRPC server error: %v", err)
 		}
 	}()
@@ -281,11 +453,9 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		tcpSrv := tcp.FromEnv()
 		log.Println("✅ TCP server listening on :9090")
-		// TCP server will stop when context is cancelled (listener will close)
-		// For now, just run it - it will stop when process exits
-		if err := tcpSrv.Start(); err != nil {
+		// Cancelling ctx starts a graceful shutdown; see below.
+		if err := tcpSrv.Start(ctx); err != nil {
 			log.Printf("TCP server error: %v", err)
 		}
 	}()
@@ -294,20 +464,73 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		udpSrv := udp.FromEnv()
 		log.Println("✅ UDP server listening on :9091")
-		if err := udpSrv.Start(); err != nil {
+		if err := udpSrv.Start(ctx); err != nil {
 			log.Printf("UDP server error: %v", err)
 		}
 	}()
 
+	// Built-in relay for the other instances' brokers (MANGAHUB_RELAY_PORT)
+	if relay := broker.RelayFromEnv(); relay != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := relay.Start(ctx); err != nil {
+				log.Printf("Relay error: %v", err)
+			}
+		}()
+	}
+
+	// Flushes notifications held for quiet hours and sends scheduled digests
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		notify.NewDigestScheduler(dispatcher).Run(ctx)
+	}()
+
+	// Retries progress broadcasts that could not be delivered
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		userSvc.RunOutbox(ctx)
+	}()
+
+	// Chapter release detector feeding the UDP notification server
+	if os.Getenv("MANGAHUB_RELEASE_DETECTOR") != "false" && mangaSvc.UseMangaDex {
+		detector := releases.NewDetector(db, mangaSvc, bus)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			detector.Run(ctx)
+		}()
+	}
+
 	// 5. WebSocket Server (port 9093)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		hub := newHub()
+		hub := newHub(nodes)
 		go hub.run()
 
+		// Announce newly detected chapters in the general room.
+		bus.Subscribe("websocket", func(e events.Event) {
+			r, ok := e.Payload.(events.Release)
+			if !ok || e.UserID != "" {
+				return
+			}
+			hub.Broadcast <- ChatPayload{
+				Type:      "system",
+				Username:  "MangaHub",
+				Message:   r.Message,
+				Timestamp: r.Timestamp,
+				Room:      "general",
+			}
+		}, events.ChapterReleased)
+
 		r := gin.Default()
 		r.GET("/ws", func(c *gin.Context) {
 			username := c.Query("username")
@@ -344,11 +567,15 @@ func main() {
 			}()
 		})
 
+		wsAddr := os.Getenv("MANGAHUB_WS_ADDR")
+		if wsAddr == "" {
+			wsAddr = ":9093"
+		}
 		wsServer = &http.Server{
-			Addr:    ":9093",
+			Addr:    wsAddr,
 			Handler: r,
 		}
-		log.Println("✅ WebSocket server listening on :9093/ws")
+		log.Println("✅ WebSocket server listening on " + wsAddr + "/ws")
 		if err := wsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Printf("WebSocket server error: %v", err)
 		}
@@ -360,6 +587,7 @@ func main() {
 	<-sigChan
 
 	log.Println("\n🛑 Shutting down all servers...")
+	cancel()
 
 	// Create shutdown context with timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -379,6 +607,20 @@ func main() {
 		}
 	}
 
+	// Drain the TCP and UDP servers: clients get a server_shutdown message
+	// and queued broadcasts are sent before the connections close.
+	if err := tcpSrv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("TCP server shutdown error: %v", err)
+	}
+	if err := udpSrv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("UDP server shutdown error: %v", err)
+	}
+	// The UDP server closes its dispatcher too; closing it again here, where
+	// it was created, returns at once.
+	if err := dispatcher.Close(shutdownCtx); err != nil {
+		log.Printf("Notification dispatcher shutdown error: %v", err)
+	}
+
 	// Stop gRPC server
 	if grpcServer != nil {
 		grpcServer.Stop()
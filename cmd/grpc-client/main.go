@@ -7,9 +7,11 @@ import (
 	"log"
 	"time"
 
+	"mangahub/internal/tlsutil"
 	pb "mangahub/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -19,13 +21,35 @@ func main() {
 	mangaID := flag.String("manga-id", "", "Manga ID (for get/update)")
 	userID := flag.String("user-id", "", "User ID (for update)")
 	chapter := flag.Int("chapter", 0, "Current chapter (for update)")
+	clientTS := flag.Int64("client-ts", 0, "Client timestamp in unix ms (for update, default now)")
+	baseVersion := flag.Int64("base-version", 0, "Progress version last seen (for update)")
 	query := flag.String("query", "", "Search query")
 	genre := flag.String("genre", "", "Genre filter")
 	page := flag.Int("page", 1, "Page number")
+	useTLS := flag.Bool("tls", false, "Connect over TLS")
+	caFile := flag.String("ca", "", "CA certificate for verifying the server (implies -tls)")
+	certFile := flag.String("cert", "", "Client certificate for mutual TLS (implies -tls)")
+	keyFile := flag.String("key", "", "Client private key for mutual TLS")
+	serverName := flag.String("server-name", "", "Override the TLS server name")
 	flag.Parse()
 
+	creds := insecure.NewCredentials()
+	if *useTLS || *caFile != "" || *certFile != "" {
+		tlsCfg := &tlsutil.ClientConfig{
+			CAFile:     *caFile,
+			CertFile:   *certFile,
+			KeyFile:    *keyFile,
+			ServerName: *serverName,
+		}
+		cfg, err := tlsCfg.TLSConfig()
+		if err != nil {
+			log.Fatalf("TLS config: %v", err)
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+
 	// Connect to gRPC server
-	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -50,6 +74,9 @@ func main() {
 		fmt.Printf("   Author: %s\n", resp.Manga.Author)
 		fmt.Printf("   Status: %s\n", resp.Manga.Status)
 		fmt.Printf("   Chapters: %d\n", resp.Manga.TotalChapters)
+		if resp.Degraded {
+			fmt.Printf("   ⚠️  Degraded: served from local cache (MangaDex unavailable)\n")
+		}
 
 	case "search":
 		req := &pb.SearchMangaRequest{
@@ -65,7 +92,11 @@ func main() {
 		fmt.Printf("✅ Search results via gRPC:\n")
 		fmt.Printf("   Total: %d\n", resp.Total)
 		fmt.Printf("   Page: %d/%d\n", resp.Page, resp.TotalPages)
-		fmt.Printf("   Results: %d\n\n", len(resp.Data))
+		fmt.Printf("   Results: %d\n", len(resp.Data))
+		if resp.Degraded {
+			fmt.Printf("   ⚠️  Degraded: local results only (MangaDex unavailable)\n")
+		}
+		fmt.Println()
 		for i, m := range resp.Data {
 			if i >= 5 {
 				fmt.Printf("   ... and %d more\n", len(resp.Data)-5)
@@ -79,10 +110,12 @@ func main() {
 			log.Fatal("user-id and manga-id are required for update action")
 		}
 		req := &pb.UpdateProgressRequest{
-			UserId:         *userID,
-			MangaId:        *mangaID,
-			CurrentChapter: int32(*chapter),
-			Status:         "reading",
+			UserId:          *userID,
+			MangaId:         *mangaID,
+			CurrentChapter:  int32(*chapter),
+			Status:          "reading",
+			ClientTimestamp: *clientTS,
+			BaseVersion:     *baseVersion,
 		}
 		resp, err := client.UpdateProgress(ctx, req)
 		if err != nil {
@@ -92,6 +125,10 @@ func main() {
 		fmt.Printf("   Success: %v\n", resp.Success)
 		fmt.Printf("   Message: %s\n", resp.Message)
 		fmt.Printf("   Broadcast sent: %v\n", resp.BroadcastSent)
+		fmt.Printf("   Applied: %v (conflict: %v)\n", resp.Applied, resp.Conflict)
+		if p := resp.Progress; p != nil {
+			fmt.Printf("   Stored: chapter %d, status %s, version %d\n", p.CurrentChapter, p.Status, p.Version)
+		}
 
 	default:
 		log.Fatalf("Unknown action: %s (use: get, search, or update)", *action)
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"mangahub/internal/tlsutil"
+	"mangahub/pkg/syncclient"
+)
+
+// Simple TCP sync client: connects to the TCP server, prints the user's live
+// progress and library updates as they arrive and reconnects (resuming from
+// the last update seen) whenever the connection drops.
+//
+// Needs -token (the JWT from /auth/login); -user is only used with
+// admin/service tokens. -last-seq resumes from an earlier run's last seq,
+// which is printed on exit.
+
+func main() {
+	addr := flag.String("addr", syncclient.DefaultAddr, "TCP server address")
+	token := flag.String("token", os.Getenv("MANGAHUB_TOKEN"), "JWT from /auth/login (default $MANGAHUB_TOKEN)")
+	userID := flag.String("user", "", "user ID to act for (admin/service tokens only)")
+	version := flag.Int("version", syncclient.DefaultVersion, "protocol version to request")
+	framing := flag.String("framing", syncclient.FramingJSON, "wire framing: json | protobuf")
+	lastSeq := flag.Uint64("last-seq", 0, "resume after this sequence number")
+	snapshot := flag.Bool("snapshot", false, "print the whole library on connect (protocol version 2)")
+	useTLS := flag.Bool("tls", false, "Connect over TLS")
+	caFile := flag.String("ca", "", "CA certificate for verifying the server (implies -tls)")
+	certFile := flag.String("cert", "", "Client certificate for mutual TLS (implies -tls)")
+	keyFile := flag.String("key", "", "Client private key for mutual TLS")
+	serverName := flag.String("server-name", "", "Override the TLS server name")
+	flag.Parse()
+
+	if *token == "" {
+		log.Fatal("a -token is required (log in via POST /auth/login)")
+	}
+
+	var tlsConfig *tls.Config
+	if *useTLS || *caFile != "" || *certFile != "" {
+		tlsCfg := &tlsutil.ClientConfig{
+			CAFile:     *caFile,
+			CertFile:   *certFile,
+			KeyFile:    *keyFile,
+			ServerName: *serverName,
+		}
+		cfg, err := tlsCfg.TLSConfig()
+		if err != nil {
+			log.Fatalf("TLS config: %v", err)
+		}
+		tlsConfig = cfg
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := syncclient.Dial(ctx, syncclient.Config{
+		Addr:      *addr,
+		Token:     *token,
+		UserID:    *userID,
+		TLSConfig: tlsConfig,
+		Version:   *version,
+		Framing:   *framing,
+		LastSeq:   *lastSeq,
+	})
+	if err != nil {
+		log.Fatal("connect error:", err)
+	}
+	if *snapshot {
+		if err := client.RequestSnapshot("cli"); err != nil {
+			log.Fatal("snapshot error:", err)
+		}
+	}
+
+	fmt.Printf("Tailing updates for %s, Ctrl-C to stop...\n", client.UserID())
+	for u := range client.Updates() {
+		printUpdate(u)
+	}
+	if err := client.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Last seq: %d\n", client.LastSeq())
+}
+
+func printUpdate(u syncclient.Update) {
+	ts := time.Now().Format("15:04:05")
+	switch u.Type {
+	case syncclient.TypeProgress:
+		fmt.Printf("%s #%d 📖 %s chapter %d (v%d)\n", ts, u.Seq, u.MangaID, u.Chapter, u.Version)
+	case syncclient.TypeLibraryAdd:
+		fmt.Printf("%s #%d ➕ %s added (%s, chapter %d)\n", ts, u.Seq, u.MangaID, u.Status, u.Chapter)
+	case syncclient.TypeLibraryRemove:
+		fmt.Printf("%s #%d ➖ %s removed\n", ts, u.Seq, u.MangaID)
+	case syncclient.TypeStatusChange:
+		fmt.Printf("%s #%d 🏷  %s is now %s\n", ts, u.Seq, u.MangaID, u.Status)
+	case syncclient.TypeSubscriptionChange:
+		state := "off"
+		if u.Subscribed {
+			state = "on"
+		}
+		fmt.Printf("%s #%d 🔔 %s notifications %s\n", ts, u.Seq, u.MangaID, state)
+	case syncclient.TypeSnapshot:
+		fmt.Printf("%s library as of #%d:\n", ts, u.Snapshot.LastSeq)
+		for _, e := range u.Snapshot.Library {
+			fmt.Printf("  %s: chapter %d, %s (v%d)\n", e.MangaID, e.Chapter, e.Status, e.Version)
+		}
+		if len(u.Snapshot.Subscriptions) > 0 {
+			fmt.Printf("  subscribed: %v\n", u.Snapshot.Subscriptions)
+		}
+	case syncclient.TypeProgressConflict:
+		fmt.Printf("%s ⚠️  %s: kept chapter %d (v%d)\n", ts, u.MangaID, u.Chapter, u.Version)
+	case syncclient.TypeError:
+		fmt.Printf("%s error: %s\n", ts, u.Error)
+	case syncclient.TypeServerShutdown:
+		fmt.Printf("%s server is shutting down, reconnecting...\n", ts)
+	default:
+		fmt.Printf("%s %s\n", ts, u.Type)
+	}
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"mangahub/internal/user"
 )
 
 // Simple UDP client for UC-009/UC-010:
 // - register mode: sends a register message and listens for a confirmation
+// - listen mode: registers, keeps the registration alive and prints notifications
 // - notify mode: sends a chapter_release notification (admin trigger)
+//
+// Every mode needs -token (the JWT from /auth/login); notify requires an
+// admin account. -user is only used with admin/service tokens.
 
 type registerMessage struct {
 	Type        string   `json:"type"`
@@ -36,28 +45,37 @@ type notification struct {
 }
 
 func main() {
-	mode := flag.String("mode", "register", "mode: register | notify")
+	mode := flag.String("mode", "register", "mode: register | listen | notify")
 	addr := flag.String("addr", "localhost:9091", "UDP server address")
-	userID := flag.String("user", "", "user ID for registration")
-	mangaID := flag.String("manga", "", "manga ID for notification")
+	token := flag.String("token", os.Getenv("MANGAHUB_TOKEN"), "JWT from /auth/login (default $MANGAHUB_TOKEN)")
+	userID := flag.String("user", "", "user ID to act for (admin/service tokens only)")
+	signingKey := flag.String("signing-key", os.Getenv("MANGAHUB_UDP_SIGNING_KEY"), "verify notification signatures with this key in listen mode")
+	mangaID := flag.String("manga", "", "manga ID for notification, or comma-separated manga IDs to subscribe to when registering")
+	prefs := flag.String("prefs", "", "comma-separated genres to subscribe to when registering")
 	title := flag.String("title", "", "manga title for notification")
 	chapter := flag.Int("chapter", 1, "chapter number for notification")
 	message := flag.String("message", "New chapter released!", "notification message")
+	label := flag.String("label", "cli-client", "client label distinguishing this device")
 	flag.Parse()
 
+	if *token == "" {
+		log.Fatal("a -token is required (log in via POST /auth/login)")
+	}
+
 	switch *mode {
 	case "register":
-		if *userID == "" {
-			log.Fatal("register mode requires -user flag")
-		}
-		if err := doRegister(*addr, *userID); err != nil {
+		if err := doRegister(*addr, *token, *userID, *label, splitList(*mangaID), splitList(*prefs)); err != nil {
 			log.Fatal("register error:", err)
 		}
+	case "listen":
+		if err := doListen(*addr, *token, *userID, *label, []byte(*signingKey), splitList(*mangaID), splitList(*prefs)); err != nil {
+			log.Fatal("listen error:", err)
+		}
 	case "notify":
 		if *mangaID == "" || *title == "" {
 			log.Fatal("notify mode requires -manga and -title flags")
 		}
-		if err := doNotify(*addr, *mangaID, *title, *chapter, *message); err != nil {
+		if err := doNotify(*addr, *token, *mangaID, *title, *chapter, *message); err != nil {
 			log.Fatal("notify error:", err)
 		}
 	default:
@@ -65,11 +83,14 @@ func main() {
 	}
 }
 
-func doRegister(addr, userID string) error {
+func doRegister(addr, token, userID, label string, mangaIDs, prefs []string) error {
 	msg, err := user.RegisterForUDPNotifications(user.UDPRegisterOptions{
 		ServerAddr:  addr,
+		Token:       token,
 		UserID:      userID,
-		ClientLabel: "cli-client",
+		MangaIDs:    mangaIDs,
+		Preferences: prefs,
+		ClientLabel: label,
 	})
 	if err != nil {
 		return err
@@ -78,9 +99,34 @@ func doRegister(addr, userID string) error {
 	return nil
 }
 
-func doNotify(addr, mangaID, title string, chapter int, msg string) error {
+func doListen(addr, token, userID, label string, signingKey []byte, mangaIDs, prefs []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Listening for notifications (%s), Ctrl-C to stop...\n", label)
+	return user.ListenForUDPNotifications(ctx, user.UDPListenOptions{
+		SigningKey: signingKey,
+		UDPRegisterOptions: user.UDPRegisterOptions{
+			ServerAddr:  addr,
+			Token:       token,
+			UserID:      userID,
+			MangaIDs:    mangaIDs,
+			Preferences: prefs,
+			ClientLabel: label,
+		},
+	}, func(n user.UDPNotificationMessage) {
+		if n.Type == "server_shutdown" {
+			fmt.Println("⏸  Server is shutting down, waiting for it to come back...")
+			return
+		}
+		fmt.Printf("🔔 %s chapter %d: %s\n", n.Title, n.Chapter, n.Message)
+	})
+}
+
+func doNotify(addr, token, mangaID, title string, chapter int, msg string) error {
 	if err := user.SendUDPNotification(user.UDPNotification{
 		ServerAddr: addr,
+		Token:      token,
 		MangaID:    mangaID,
 		Title:      title,
 		Chapter:    chapter,
@@ -92,4 +138,13 @@ func doNotify(addr, mangaID, title string, chapter int, msg string) error {
 	return nil
 }
 
-
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
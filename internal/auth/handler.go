@@ -125,14 +125,30 @@ func (h *Handler) JWTMiddleware(c *gin.Context) {
 	}
 
 	raw := authHeader[7:]
-	userID, err := ParseUserIDFromToken(h.JWTSecret, raw)
+	claims, err := ParseToken(h.JWTSecret, raw)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
 	}
 
-	c.Set("user_id", userID)
+	c.Set("user_id", claims.UserID)
+	c.Set("role", claims.Role)
 	c.Next()
 }
 
+// RequireRole only lets through requests whose JWT role is one of roles.
+// It must run after JWTMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	}
+}
+
 
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mangahub/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testSecret = []byte("test-secret")
+
+// newTestRouter returns a router with the auth endpoints and an admin-only
+// GET /admin/ping, and the database behind it.
+func newTestRouter(t *testing.T) (*gin.Engine, *Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	svc := NewService(db)
+	r := gin.New()
+	authMiddleware := RegisterRoutes(r, svc, testSecret)
+	r.GET("/admin/ping", authMiddleware, RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return r, svc
+}
+
+func do(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// login returns a fresh token for alice.
+func login(t *testing.T, r *gin.Engine) string {
+	t.Helper()
+	w := do(r, http.MethodPost, "/auth/login", "", `{"username":"alice","password":"password1"}`)
+	var resp struct{ Token string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("login: %d %s", w.Code, w.Body)
+	}
+	return resp.Token
+}
+
+func TestRequireRole(t *testing.T) {
+	r, svc := newTestRouter(t)
+	if err := svc.RegisterUser("alice", "alice@example.com", "password1"); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	userToken := login(t, r)
+
+	if _, err := svc.DB.Exec(`UPDATE users SET role = 'admin' WHERE username = 'alice'`); err != nil {
+		t.Fatalf("grant admin: %v", err)
+	}
+	adminToken := login(t, r)
+
+	serviceToken, err := GenerateServiceJWT(testSecret, "api", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateServiceJWT: %v", err)
+	}
+	expired, err := GenerateServiceJWT(testSecret, "api", -time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceJWT: %v", err)
+	}
+	forged, err := GenerateServiceJWT([]byte("other-secret"), "api", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateServiceJWT: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"user", userToken, http.StatusForbidden},
+		{"admin", adminToken, http.StatusOK},
+		{"service", serviceToken, http.StatusForbidden},
+		{"expired", expired, http.StatusUnauthorized},
+		{"wrong secret", forged, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(r, http.MethodGet, "/admin/ping", tt.token, ""); w.Code != tt.want {
+				t.Fatalf("GET /admin/ping: %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -9,20 +9,66 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// Roles carried in the "role" claim.
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleService = "service" // internal components acting on behalf of users
+)
+
+// Claims holds the identity extracted from a validated JWT.
+type Claims struct {
+	UserID string
+	Role   string
+}
+
+// RoleForUser returns the role granted to u. Admins are marked out of band
+// in the users.role column; registration never grants it.
+func RoleForUser(u *models.User) string {
+	if u.Role == RoleAdmin {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
 // GenerateJWT creates a signed JWT for an authenticated user.
 func GenerateJWT(secret []byte, u *models.User) (string, error) {
 	claims := jwt.MapClaims{
 		"sub":   u.ID,
 		"usr":   u.Username,
 		"email": u.Email,
+		"role":  RoleForUser(u),
 		"exp":   time.Now().Add(24 * time.Hour).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(secret)
 }
 
+// GenerateServiceJWT creates a short-lived token for an internal component
+// (e.g. the API server talking to the UDP server). Service tokens may act on
+// behalf of any user.
+func GenerateServiceJWT(secret []byte, service string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":  "service:" + service,
+		"role": RoleService,
+		"exp":  time.Now().Add(ttl).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
+
 // ParseUserIDFromToken validates a JWT and extracts the user ID ("sub" claim).
 func ParseUserIDFromToken(secret []byte, raw string) (string, error) {
+	claims, err := ParseToken(secret, raw)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// ParseToken validates a JWT and extracts the user ID and role. Tokens
+// without a role claim are treated as regular users.
+func ParseToken(secret []byte, raw string) (*Claims, error) {
 	tok, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
@@ -30,18 +76,22 @@ func ParseUserIDFromToken(secret []byte, raw string) (string, error) {
 		return secret, nil
 	})
 	if err != nil || !tok.Valid {
-		return "", errors.New("invalid_token")
+		return nil, errors.New("invalid_token")
 	}
 
 	claims, ok := tok.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("invalid_claims")
+		return nil, errors.New("invalid_claims")
 	}
 	id, _ := claims["sub"].(string)
 	if id == "" {
-		return "", errors.New("missing_sub")
+		return nil, errors.New("missing_sub")
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = RoleUser
 	}
-	return id, nil
+	return &Claims{UserID: id, Role: role}, nil
 }
 
 
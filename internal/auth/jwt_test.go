@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+
+	"mangahub/pkg/models"
+)
+
+func TestRoleForUser(t *testing.T) {
+	tests := []struct {
+		role string
+		want string
+	}{
+		{"admin", RoleAdmin},
+		{"user", RoleUser},
+		{"", RoleUser},
+		// Only admin can be granted through users.role.
+		{"service", RoleUser},
+		{"Admin", RoleUser},
+	}
+	for _, tt := range tests {
+		u := &models.User{ID: "user_alice", Username: "alice", Role: tt.role}
+		if got := RoleForUser(u); got != tt.want {
+			t.Errorf("RoleForUser(role %q) = %q, want %q", tt.role, got, tt.want)
+		}
+
+		token, err := GenerateJWT(testSecret, u)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		claims, err := ParseToken(testSecret, token)
+		if err != nil {
+			t.Fatalf("ParseToken: %v", err)
+		}
+		if claims.UserID != u.ID || claims.Role != tt.want {
+			t.Errorf("token for role %q carries %+v, want role %q", tt.role, claims, tt.want)
+		}
+	}
+}
@@ -57,7 +57,7 @@ func (s *Service) AuthenticateUser(identifier, password string, byEmail bool) (*
 		return nil, errors.New("missing_credentials")
 	}
 
-	query := `SELECT id, username, email, password_hash, created_at FROM users WHERE `
+	query := `SELECT id, username, email, password_hash, role, created_at FROM users WHERE `
 	if byEmail {
 		query += `email = ?`
 	} else {
@@ -66,7 +66,7 @@ func (s *Service) AuthenticateUser(identifier, password string, byEmail bool) (*
 
 	var u models.User
 	err := s.DB.QueryRow(query, identifier).Scan(
-		&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.CreatedAt,
+		&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -0,0 +1,192 @@
+// Package broker fans messages out across all-servers instances, so a
+// broadcast made on one node reaches the TCP and WebSocket clients connected
+// to the others. Every node has an ID; messages carry the publishing node and
+// a unique ID, and subscribers never see their own node's messages or the
+// same message twice.
+//
+// Memory connects nodes inside one process; RelayClient connects nodes
+// through a Relay over TCP.
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Message is one envelope on the broker.
+type Message struct {
+	ID    string          `json:"id"`   // unique across nodes
+	Node  string          `json:"node"` // publishing node
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Handler receives messages published by other nodes.
+type Handler func(Message)
+
+// Broker publishes messages to the other nodes and delivers theirs.
+type Broker interface {
+	// NodeID identifies this node.
+	NodeID() string
+	// Publish sends data (JSON) to the subscribers of topic on every other
+	// node. It never blocks; a message that can't be queued is dropped with
+	// an error.
+	Publish(topic string, data []byte) error
+	// Subscribe calls handler for messages on topic from other nodes, one at
+	// a time and in arrival order. The returned function unsubscribes.
+	Subscribe(topic string, handler Handler) func()
+	// Close disconnects from the other nodes.
+	Close() error
+}
+
+// DefaultQueueSize is the number of messages buffered per subscription and,
+// for a RelayClient, for sending.
+const DefaultQueueSize = 1024
+
+// DefaultDedupeSize is the number of recent message IDs remembered per node.
+const DefaultDedupeSize = 4096
+
+// NewNodeID returns a node ID made of the host name and a random suffix, so
+// a restarted node never reuses the message IDs of its previous run.
+func NewNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "node"
+	}
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return host + "-" + hex.EncodeToString(b[:])
+}
+
+// FromEnv builds the broker for this instance from environment variables:
+// - MANGAHUB_NODE_ID (default host name plus a random suffix)
+// - MANGAHUB_RELAY_ADDR (relay to join, e.g. localhost:9095)
+// - MANGAHUB_RELAY_SECRET (shared secret expected by the relay)
+//
+// Without MANGAHUB_RELAY_ADDR the node sits alone on a MemoryHub: messages
+// only reach this process's own subscribers, so fan-out to other nodes is a
+// no-op and instances started this way do not see each other's events.
+func FromEnv(ctx context.Context) Broker {
+	nodeID := os.Getenv("MANGAHUB_NODE_ID")
+	if nodeID == "" {
+		nodeID = NewNodeID()
+	}
+	if addr := os.Getenv("MANGAHUB_RELAY_ADDR"); addr != "" {
+		return DialRelay(ctx, addr, nodeID, os.Getenv("MANGAHUB_RELAY_SECRET"))
+	}
+	return NewMemoryHub().Node(nodeID)
+}
+
+// sequence numbers the messages a node publishes.
+type sequence struct {
+	node string
+	next atomic.Uint64
+}
+
+func (s *sequence) message(topic string, data []byte) Message {
+	return Message{
+		ID:    fmt.Sprintf("%s-%d", s.node, s.next.Add(1)),
+		Node:  s.node,
+		Topic: topic,
+		Data:  data,
+	}
+}
+
+// subscriptions dispatches incoming messages to topic handlers, dropping the
+// node's own messages and duplicates. Each subscription has its own queue
+// and goroutine so a slow handler never blocks the connection.
+type subscriptions struct {
+	node string
+	seen *dedupe
+
+	mu     sync.RWMutex
+	nextID int
+	topics map[string]map[int]chan Message
+}
+
+func newSubscriptions(node string) *subscriptions {
+	return &subscriptions{
+		node:   node,
+		seen:   newDedupe(DefaultDedupeSize),
+		topics: make(map[string]map[int]chan Message),
+	}
+}
+
+func (s *subscriptions) add(topic string, handler Handler) func() {
+	ch := make(chan Message, DefaultQueueSize)
+
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	if s.topics[topic] == nil {
+		s.topics[topic] = make(map[int]chan Message)
+	}
+	s.topics[topic][id] = ch
+	s.mu.Unlock()
+
+	go func() {
+		for m := range ch {
+			handler(m)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.topics[topic], id)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (s *subscriptions) dispatch(m Message) {
+	if m.Node == s.node || s.seen.check(m.ID) {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, ch := range s.topics[m.Topic] {
+		select {
+		case ch <- m:
+		default:
+			log.Printf("[Broker] %s subscriber is not keeping up, dropped message %s", m.Topic, m.ID)
+		}
+	}
+}
+
+// dedupe remembers the most recent message IDs in a ring.
+type dedupe struct {
+	mu    sync.Mutex
+	ids   map[string]struct{}
+	order []string
+	next  int
+}
+
+func newDedupe(size int) *dedupe {
+	return &dedupe{ids: make(map[string]struct{}, size), order: make([]string, size)}
+}
+
+// check records id and reports whether it was already seen.
+func (d *dedupe) check(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.ids[id]; ok {
+		return true
+	}
+	if old := d.order[d.next]; old != "" {
+		delete(d.ids, old)
+	}
+	d.order[d.next] = id
+	d.next = (d.next + 1) % len(d.order)
+	d.ids[id] = struct{}{}
+	return false
+}
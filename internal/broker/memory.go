@@ -0,0 +1,56 @@
+package broker
+
+import "sync"
+
+// MemoryHub connects Memory brokers in one process. A single node on its own
+// hub is what all-servers uses when no relay is configured; publishing then
+// only reaches the node's own subscribers and no other instance.
+type MemoryHub struct {
+	mu    sync.RWMutex
+	nodes map[*Memory]struct{}
+}
+
+// NewMemoryHub creates a hub without nodes.
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{nodes: make(map[*Memory]struct{})}
+}
+
+// Node joins a new node with the given ID to the hub.
+func (h *MemoryHub) Node(id string) *Memory {
+	m := &Memory{hub: h, seq: &sequence{node: id}, subs: newSubscriptions(id)}
+	h.mu.Lock()
+	h.nodes[m] = struct{}{}
+	h.mu.Unlock()
+	return m
+}
+
+// Memory is a Broker node on a MemoryHub.
+type Memory struct {
+	hub  *MemoryHub
+	seq  *sequence
+	subs *subscriptions
+}
+
+func (m *Memory) NodeID() string { return m.seq.node }
+
+func (m *Memory) Publish(topic string, data []byte) error {
+	msg := m.seq.message(topic, data)
+	m.hub.mu.RLock()
+	defer m.hub.mu.RUnlock()
+	for node := range m.hub.nodes {
+		node.subs.dispatch(msg)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(topic string, handler Handler) func() {
+	return m.subs.add(topic, handler)
+}
+
+// Close leaves the hub.
+func (m *Memory) Close() error {
+	m.hub.mu.Lock()
+	delete(m.hub.nodes, m)
+	m.hub.mu.Unlock()
+	return nil
+}
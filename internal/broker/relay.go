@@ -0,0 +1,326 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Relay protocol: a node connects, sends a hello line and gets a welcome
+// (or an error) back. After that both directions carry one JSON Message per
+// line, and the relay forwards every line it reads to all other nodes.
+type relayHello struct {
+	Type   string `json:"type"` // "hello" | "welcome" | "error"
+	Node   string `json:"node,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Relay defaults.
+const (
+	DefaultRelayPort      = "9095"
+	DefaultMaxMessageSize = 1 << 20
+	relayWriteTimeout     = 10 * time.Second
+	relayHelloTimeout     = 10 * time.Second
+	relayMinBackoff       = 500 * time.Millisecond
+	relayMaxBackoff       = 10 * time.Second
+)
+
+// ErrQueueFull is returned by Publish when the send queue is full.
+var ErrQueueFull = errors.New("broker: send queue full")
+
+// Relay is a small hub that forwards messages between nodes. It keeps no
+// state beyond the connected nodes: a node that is disconnected misses what
+// is published meanwhile.
+type Relay struct {
+	Port   string
+	Secret string // required from nodes when set
+
+	mu    sync.Mutex
+	nodes map[*relayNode]struct{}
+}
+
+type relayNode struct {
+	id   string
+	conn net.Conn
+	send chan []byte
+	once sync.Once
+}
+
+func (n *relayNode) close() { n.once.Do(func() { n.conn.Close() }) }
+
+// NewRelay creates a relay listening on port.
+func NewRelay(port, secret string) *Relay {
+	if port == "" {
+		port = DefaultRelayPort
+	}
+	return &Relay{Port: port, Secret: secret, nodes: make(map[*relayNode]struct{})}
+}
+
+// RelayFromEnv returns the built-in relay if this instance should run one:
+// - MANGAHUB_RELAY_PORT (unset: no relay)
+// - MANGAHUB_RELAY_SECRET
+func RelayFromEnv() *Relay {
+	port := os.Getenv("MANGAHUB_RELAY_PORT")
+	if port == "" {
+		return nil
+	}
+	return NewRelay(port, os.Getenv("MANGAHUB_RELAY_SECRET"))
+}
+
+// Start accepts nodes until ctx is cancelled.
+func (r *Relay) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", ":"+r.Port)
+	if err != nil {
+		return fmt.Errorf("relay listen error: %w", err)
+	}
+	log.Println("[Broker] relay listening on :" + r.Port)
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Println("[Broker] relay accept error:", err)
+			continue
+		}
+		go r.handleNode(conn)
+	}
+}
+
+func (r *Relay) handleNode(conn net.Conn) {
+	defer conn.Close()
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 0, 4096), DefaultMaxMessageSize)
+
+	_ = conn.SetDeadline(time.Now().Add(relayHelloTimeout))
+	var hello relayHello
+	if !sc.Scan() || json.Unmarshal(sc.Bytes(), &hello) != nil || hello.Type != "hello" || hello.Node == "" {
+		_ = writeJSON(conn, relayHello{Type: "error", Error: "expected_hello"})
+		return
+	}
+	if r.Secret != "" && subtle.ConstantTimeCompare([]byte(hello.Secret), []byte(r.Secret)) != 1 {
+		log.Printf("[Broker] relay rejected node %s from %s: invalid secret", hello.Node, conn.RemoteAddr())
+		_ = writeJSON(conn, relayHello{Type: "error", Error: "invalid_secret"})
+		return
+	}
+	if err := writeJSON(conn, relayHello{Type: "welcome"}); err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	n := &relayNode{id: hello.Node, conn: conn, send: make(chan []byte, DefaultQueueSize)}
+	r.mu.Lock()
+	r.nodes[n] = struct{}{}
+	r.mu.Unlock()
+	log.Printf("[Broker] node %s joined the relay from %s", n.id, conn.RemoteAddr())
+
+	go r.writeLoop(n)
+	for sc.Scan() {
+		line := append(append([]byte(nil), sc.Bytes()...), '\n')
+		r.forward(n, line)
+	}
+
+	// forward holds r.mu while queueing, so closing send here is safe.
+	r.mu.Lock()
+	delete(r.nodes, n)
+	close(n.send)
+	r.mu.Unlock()
+	n.close()
+	log.Printf("[Broker] node %s left the relay", n.id)
+}
+
+// forward queues line for every node but from.
+func (r *Relay) forward(from *relayNode, line []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for n := range r.nodes {
+		if n == from {
+			continue
+		}
+		select {
+		case n.send <- line:
+		default:
+			log.Printf("[Broker] node %s is not keeping up, disconnecting", n.id)
+			n.close()
+		}
+	}
+}
+
+func (r *Relay) writeLoop(n *relayNode) {
+	for line := range n.send {
+		_ = n.conn.SetWriteDeadline(time.Now().Add(relayWriteTimeout))
+		if _, err := n.conn.Write(line); err != nil {
+			n.close()
+			return
+		}
+	}
+}
+
+// RelayClient is a Broker node connected to a Relay. It reconnects with
+// backoff; messages published while disconnected wait in the send queue.
+type RelayClient struct {
+	addr   string
+	secret string
+	seq    *sequence
+	subs   *subscriptions
+	send   chan []byte
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// DialRelay joins the relay at addr as nodeID. It connects in the
+// background, so the relay doesn't have to be up yet.
+func DialRelay(ctx context.Context, addr, nodeID, secret string) *RelayClient {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &RelayClient{
+		addr:   addr,
+		secret: secret,
+		seq:    &sequence{node: nodeID},
+		subs:   newSubscriptions(nodeID),
+		send:   make(chan []byte, DefaultQueueSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go c.run(ctx)
+	return c
+}
+
+func (c *RelayClient) NodeID() string { return c.seq.node }
+
+func (c *RelayClient) Publish(topic string, data []byte) error {
+	line, err := json.Marshal(c.seq.message(topic, data))
+	if err != nil {
+		return err
+	}
+	select {
+	case c.send <- append(line, '\n'):
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (c *RelayClient) Subscribe(topic string, handler Handler) func() {
+	return c.subs.add(topic, handler)
+}
+
+// Close disconnects from the relay.
+func (c *RelayClient) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+func (c *RelayClient) run(ctx context.Context) {
+	defer close(c.done)
+
+	backoff := relayMinBackoff
+	for {
+		conn, sc, err := c.connect(ctx)
+		if err == nil {
+			log.Printf("[Broker] node %s joined relay %s", c.seq.node, c.addr)
+			backoff = relayMinBackoff
+			err = c.serve(ctx, conn, sc)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[Broker] relay %s: %v; retrying in %s", c.addr, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > relayMaxBackoff {
+			backoff = relayMaxBackoff
+		}
+	}
+}
+
+func (c *RelayClient) connect(ctx context.Context) (net.Conn, *bufio.Scanner, error) {
+	d := net.Dialer{Timeout: relayHelloTimeout}
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(relayHelloTimeout))
+	if err := writeJSON(conn, relayHello{Type: "hello", Node: c.seq.node, Secret: c.secret}); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 0, 4096), DefaultMaxMessageSize)
+	var resp relayHello
+	if !sc.Scan() || json.Unmarshal(sc.Bytes(), &resp) != nil {
+		conn.Close()
+		return nil, nil, errors.New("no welcome from relay")
+	}
+	if resp.Type != "welcome" {
+		conn.Close()
+		return nil, nil, fmt.Errorf("rejected by relay: %s", resp.Error)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, sc, nil
+}
+
+// serve writes queued messages and dispatches received ones until the
+// connection fails or ctx is done.
+func (c *RelayClient) serve(ctx context.Context, conn net.Conn, sc *bufio.Scanner) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case line := <-c.send:
+				_ = conn.SetWriteDeadline(time.Now().Add(relayWriteTimeout))
+				if _, err := conn.Write(line); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for sc.Scan() {
+		var m Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			continue
+		}
+		c.subs.dispatch(m)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
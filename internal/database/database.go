@@ -55,6 +55,80 @@ func migrate(db *sql.DB) error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			PRIMARY KEY (user_id, manga_id)
 		);`,
+		`CREATE TABLE IF NOT EXISTS manga_release_state (
+			manga_id TEXT PRIMARY KEY,
+			last_chapter REAL,
+			last_chapter_id TEXT,
+			checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			type TEXT,
+			manga_id TEXT,
+			title TEXT,
+			chapter INTEGER,
+			message TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			read_at TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, id);`,
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id TEXT PRIMARY KEY,
+			prefs TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS chapter_releases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			manga_id TEXT NOT NULL,
+			title TEXT,
+			chapter REAL NOT NULL,
+			chapter_label TEXT,
+			released_at INTEGER NOT NULL, -- unix seconds
+			UNIQUE (manga_id, chapter)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_chapter_releases_time ON chapter_releases (released_at);`,
+		`CREATE TABLE IF NOT EXISTS digest_schedules (
+			user_id TEXT PRIMARY KEY,
+			frequency TEXT NOT NULL, -- daily | weekly | off
+			hour INTEGER NOT NULL,
+			weekday INTEGER NOT NULL,
+			timezone TEXT,
+			last_sent_at INTEGER, -- unix seconds
+			next_run_at INTEGER NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS notification_queue (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			reason TEXT,
+			deliver_after INTEGER NOT NULL, -- unix seconds
+			attempts INTEGER DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS progress_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			manga_id TEXT NOT NULL,
+			chapter INTEGER NOT NULL,
+			status TEXT,
+			created_at INTEGER NOT NULL, -- unix seconds, also the update timestamp
+			state TEXT NOT NULL DEFAULT 'pending', -- pending | failed
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at INTEGER NOT NULL, -- unix seconds
+			last_error TEXT
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_progress_outbox_due ON progress_outbox (state, next_attempt_at);`,
+		`CREATE TABLE IF NOT EXISTS sync_log (
+			user_id TEXT NOT NULL,
+			seq INTEGER NOT NULL, -- per-user sequence number
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL, -- JSON message as sent to clients
+			PRIMARY KEY (user_id, seq)
+		);`,
 	}
 
 	for _, stmt := range stmts {
@@ -62,5 +136,66 @@ func migrate(db *sql.DB) error {
 			return fmt.Errorf("migrate: %w", err)
 		}
 	}
+
+	// Columns added after the tables were first created.
+	columns := []struct{ table, column, decl string }{
+		{"users", "role", "TEXT NOT NULL DEFAULT 'user'"}, // 'admin' is granted by hand
+		{"user_progress", "version", "INTEGER NOT NULL DEFAULT 0"},
+		{"user_progress", "client_updated_at", "INTEGER NOT NULL DEFAULT 0"}, // unix ms
+		{"progress_outbox", "version", "INTEGER NOT NULL DEFAULT 0"},
+		{"progress_outbox", "client_updated_at", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := addColumn(db, c.table, c.column, c.decl); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	if err := migrateProgressLog(db); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
 	return nil
 }
+
+// migrateProgressLog moves the progress history of databases created before
+// sync_log into it, keeping sequence numbers. The emptied progress_log table
+// is left in place.
+func migrateProgressLog(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'progress_log')`).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(
+		`INSERT OR IGNORE INTO sync_log (user_id, seq, type, payload)
+		SELECT user_id, seq, 'progress', json_object(
+			'type', 'progress', 'seq', seq, 'user_id', user_id,
+			'manga_id', manga_id, 'chapter', chapter, 'timestamp', timestamp)
+		FROM progress_log`,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM progress_log`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addColumn adds a column to table unless it already exists.
+func addColumn(db *sql.DB, table, column, decl string) error {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, table, column,
+	).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
+	return err
+}
@@ -0,0 +1,115 @@
+// Package events is the in-process publish/subscribe bus that connects the
+// services in all-servers. Services publish what happened (progress updated,
+// library changed, chapter released, subscriptions changed) and the TCP, UDP,
+// SSE and WebSocket servers subscribe to the events they relay.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	ProgressUpdated     = "progress_updated"     // payload Progress
+	LibraryChanged      = "library_changed"      // payload LibraryChange
+	ChapterReleased     = "chapter_released"     // payload Release
+	SubscriptionChanged = "subscription_changed" // payload SubscriptionChange
+)
+
+// Event is one message on the bus.
+type Event struct {
+	Type string
+	// UserID is the user the event concerns; empty for global events such
+	// as a newly detected chapter before its recipients are known.
+	UserID string
+	// Source names the publishing component so a consumer can skip events
+	// it produced itself (e.g. "tcp", "udp", "user").
+	Source  string
+	Payload interface{}
+	Time    time.Time
+}
+
+// DefaultBufferSize is the number of events queued per subscriber.
+const DefaultBufferSize = 256
+
+// Bus fans events out to subscribers. Each subscriber has its own queue and
+// goroutine so a slow consumer never blocks publishers or other consumers;
+// when a queue is full the event is dropped for that subscriber.
+type Bus struct {
+	BufferSize int
+
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]*subscriber
+}
+
+type subscriber struct {
+	name  string
+	types map[string]bool // nil = all types
+	ch    chan Event
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{
+		BufferSize: DefaultBufferSize,
+		subs:       make(map[int]*subscriber),
+	}
+}
+
+// Publish queues e for every subscriber of its type.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if sub.types != nil && !sub.types[e.Type] {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			log.Printf("[Events] %s is not keeping up, dropped %s event for user %s", sub.name, e.Type, e.UserID)
+		}
+	}
+}
+
+// Subscribe calls handler for every event of the given types (all types if
+// none are given), one at a time and in publish order. name identifies the
+// subscriber in logs. The returned function unsubscribes.
+func (b *Bus) Subscribe(name string, handler func(Event), types ...string) func() {
+	sub := &subscriber{name: name, ch: make(chan Event, b.BufferSize)}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	go func() {
+		for e := range sub.ch {
+			handler(e)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
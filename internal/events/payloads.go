@@ -0,0 +1,48 @@
+package events
+
+// Progress is the payload of ProgressUpdated.
+type Progress struct {
+	UserID    string `json:"user_id"`
+	MangaID   string `json:"manga_id"`
+	Chapter   int    `json:"chapter"`
+	Status    string `json:"status,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+
+	// Version of the stored progress and the client time (unix ms) of the
+	// update that produced it.
+	Version         int64 `json:"version,omitempty"`
+	ClientTimestamp int64 `json:"client_timestamp,omitempty"`
+}
+
+// Library change actions.
+const (
+	LibraryAdded         = "added"
+	LibraryRemoved       = "removed"
+	LibraryStatusChanged = "status_changed"
+)
+
+// LibraryChange is the payload of LibraryChanged.
+type LibraryChange struct {
+	UserID  string `json:"user_id"`
+	MangaID string `json:"manga_id"`
+	Action  string `json:"action"` // added | removed | status_changed
+	Chapter int    `json:"chapter,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// Release is the payload of ChapterReleased.
+type Release struct {
+	MangaID   string   `json:"manga_id"`
+	Title     string   `json:"title"`
+	Chapter   int      `json:"chapter"`
+	Message   string   `json:"message"`
+	Timestamp int64    `json:"timestamp"`
+	Genres    []string `json:"genres,omitempty"`
+}
+
+// SubscriptionChange is the payload of SubscriptionChanged.
+type SubscriptionChange struct {
+	UserID     string `json:"user_id"`
+	MangaID    string `json:"manga_id"`
+	Subscribed bool   `json:"subscribed"`
+}
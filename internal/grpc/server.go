@@ -1,6 +1,7 @@
 package grpc
 
 import (
+	"crypto/tls"
 	"log"
 	"net"
 
@@ -8,6 +9,7 @@ import (
 	"mangahub/internal/user"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 
 	pb "mangahub/proto"
@@ -20,9 +22,14 @@ type Server struct {
 	userService  *user.Service
 }
 
-// NewServer creates a new gRPC server with the provided services
-func NewServer(mangaSvc *manga.Service, userSvc *user.Service) *Server {
-	grpcServer := grpc.NewServer()
+// NewServer creates a new gRPC server with the provided services. A non-nil
+// tlsConfig serves over TLS instead of plaintext.
+func NewServer(mangaSvc *manga.Service, userSvc *user.Service, tlsConfig *tls.Config) *Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(opts...)
 
 	// Create the manga service implementation
 	mangaSvcImpl := NewMangaServiceServer(mangaSvc, userSvc)
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"mangahub/internal/manga"
@@ -37,13 +38,17 @@ func (s *ServiceServer) GetManga(ctx context.Context, req *pb.GetMangaRequest) (
 		if err.Error() == "not_found" {
 			return nil, ErrNotFound("manga not found")
 		}
+		if errors.Is(err, manga.ErrUpstreamUnavailable) {
+			return nil, ErrUnavailable("manga source temporarily unavailable")
+		}
 		log.Printf("Error querying manga: %v", err)
 		return nil, ErrInternal("failed to query manga")
 	}
 
 	// Construct protobuf response message
 	return &pb.GetMangaResponse{
-		Manga: toProtoManga(m),
+		Manga:    toProtoManga(m),
+		Degraded: s.mangaService.Degraded(),
 	}, nil
 }
 
@@ -86,6 +91,7 @@ func (s *ServiceServer) SearchManga(ctx context.Context, req *pb.SearchMangaRequ
 		Page:       int32(result.Page),
 		Limit:      int32(result.Limit),
 		TotalPages: int32(result.TotalPages),
+		Degraded:   result.Degraded,
 	}, nil
 }
 
@@ -104,9 +110,11 @@ func (s *ServiceServer) UpdateProgress(ctx context.Context, req *pb.UpdateProgre
 
 	// Update user_progress table
 	updateReq := user.UpdateProgressRequest{
-		MangaID:        req.MangaId,
-		CurrentChapter: int(req.CurrentChapter),
-		Status:         req.Status,
+		MangaID:         req.MangaId,
+		CurrentChapter:  int(req.CurrentChapter),
+		Status:          req.Status,
+		ClientTimestamp: req.ClientTimestamp,
+		BaseVersion:     req.BaseVersion,
 	}
 
 	result, err := s.userService.UpdateProgress(req.UserId, updateReq)
@@ -123,15 +131,49 @@ func (s *ServiceServer) UpdateProgress(ctx context.Context, req *pb.UpdateProgre
 		return nil, ErrInternal("failed to update progress")
 	}
 
+	// Stale updates are not errors: the stored progress is returned so the
+	// caller can reconcile.
+	progress := toProtoProgress(req.UserId, result.Progress)
+	if !result.Applied {
+		msg := "A newer update is already stored; progress unchanged"
+		if result.Rejected {
+			msg = "Progress update is stale"
+		}
+		return &pb.UpdateProgressResponse{
+			Success:  !result.Rejected,
+			Message:  msg,
+			Conflict: result.Rejected,
+			Progress: progress,
+		}, nil
+	}
+
 	// Trigger TCP broadcast for real-time sync (already done in UpdateProgress)
 	// Return success confirmation
 	return &pb.UpdateProgressResponse{
 		Success:       true,
 		Message:       "Progress updated successfully",
 		BroadcastSent: result.BroadcastSent,
+		Applied:       true,
+		Progress:      progress,
 	}, nil
 }
 
+// Helper function to convert user.ProgressState to proto UserProgress
+func toProtoProgress(userID string, p *user.ProgressState) *pb.UserProgress {
+	if p == nil {
+		return nil
+	}
+	return &pb.UserProgress{
+		UserId:          userID,
+		MangaId:         p.MangaID,
+		CurrentChapter:  int32(p.CurrentChapter),
+		Status:          p.Status,
+		UpdatedAt:       p.UpdatedAt,
+		Version:         p.Version,
+		ClientTimestamp: p.ClientTimestamp,
+	}
+}
+
 // Helper function to convert models.Manga to proto Manga
 func toProtoManga(m *models.Manga) *pb.Manga {
 	return &pb.Manga{
@@ -0,0 +1,75 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"mangahub/internal/database"
+	"mangahub/internal/manga"
+	"mangahub/pkg/models"
+	pb "mangahub/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// downProvider is a remote provider that cannot be reached.
+type downProvider struct{ circuitOpen bool }
+
+func (p *downProvider) Name() string   { return "down" }
+func (p *downProvider) Degraded() bool { return p.circuitOpen }
+
+func (p *downProvider) Search(params manga.SearchParams) (*manga.SearchResult, error) {
+	return nil, fmt.Errorf("%w: fetch failed", manga.ErrUpstreamUnavailable)
+}
+
+func (p *downProvider) Get(id string) (*models.Manga, error) {
+	return nil, fmt.Errorf("%w: fetch failed", manga.ErrUpstreamUnavailable)
+}
+
+func (p *downProvider) ChapterCount(id string) (int, error) { return 0, errors.New("unavailable") }
+func (p *downProvider) Tags() ([]string, error)             { return nil, errors.New("unavailable") }
+
+func newTestServer(t *testing.T, p manga.Provider) *ServiceServer {
+	t.Helper()
+	t.Setenv("MANGAHUB_USE_MANGADEX", "false")
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	svc := manga.NewService(db)
+	svc.RegisterProvider(p)
+	return NewMangaServiceServer(svc, nil)
+}
+
+func TestUnavailableProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		circuitOpen bool
+	}{
+		{"unreachable", false},
+		{"circuit open", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, &downProvider{circuitOpen: tt.circuitOpen})
+
+			_, err := s.GetManga(context.Background(), &pb.GetMangaRequest{MangaId: "down-1"})
+			if code := status.Code(err); code != codes.Unavailable {
+				t.Fatalf("GetManga: %v (%s), want %s", err, code, codes.Unavailable)
+			}
+
+			resp, err := s.SearchManga(context.Background(), &pb.SearchMangaRequest{})
+			if err != nil {
+				t.Fatalf("SearchManga: %v", err)
+			}
+			if !resp.Degraded {
+				t.Fatal("SearchManga not flagged degraded")
+			}
+		})
+	}
+}
@@ -21,6 +21,10 @@ func ErrInternal(msg string) error {
 	return status.Error(codes.Internal, msg)
 }
 
+func ErrUnavailable(msg string) error {
+	return status.Error(codes.Unavailable, msg)
+}
+
 // GetMangaRequest, GetMangaResponse, SearchMangaRequest, etc. are defined here
 // to match the proto structure without requiring protoc generation
 
@@ -29,7 +33,8 @@ type GetMangaRequest struct {
 }
 
 type GetMangaResponse struct {
-	Manga *Manga
+	Manga    *Manga
+	Degraded bool
 }
 
 type SearchMangaRequest struct {
@@ -46,19 +51,25 @@ type SearchMangaResponse struct {
 	Page       int32
 	Limit      int32
 	TotalPages int32
+	Degraded   bool
 }
 
 type UpdateProgressRequest struct {
-	UserId         string
-	MangaId        string
-	CurrentChapter int32
-	Status         string
+	UserId          string
+	MangaId         string
+	CurrentChapter  int32
+	Status          string
+	ClientTimestamp int64
+	BaseVersion     int64
 }
 
 type UpdateProgressResponse struct {
 	Success       bool
 	Message       string
 	BroadcastSent bool
+	Applied       bool
+	Conflict      bool
+	Progress      *UserProgress
 }
 
 type Manga struct {
@@ -72,6 +83,16 @@ type Manga struct {
 	CoverUrl      string
 }
 
+type UserProgress struct {
+	UserId          string
+	MangaId         string
+	CurrentChapter  int32
+	Status          string
+	UpdatedAt       int64
+	Version         int64
+	ClientTimestamp int64
+}
+
 // MangaServiceServer interface defines the gRPC service methods
 type MangaServiceServer interface {
 	GetManga(ctx context.Context, req *GetMangaRequest) (*GetMangaResponse, error)
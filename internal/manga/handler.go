@@ -1,6 +1,7 @@
 package manga
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -17,6 +18,8 @@ func RegisterRoutes(r *gin.RouterGroup, svc *Service) {
 
 	r.GET("/manga", h.HandleListManga)
 	r.GET("/manga/:id", h.HandleGetManga)
+	r.GET("/manga/tags", h.HandleListTags)
+	r.GET("/manga/cache/stats", h.HandleCacheStats)
 }
 
 func (h *Handler) HandleListManga(c *gin.Context) {
@@ -51,7 +54,8 @@ func (h *Handler) HandleListManga(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": result.Data,
+		"data":     result.Data,
+		"degraded": result.Degraded,
 		"pagination": gin.H{
 			"page":        result.Page,
 			"limit":       result.Limit,
@@ -72,6 +76,13 @@ func (h *Handler) HandleGetManga(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if errors.Is(err, ErrUpstreamUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":    "manga source temporarily unavailable",
+				"degraded": true,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query manga"})
 		return
 	}
@@ -86,8 +97,32 @@ func (h *Handler) HandleGetManga(c *gin.Context) {
 		"total_chapters": result.Manga.TotalChapters,
 		"description":    result.Manga.Description,
 		"cover_url":      result.Manga.CoverURL,
+		"alt_ids":        result.Manga.AltIDs,
 		"user_progress":  result.UserProgress,
+		"degraded":       h.Service.Degraded(),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+// HandleCacheStats reports hit/miss metrics for the MangaDex response cache
+// along with the current circuit breaker state.
+func (h *Handler) HandleCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"cache":   h.Service.MangaDex.CacheStats(),
+		"circuit": h.Service.MangaDex.CircuitState(),
+	})
+}
+
+// HandleListTags returns the tags/genres known to all metadata providers.
+func (h *Handler) HandleListTags(c *gin.Context) {
+	tags, err := h.Service.GetTags()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query tags"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":      tags,
+		"providers": append([]string{LocalProviderName}, h.Service.Providers()...),
+	})
+}
@@ -0,0 +1,47 @@
+package manga
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandlerReportsUnavailableProviders(t *testing.T) {
+	unreachable := fmt.Errorf("%w: fetch failed", ErrUpstreamUnavailable)
+	tests := []struct {
+		name     string
+		provider *fakeProvider
+		path     string
+		status   int
+		degraded bool
+	}{
+		{"get, unreachable", &fakeProvider{err: unreachable}, "/manga/fake-1", http.StatusServiceUnavailable, true},
+		{"get, circuit open", &fakeProvider{err: errors.New("circuit open"), degraded: true}, "/manga/fake-1", http.StatusServiceUnavailable, true},
+		{"get, not found", &fakeProvider{}, "/manga/fake-1", http.StatusNotFound, false},
+		{"search, unreachable", &fakeProvider{err: unreachable}, "/manga", http.StatusOK, true},
+		{"search, circuit open", &fakeProvider{total: 1, degraded: true}, "/manga", http.StatusOK, true},
+		{"search, available", &fakeProvider{total: 1}, "/manga", http.StatusOK, false},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			RegisterRoutes(r.Group("/"), newTestService(t, tt.provider, 1))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			var body struct{ Degraded bool }
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			if w.Code != tt.status || body.Degraded != tt.degraded {
+				t.Fatalf("GET %s: %d degraded=%v, want %d degraded=%v", tt.path, w.Code, body.Degraded, tt.status, tt.degraded)
+			}
+		})
+	}
+}
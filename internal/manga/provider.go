@@ -0,0 +1,162 @@
+package manga
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"mangahub/pkg/models"
+)
+
+// Provider is a source of manga metadata (MangaDex, the local DB, ...).
+// IDs passed to Get and ChapterCount are provider-native; IDs on returned
+// manga are provider-qualified ("<name>-<native id>") except for the local
+// provider, whose IDs are stored as-is. Errors from a provider that could not
+// be reached wrap ErrUpstreamUnavailable.
+type Provider interface {
+	Name() string
+	Search(params SearchParams) (*SearchResult, error)
+	Get(id string) (*models.Manga, error)
+	ChapterCount(id string) (int, error)
+	Tags() ([]string, error)
+}
+
+// idOwner is implemented by providers that can recognise their own bare,
+// unqualified IDs (e.g. MangaDex UUIDs).
+type idOwner interface {
+	OwnsID(id string) bool
+}
+
+// degradable is implemented by providers that can report being unavailable.
+type degradable interface {
+	Degraded() bool
+}
+
+// LocalProviderName is the name of the built-in local-DB provider.
+const LocalProviderName = "local"
+
+// QualifyID builds a provider-qualified manga ID.
+func QualifyID(provider, id string) string {
+	if provider == LocalProviderName {
+		return id
+	}
+	return provider + "-" + id
+}
+
+// RegisterProvider adds a remote metadata provider. Providers are consulted
+// in registration order, which is also their priority when merging results.
+func (s *Service) RegisterProvider(p Provider) {
+	s.providers = append(s.providers, p)
+}
+
+// Providers returns the names of the registered remote providers.
+func (s *Service) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for _, p := range s.providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// ParseID splits a manga ID into provider name and provider-native ID.
+// Unqualified IDs belong to the first provider that claims them, otherwise
+// to the local provider.
+func (s *Service) ParseID(id string) (provider, nativeID string) {
+	for _, p := range s.providers {
+		if prefix := p.Name() + "-"; strings.HasPrefix(id, prefix) {
+			return p.Name(), strings.TrimPrefix(id, prefix)
+		}
+	}
+	for _, p := range s.providers {
+		if o, ok := p.(idOwner); ok && o.OwnsID(id) {
+			return p.Name(), id
+		}
+	}
+	return LocalProviderName, id
+}
+
+func (s *Service) provider(name string) Provider {
+	if name == LocalProviderName {
+		return s.local
+	}
+	for _, p := range s.providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func providerDegraded(p Provider) bool {
+	d, ok := p.(degradable)
+	return ok && d.Degraded()
+}
+
+// seriesKey normalises a title so the same series from different providers
+// compares equal ("One Piece", "one-piece!" -> "onepiece").
+func seriesKey(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// mergeResults combines per-provider result lists in priority order. Entries
+// with the same ID are dropped; entries for the same series (by normalised
+// title) are linked by recording the lower-priority ID in AltIDs of the
+// entry that is kept.
+func mergeResults(lists ...[]models.Manga) []models.Manga {
+	merged := []models.Manga{}
+	byID := make(map[string]int)
+	byKey := make(map[string]int)
+
+	for _, list := range lists {
+		for _, m := range list {
+			if _, ok := byID[m.ID]; ok {
+				continue
+			}
+			key := seriesKey(m.Title)
+			if idx, ok := byKey[key]; ok && key != "" {
+				merged[idx].AltIDs = appendUnique(merged[idx].AltIDs, m.ID)
+				byID[m.ID] = idx
+				continue
+			}
+			merged = append(merged, m)
+			byID[m.ID] = len(merged) - 1
+			if key != "" {
+				byKey[key] = len(merged) - 1
+			}
+		}
+	}
+	return merged
+}
+
+// mergeTags unions tag lists case-insensitively and sorts them.
+func mergeTags(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, list := range lists {
+		for _, t := range list {
+			k := strings.ToLower(strings.TrimSpace(t))
+			if k == "" || seen[k] {
+				continue
+			}
+			seen[k] = true
+			tags = append(tags, strings.TrimSpace(t))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i]) < strings.ToLower(tags[j]) })
+	return tags
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, x := range list {
+		if x == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
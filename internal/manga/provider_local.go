@@ -0,0 +1,125 @@
+package manga
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"mangahub/pkg/models"
+)
+
+// LocalProvider serves manga stored in the local database: entries created
+// locally as well as everything cached from remote providers.
+type LocalProvider struct {
+	DB *sql.DB
+}
+
+func (p *LocalProvider) Name() string { return LocalProviderName }
+
+// Search runs a LIKE-based search over the manga table.
+func (p *LocalProvider) Search(params SearchParams) (*SearchResult, error) {
+	mangas, total, err := p.query(params, params.Limit, (params.Page-1)*params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchResult{
+		Data:       mangas,
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: (total + params.Limit - 1) / params.Limit,
+	}, nil
+}
+
+// matches returns every stored manga matching params, ordered by title.
+func (p *LocalProvider) matches(params SearchParams) ([]models.Manga, error) {
+	mangas, _, err := p.query(params, -1, 0) // SQLite: LIMIT -1 is no limit
+	return mangas, err
+}
+
+// query returns up to limit matching manga from offset, and how many match.
+func (p *LocalProvider) query(params SearchParams, limit, offset int) ([]models.Manga, int, error) {
+	queryLike := "%" + params.Query + "%"
+	genreLike := "%" + params.Genre + "%"
+
+	where := `WHERE (? = '' OR title LIKE ? OR author LIKE ?)
+		AND (? = '' OR genres LIKE ?)
+		AND (? = '' OR LOWER(status) = LOWER(?))`
+	args := []interface{}{
+		params.Query, queryLike, queryLike,
+		params.Genre, genreLike,
+		params.Status, params.Status,
+	}
+
+	var total int
+	if err := p.DB.QueryRow(`SELECT COUNT(*) FROM manga `+where, args...).Scan(&total); err != nil {
+		log.Printf("Error counting local manga: %v", err)
+		return nil, 0, errors.New("failed to query manga")
+	}
+
+	rows, err := p.DB.Query(
+		`SELECT id, title, author, genres, status, total_chapters, description, cover_url
+		FROM manga `+where+` ORDER BY title LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		log.Printf("Error querying local manga: %v", err)
+		return nil, 0, errors.New("failed to query manga")
+	}
+	defer rows.Close()
+
+	mangas := []models.Manga{}
+	for rows.Next() {
+		var m models.Manga
+		var genresJSON string
+		if err := rows.Scan(&m.ID, &m.Title, &m.Author, &genresJSON, &m.Status, &m.TotalChapters, &m.Description, &m.CoverURL); err != nil {
+			log.Printf("Error scanning local manga row: %v", err)
+			continue
+		}
+		m.Genres = parseGenres(genresJSON)
+		mangas = append(mangas, m)
+	}
+	return mangas, total, nil
+}
+
+// Get looks up a manga by its stored ID. Returns sql.ErrNoRows if absent.
+func (p *LocalProvider) Get(id string) (*models.Manga, error) {
+	var m models.Manga
+	var genresJSON string
+	err := p.DB.QueryRow(
+		`SELECT id, title, author, genres, status, total_chapters, description, cover_url
+		FROM manga WHERE id = ?`,
+		id,
+	).Scan(&m.ID, &m.Title, &m.Author, &genresJSON, &m.Status, &m.TotalChapters, &m.Description, &m.CoverURL)
+	if err != nil {
+		return nil, err
+	}
+	m.Genres = parseGenres(genresJSON)
+	return &m, nil
+}
+
+func (p *LocalProvider) ChapterCount(id string) (int, error) {
+	var total int
+	err := p.DB.QueryRow(`SELECT total_chapters FROM manga WHERE id = ?`, id).Scan(&total)
+	return total, err
+}
+
+// Tags returns every genre used by locally stored manga.
+func (p *LocalProvider) Tags() ([]string, error) {
+	rows, err := p.DB.Query(`SELECT DISTINCT genres FROM manga WHERE genres IS NOT NULL AND genres != ''`)
+	if err != nil {
+		log.Printf("Error querying local genres: %v", err)
+		return nil, errors.New("failed to query tags")
+	}
+	defer rows.Close()
+
+	var lists [][]string
+	for rows.Next() {
+		var genresJSON string
+		if err := rows.Scan(&genresJSON); err != nil {
+			continue
+		}
+		lists = append(lists, parseGenres(genresJSON))
+	}
+	return mergeTags(lists...), nil
+}
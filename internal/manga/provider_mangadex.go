@@ -0,0 +1,237 @@
+package manga
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"mangahub/internal/mangadex"
+	"mangahub/pkg/models"
+)
+
+// MangaDexProvider serves metadata from the MangaDex API.
+type MangaDexProvider struct {
+	Client *mangadex.Client
+}
+
+func (p *MangaDexProvider) Name() string { return "mangadex" }
+
+// OwnsID claims bare MangaDex UUIDs so legacy unprefixed IDs keep working.
+func (p *MangaDexProvider) OwnsID(id string) bool { return isUUID(id) }
+
+func (p *MangaDexProvider) Degraded() bool { return p.Client.Degraded() }
+
+// upstreamError marks errors from an unreachable MangaDex as
+// ErrUpstreamUnavailable.
+func upstreamError(err error) error {
+	if errors.Is(err, mangadex.ErrUnavailable) {
+		return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+	return err
+}
+
+// Search queries MangaDex. Genre filtering is done client-side because
+// MangaDex only accepts tag IDs, not names.
+func (p *MangaDexProvider) Search(params SearchParams) (*SearchResult, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	log.Printf("[Manga] Fetching from MangaDex: query=%s, genre=%s, status=%s, limit=%d, offset=%d",
+		params.Query, params.Genre, params.Status, params.Limit, offset)
+
+	// If filtering by genre, we need to fetch larger batches and filter client-side
+	// because MangaDex doesn't accept genre names, only tag IDs
+	hasGenreFilter := params.Genre != ""
+
+	var allMangas []models.Manga
+	var mangadexTotal int
+	batchSize := 100
+	maxBatches := 10 // Safety limit
+	currentOffset := 0
+	totalFetched := 0
+	totalFiltered := 0
+
+	if hasGenreFilter {
+		// Fetch multiple batches to get enough filtered results
+		// Increase max batches for rare genres - fetch up to 50 batches (5000 manga) if needed
+		maxBatches = 50
+		targetCount := offset + params.Limit
+
+		// Also try partial matching in addition to exact match
+		genreLower := strings.ToLower(params.Genre)
+
+		for len(allMangas) < targetCount && currentOffset < maxBatches*batchSize {
+			mdResp, err := p.Client.SearchManga(params.Query, "", params.Status, batchSize, currentOffset)
+			if err != nil {
+				log.Printf("[Manga] Error fetching batch from MangaDex: %v", err)
+				if currentOffset == 0 {
+					return nil, upstreamError(err)
+				}
+				break
+			}
+
+			if mangadexTotal == 0 && mdResp.Total > 0 {
+				mangadexTotal = mdResp.Total
+			}
+
+			// Transform and filter by genre
+			batchFiltered := 0
+			for _, mdManga := range mdResp.Data {
+				manga := mangadex.TransformMangaDexToManga(&mdManga, p.Client, false) // false = don't use aggregate for list views
+				if manga != nil {
+					totalFetched++
+					// Filter by genre (case-insensitive, also try partial match)
+					genreMatch := false
+					for _, g := range manga.Genres {
+						gLower := strings.ToLower(g)
+						// Exact match or contains match
+						if strings.EqualFold(g, params.Genre) || strings.Contains(gLower, genreLower) || strings.Contains(genreLower, gLower) {
+							genreMatch = true
+							break
+						}
+					}
+					if genreMatch {
+						allMangas = append(allMangas, *manga)
+						totalFiltered++
+						batchFiltered++
+					}
+				}
+			}
+
+			// Log progress every 5 batches
+			if (currentOffset/batchSize+1)%5 == 0 {
+				log.Printf("[Manga] Genre filter progress: fetched %d batches (%d manga), found %d matching '%s'",
+					currentOffset/batchSize+1, totalFetched, totalFiltered, params.Genre)
+			}
+
+			// If we've fetched enough batches but still no matches, log sample genres
+			if currentOffset >= 500 && totalFiltered == 0 {
+				// Sample a few manga to see what genres we're getting
+				if len(mdResp.Data) > 0 {
+					sample := mangadex.TransformMangaDexToManga(&mdResp.Data[0], p.Client, false) // false = don't use aggregate
+					if sample != nil && len(sample.Genres) > 0 {
+						sampleCount := 3
+						if len(sample.Genres) < sampleCount {
+							sampleCount = len(sample.Genres)
+						}
+						log.Printf("[Manga] Sample genres found (looking for '%s'): %v", params.Genre, sample.Genres[:sampleCount])
+					}
+				}
+			}
+
+			if len(mdResp.Data) < batchSize {
+				break // No more data
+			}
+			currentOffset += batchSize
+
+			// If we've found enough for pagination and have a good sample, we can stop early
+			if len(allMangas) >= targetCount && totalFetched >= 1000 {
+				break
+			}
+		}
+
+		log.Printf("[Manga] Genre filter complete: fetched %d manga, found %d matching '%s'", totalFetched, totalFiltered, params.Genre)
+
+		// Calculate estimated total based on filter ratio
+		var estimatedTotal int
+		if mangadexTotal > 0 && totalFetched > 0 {
+			filterRatio := float64(totalFiltered) / float64(totalFetched)
+			estimatedTotal = int(float64(mangadexTotal) * filterRatio)
+		} else {
+			estimatedTotal = len(allMangas)
+		}
+
+		// Paginate filtered results
+		var paginatedMangas []models.Manga
+		if offset < len(allMangas) {
+			end := offset + params.Limit
+			if end > len(allMangas) {
+				end = len(allMangas)
+			}
+			paginatedMangas = allMangas[offset:end]
+		} else {
+			paginatedMangas = []models.Manga{}
+		}
+
+		// If no results found, log a warning
+		if len(paginatedMangas) == 0 && totalFiltered == 0 {
+			log.Printf("[Manga] Warning: No manga found matching genre '%s' after fetching %d manga", params.Genre, totalFetched)
+		}
+
+		totalPages := (estimatedTotal + params.Limit - 1) / params.Limit
+		return &SearchResult{
+			Data:       paginatedMangas,
+			Total:      estimatedTotal,
+			Page:       params.Page,
+			Limit:      params.Limit,
+			TotalPages: totalPages,
+		}, nil
+	}
+
+	// No genre filter - direct fetch
+	mdResp, err := p.Client.SearchManga(params.Query, "", params.Status, params.Limit, offset)
+	if err != nil {
+		log.Printf("[Manga] Error fetching from MangaDex: %v", err)
+		return nil, upstreamError(err)
+	}
+
+	log.Printf("[Manga] MangaDex returned %d results, total: %d", len(mdResp.Data), mdResp.Total)
+
+	// Transform and cache results
+	var mangas []models.Manga
+	for _, mdManga := range mdResp.Data {
+		manga := mangadex.TransformMangaDexToManga(&mdManga, p.Client, false) // false = don't use aggregate for list views
+		if manga != nil {
+			mangas = append(mangas, *manga)
+		}
+	}
+
+	total := mdResp.Total
+	if total == 0 {
+		total = len(mangas) // Fallback if MangaDex doesn't provide total
+	}
+
+	totalPages := (total + params.Limit - 1) / params.Limit
+	return &SearchResult{
+		Data:       mangas,
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// Get fetches a manga by MangaDex UUID, using the aggregate endpoint for an
+// accurate chapter count.
+func (p *MangaDexProvider) Get(id string) (*models.Manga, error) {
+	log.Printf("Manga %s not in local DB, fetching from MangaDex...", id)
+	mdManga, err := p.Client.GetMangaByID(id)
+	if err != nil {
+		log.Printf("Failed to fetch from MangaDex: %v", err)
+		return nil, upstreamError(err)
+	}
+	manga := mangadex.TransformMangaDexToManga(mdManga, p.Client, true) // true = use aggregate for detail pages
+	if manga == nil {
+		return nil, errors.New("not_found")
+	}
+	return manga, nil
+}
+
+func (p *MangaDexProvider) ChapterCount(id string) (int, error) {
+	return p.Client.GetChapterCount(id)
+}
+
+func (p *MangaDexProvider) Tags() ([]string, error) {
+	return p.Client.GetTags()
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	// Check for hyphens at positions 8, 13, 18, 23
+	if len(s) >= 36 && s[8] == '-' && s[13] == '-' && s[18] == '-' && s[23] == '-' {
+		return true
+	}
+	return false
+}
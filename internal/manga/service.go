@@ -12,20 +12,29 @@ import (
 	"mangahub/pkg/models"
 )
 
-// Service contains core manga data management logic.
+// Service contains core manga data management logic. Metadata comes from the
+// local database plus any registered remote providers (MangaDex by default).
 type Service struct {
 	DB          *sql.DB
 	MangaDex    *mangadex.Client
 	UseMangaDex bool
+
+	local     *LocalProvider
+	providers []Provider // remote providers, in priority order
 }
 
 func NewService(db *sql.DB) *Service {
 	useMangaDex := os.Getenv("MANGAHUB_USE_MANGADEX")
-	return &Service{
+	s := &Service{
 		DB:          db,
 		MangaDex:    mangadex.NewClient(),
 		UseMangaDex: useMangaDex != "false",
+		local:       &LocalProvider{DB: db},
+	}
+	if s.UseMangaDex {
+		s.RegisterProvider(&MangaDexProvider{Client: s.MangaDex})
 	}
+	return s
 }
 
 // SearchParams holds search and filter parameters.
@@ -44,8 +53,20 @@ type SearchResult struct {
 	Page       int
 	Limit      int
 	TotalPages int
+	Degraded   bool // true when a remote provider was unavailable and results come from the local DB
 }
 
+// ErrUpstreamUnavailable is returned when a manga is not cached locally and
+// its provider cannot be reached.
+var ErrUpstreamUnavailable = errors.New("upstream_unavailable")
+
+// SearchManga queries every available remote provider and merges their
+// results with locally stored manga. The merged list is paged as one: remote
+// results come first, followed by local manga that no answering provider
+// owns, so Total is the remote total plus those local rows. If no remote
+// provider answers, local results are returned and flagged as degraded;
+// a provider that answers with an error only degrades the result when it
+// could not be reached.
 func (s *Service) SearchManga(params SearchParams) (*SearchResult, error) {
 	// Validate and set defaults
 	if params.Page < 1 {
@@ -55,178 +76,118 @@ func (s *Service) SearchManga(params SearchParams) (*SearchResult, error) {
 		params.Limit = 20
 	}
 
-	if !s.UseMangaDex {
-		return nil, errors.New("mangadex integration disabled")
-	}
-
-	return s.searchMangaDexAndCache(params)
-}
-
-func (s *Service) searchMangaDexAndCache(params SearchParams) (*SearchResult, error) {
-	offset := (params.Page - 1) * params.Limit
-
-	log.Printf("[Manga] Fetching from MangaDex: query=%s, genre=%s, status=%s, limit=%d, offset=%d",
-		params.Query, params.Genre, params.Status, params.Limit, offset)
-
-	// If filtering by genre, we need to fetch larger batches and filter client-side
-	// because MangaDex doesn't accept genre names, only tag IDs
-	hasGenreFilter := params.Genre != ""
-
-	var allMangas []models.Manga
-	var mangadexTotal int
-	batchSize := 100
-	maxBatches := 10 // Safety limit
-	currentOffset := 0
-	totalFetched := 0
-	totalFiltered := 0
-
-	if hasGenreFilter {
-		// Fetch multiple batches to get enough filtered results
-		// Increase max batches for rare genres - fetch up to 50 batches (5000 manga) if needed
-		maxBatches = 50
-		targetCount := offset + params.Limit
-
-		// Also try partial matching in addition to exact match
-		genreLower := strings.ToLower(params.Genre)
-
-		for len(allMangas) < targetCount && currentOffset < maxBatches*batchSize {
-			mdResp, err := s.MangaDex.SearchManga(params.Query, "", params.Status, batchSize, currentOffset)
-			if err != nil {
-				log.Printf("[Manga] Error fetching batch from MangaDex: %v", err)
-				break
-			}
-
-			if mangadexTotal == 0 && mdResp.Total > 0 {
-				mangadexTotal = mdResp.Total
-			}
-
-			// Transform and filter by genre
-			batchFiltered := 0
-			for _, mdManga := range mdResp.Data {
-				manga := mangadex.TransformMangaDexToManga(&mdManga, s.MangaDex, false) // false = don't use aggregate for list views
-				if manga != nil {
-					totalFetched++
-					// Filter by genre (case-insensitive, also try partial match)
-					genreMatch := false
-					for _, g := range manga.Genres {
-						gLower := strings.ToLower(g)
-						// Exact match or contains match
-						if strings.EqualFold(g, params.Genre) || strings.Contains(gLower, genreLower) || strings.Contains(genreLower, gLower) {
-							genreMatch = true
-							break
-						}
-					}
-					if genreMatch {
-						allMangas = append(allMangas, *manga)
-						totalFiltered++
-						batchFiltered++
-					}
-				}
-			}
-
-			// Log progress every 5 batches
-			if (currentOffset/batchSize+1)%5 == 0 {
-				log.Printf("[Manga] Genre filter progress: fetched %d batches (%d manga), found %d matching '%s'",
-					currentOffset/batchSize+1, totalFetched, totalFiltered, params.Genre)
-			}
-
-			// If we've fetched enough batches but still no matches, log sample genres
-			if currentOffset >= 500 && totalFiltered == 0 {
-				// Sample a few manga to see what genres we're getting
-				if len(mdResp.Data) > 0 {
-					sample := mangadex.TransformMangaDexToManga(&mdResp.Data[0], s.MangaDex, false) // false = don't use aggregate
-					if sample != nil && len(sample.Genres) > 0 {
-						sampleCount := 3
-						if len(sample.Genres) < sampleCount {
-							sampleCount = len(sample.Genres)
-						}
-						log.Printf("[Manga] Sample genres found (looking for '%s'): %v", params.Genre, sample.Genres[:sampleCount])
-					}
-				}
-			}
-
-			if len(mdResp.Data) < batchSize {
-				break // No more data
-			}
-			currentOffset += batchSize
-
-			// If we've found enough for pagination and have a good sample, we can stop early
-			if len(allMangas) >= targetCount && totalFetched >= 1000 {
-				break
-			}
-		}
-
-		log.Printf("[Manga] Genre filter complete: fetched %d manga, found %d matching '%s'", totalFetched, totalFiltered, params.Genre)
-
-		// Calculate estimated total based on filter ratio
-		var estimatedTotal int
-		if mangadexTotal > 0 && totalFetched > 0 {
-			filterRatio := float64(totalFiltered) / float64(totalFetched)
-			estimatedTotal = int(float64(mangadexTotal) * filterRatio)
-		} else {
-			estimatedTotal = len(allMangas)
+	var remote []*SearchResult
+	answered := make(map[string]bool)
+	degraded := false
+	for _, p := range s.providers {
+		// Degraded mode: while a provider's circuit is open, don't wait on it.
+		if providerDegraded(p) {
+			log.Printf("[Manga] Provider %s degraded, skipping", p.Name())
+			degraded = true
+			continue
 		}
-
-		// Paginate filtered results
-		var paginatedMangas []models.Manga
-		if offset < len(allMangas) {
-			end := offset + params.Limit
-			if end > len(allMangas) {
-				end = len(allMangas)
+		result, err := p.Search(params)
+		if err != nil {
+			log.Printf("[Manga] Provider %s search failed: %v", p.Name(), err)
+			if providerDegraded(p) || errors.Is(err, ErrUpstreamUnavailable) {
+				degraded = true
 			}
-			paginatedMangas = allMangas[offset:end]
-		} else {
-			paginatedMangas = []models.Manga{}
+			continue
 		}
+		remote = append(remote, result)
+		answered[p.Name()] = true
+	}
 
-		// If no results found, log a warning
-		if len(paginatedMangas) == 0 && totalFiltered == 0 {
-			log.Printf("[Manga] Warning: No manga found matching genre '%s' after fetching %d manga", params.Genre, totalFetched)
+	if len(remote) == 0 {
+		local, err := s.local.Search(params)
+		if err != nil {
+			return nil, err
 		}
-
-		totalPages := (estimatedTotal + params.Limit - 1) / params.Limit
-		return &SearchResult{
-			Data:       paginatedMangas,
-			Total:      estimatedTotal,
-			Page:       params.Page,
-			Limit:      params.Limit,
-			TotalPages: totalPages,
-		}, nil
+		local.Degraded = degraded
+		return local, nil
 	}
 
-	// No genre filter - direct fetch
-	mdResp, err := s.MangaDex.SearchManga(params.Query, "", params.Status, params.Limit, offset)
-	if err != nil {
-		log.Printf("[Manga] Error fetching from MangaDex: %v", err)
-		return nil, err
+	// Remote providers are authoritative for their own IDs; only local rows
+	// that no answering provider owns (locally created manga, or cached rows
+	// from a provider that is down) are merged in, after every remote result.
+	matches, _ := s.local.matches(params) // logged; serve the remote results alone
+	var localOnly []models.Manga
+	for _, m := range matches {
+		if provider, _ := s.ParseID(m.ID); !answered[provider] {
+			localOnly = append(localOnly, m)
+		}
 	}
-
-	log.Printf("[Manga] MangaDex returned %d results, total: %d", len(mdResp.Data), mdResp.Total)
-
-	// Transform and cache results
-	var mangas []models.Manga
-	for _, mdManga := range mdResp.Data {
-		manga := mangadex.TransformMangaDexToManga(&mdManga, s.MangaDex, false) // false = don't use aggregate for list views
-		if manga != nil {
-			mangas = append(mangas, *manga)
+	localOnly = mergeResults(localOnly)
+
+	lists := make([][]models.Manga, 0, len(remote))
+	remoteTotal := 0
+	for _, r := range remote {
+		lists = append(lists, r.Data)
+		if r.Total > remoteTotal {
+			remoteTotal = r.Total
 		}
 	}
-
-	total := mdResp.Total
-	if total == 0 {
-		total = len(mangas) // Fallback if MangaDex doesn't provide total
+	page := mergeResults(lists...)
+	if len(page) > params.Limit {
+		page = page[:params.Limit]
 	}
 
-	totalPages := (total + params.Limit - 1) / params.Limit
+	// The local rows on this page are those whose position in the merged
+	// list, after the remoteTotal remote results, falls inside it.
+	offset := (params.Page - 1) * params.Limit
+	start := clamp(offset-remoteTotal, 0, len(localOnly))
+	end := clamp(offset+params.Limit-remoteTotal, 0, len(localOnly))
+	page = mergeResults(page, localOnly[start:end])
+
+	total := remoteTotal + len(localOnly)
 	return &SearchResult{
-		Data:       mangas,
+		Data:       page,
 		Total:      total,
 		Page:       params.Page,
 		Limit:      params.Limit,
-		TotalPages: totalPages,
+		TotalPages: (total + params.Limit - 1) / params.Limit,
+		Degraded:   degraded,
 	}, nil
 }
 
+// clamp limits n to [lo, hi].
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// Degraded reports whether any remote provider is currently unavailable.
+func (s *Service) Degraded() bool {
+	for _, p := range s.providers {
+		if providerDegraded(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTags returns the union of tags/genres across all providers.
+func (s *Service) GetTags() ([]string, error) {
+	var lists [][]string
+	for _, p := range append([]Provider{s.local}, s.providers...) {
+		if providerDegraded(p) {
+			continue
+		}
+		tags, err := p.Tags()
+		if err != nil {
+			log.Printf("[Manga] Provider %s tags failed: %v", p.Name(), err)
+			continue
+		}
+		lists = append(lists, tags)
+	}
+	return mergeTags(lists...), nil
+}
+
 // cacheManga stores a manga in the local database for future queries
 func (s *Service) cacheManga(m *models.Manga) {
 	genresJSON, err := json.Marshal(m.Genres)
@@ -245,88 +206,47 @@ func (s *Service) cacheManga(m *models.Manga) {
 	}
 }
 
-func isUUID(s string) bool {
-	if len(s) != 36 {
-		return false
-	}
-	// Check for hyphens at positions 8, 13, 18, 23
-	if len(s) >= 36 && s[8] == '-' && s[13] == '-' && s[18] == '-' && s[23] == '-' {
-		return true
-	}
-	return false
-}
-
+// GetMangaByID returns a manga from the local cache, or fetches it from the
+// provider that owns the ID and caches it.
 func (s *Service) GetMangaByID(id string) (*models.Manga, error) {
-	var m models.Manga
-	var genresJSON string
-	err := s.DB.QueryRow(
-		`SELECT id, title, author, genres, status, total_chapters, description, cover_url 
-		FROM manga WHERE id = ?`,
-		id,
-	).Scan(&m.ID, &m.Title, &m.Author, &genresJSON, &m.Status, &m.TotalChapters, &m.Description, &m.CoverURL)
-
+	m, err := s.local.Get(id)
 	if err == nil {
-		m.Genres = parseGenres(genresJSON)
-		return &m, nil
+		return m, nil
 	}
-
 	if err != sql.ErrNoRows {
 		log.Printf("Error querying manga: %v", err)
 		return nil, errors.New("failed to query manga")
 	}
 
-	if isUUID(id) {
-		prefixedID := "mangadex-" + id
-		err := s.DB.QueryRow(
-			`SELECT id, title, author, genres, status, total_chapters, description, cover_url 
-			FROM manga WHERE id = ?`,
-			prefixedID,
-		).Scan(&m.ID, &m.Title, &m.Author, &genresJSON, &m.Status, &m.TotalChapters, &m.Description, &m.CoverURL)
+	providerName, nativeID := s.ParseID(id)
+	if providerName == LocalProviderName {
+		return nil, errors.New("not_found")
+	}
 
+	// Bare IDs claimed by a provider may already be cached under their
+	// qualified form.
+	qualified := QualifyID(providerName, nativeID)
+	if qualified != id {
+		m, err := s.local.Get(qualified)
 		if err == nil {
-			m.Genres = parseGenres(genresJSON)
-			return &m, nil
+			return m, nil
 		}
-
 		if err != sql.ErrNoRows {
 			log.Printf("Error querying manga with prefixed ID: %v", err)
 			return nil, errors.New("failed to query manga")
 		}
+	}
 
-		if s.UseMangaDex {
-			log.Printf("Manga %s not in local DB, fetching from MangaDex...", id)
-			mdManga, err := s.MangaDex.GetMangaByID(id)
-			if err != nil {
-				log.Printf("Failed to fetch from MangaDex: %v", err)
-				return nil, errors.New("not_found")
-			}
-
-			// Transform and cache the manga (use aggregate for detail page)
-			manga := mangadex.TransformMangaDexToManga(mdManga, s.MangaDex, true) // true = use aggregate for detail pages
-			if manga != nil {
-				s.cacheManga(manga)
-				return manga, nil
-			}
-		}
-	} else if strings.HasPrefix(id, "mangadex-") && s.UseMangaDex {
-		// ID has "mangadex-" prefix, extract the actual MangaDex ID
-		mangaDexID := strings.TrimPrefix(id, "mangadex-")
-		log.Printf("Manga %s not in local DB, fetching from MangaDex...", mangaDexID)
-		mdManga, err := s.MangaDex.GetMangaByID(mangaDexID)
-		if err != nil {
-			log.Printf("Failed to fetch from MangaDex: %v", err)
-			return nil, errors.New("not_found")
-		}
-
-		// Transform and cache the manga (use aggregate for detail page)
-		manga := mangadex.TransformMangaDexToManga(mdManga, s.MangaDex, true) // true = use aggregate for detail pages
-		if manga != nil {
-			s.cacheManga(manga)
-			return manga, nil
+	p := s.provider(providerName)
+	m, err = p.Get(nativeID)
+	if err != nil {
+		if providerDegraded(p) || errors.Is(err, ErrUpstreamUnavailable) {
+			return nil, ErrUpstreamUnavailable
 		}
+		return nil, errors.New("not_found")
 	}
-
-	return nil, errors.New("not_found")
+	s.cacheManga(m)
+	return m, nil
 }
 
 // MangaWithProgress combines manga details with user progress.
@@ -0,0 +1,131 @@
+package manga
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"mangahub/internal/database"
+	"mangahub/pkg/models"
+)
+
+// fakeProvider is a remote provider serving total manga titled "Remote NNN",
+// or failing every call with err.
+type fakeProvider struct {
+	total    int
+	err      error
+	degraded bool
+}
+
+func (p *fakeProvider) Name() string   { return "fake" }
+func (p *fakeProvider) Degraded() bool { return p.degraded }
+
+func (p *fakeProvider) Search(params SearchParams) (*SearchResult, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	data := []models.Manga{}
+	for i := (params.Page - 1) * params.Limit; i < p.total && len(data) < params.Limit; i++ {
+		data = append(data, models.Manga{ID: QualifyID(p.Name(), fmt.Sprint(i)), Title: fmt.Sprintf("Remote %03d", i)})
+	}
+	return &SearchResult{Data: data, Total: p.total, Page: params.Page, Limit: params.Limit}, nil
+}
+
+func (p *fakeProvider) Get(id string) (*models.Manga, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return nil, errors.New("not_found")
+}
+
+func (p *fakeProvider) ChapterCount(id string) (int, error) { return 0, errors.New("not implemented") }
+func (p *fakeProvider) Tags() ([]string, error)             { return nil, nil }
+
+// newTestService returns a service over a fresh database holding local
+// manga titled "Local NNN" and p as its only remote provider.
+func newTestService(t *testing.T, p Provider, local int) *Service {
+	t.Helper()
+	t.Setenv("MANGAHUB_USE_MANGADEX", "false")
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`DELETE FROM manga`); err != nil {
+		t.Fatalf("clear manga: %v", err)
+	}
+	for i := 0; i < local; i++ {
+		if _, err := db.Exec(
+			`INSERT INTO manga (id, title, author, genres, status, total_chapters, description, cover_url)
+			VALUES (?, ?, '', '[]', 'ongoing', 1, '', '')`,
+			fmt.Sprintf("local-%03d", i), fmt.Sprintf("Local %03d", i),
+		); err != nil {
+			t.Fatalf("insert manga: %v", err)
+		}
+	}
+	s := NewService(db)
+	s.RegisterProvider(p)
+	return s
+}
+
+func TestSearchPagesMergedResultsAsOneList(t *testing.T) {
+	s := newTestService(t, &fakeProvider{total: 25}, 7)
+
+	var titles []string
+	for page := 1; page <= 4; page++ {
+		result, err := s.SearchManga(SearchParams{Page: page, Limit: 10})
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if result.Total != 32 || result.TotalPages != 4 {
+			t.Fatalf("page %d: total %d in %d pages, want 32 in 4", page, result.Total, result.TotalPages)
+		}
+		if len(result.Data) > 10 {
+			t.Fatalf("page %d: %d results, want at most 10", page, len(result.Data))
+		}
+		for _, m := range result.Data {
+			titles = append(titles, m.Title)
+		}
+	}
+
+	if len(titles) != 32 {
+		t.Fatalf("%d results across all pages, want 32", len(titles))
+	}
+	seen := make(map[string]bool)
+	for i, title := range titles {
+		if seen[title] {
+			t.Fatalf("%q listed twice", title)
+		}
+		seen[title] = true
+		if i < 25 && title != fmt.Sprintf("Remote %03d", i) || i >= 25 && title != fmt.Sprintf("Local %03d", i-25) {
+			t.Fatalf("result %d is %q, want remote results first, then local", i, title)
+		}
+	}
+}
+
+func TestSearchDegradedOnlyWhenProviderUnreachable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		degraded bool
+	}{
+		{"unreachable", fmt.Errorf("%w: fetch failed", ErrUpstreamUnavailable), true},
+		{"bad request", errors.New("MangaDex API error: 400"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, &fakeProvider{err: tt.err}, 3)
+			result, err := s.SearchManga(SearchParams{})
+			if err != nil {
+				t.Fatalf("SearchManga: %v", err)
+			}
+			if result.Degraded != tt.degraded {
+				t.Fatalf("Degraded = %v, want %v", result.Degraded, tt.degraded)
+			}
+			if len(result.Data) != 3 {
+				t.Fatalf("%d results, want the 3 local manga", len(result.Data))
+			}
+		})
+	}
+}
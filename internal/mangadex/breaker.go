@@ -0,0 +1,137 @@
+package mangadex
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is wrapped by the errors of requests MangaDex could not
+// serve: unreachable, 5xx and 429 responses, or refused by the open circuit.
+var ErrUnavailable = errors.New("mangadex_unavailable")
+
+// ErrCircuitOpen is returned without contacting MangaDex while the circuit
+// breaker is open.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit open", ErrUnavailable)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker trips after threshold consecutive failures. While open every
+// call fails fast; after cooldown a single probe is let through (half-open)
+// and its outcome decides whether to close or re-open the circuit.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a request may be sent upstream.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		log.Printf("[MangaDex] Circuit half-open, probing upstream")
+		return nil
+	case stateHalfOpen:
+		// Only one probe at a time; everyone else keeps failing fast.
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != stateClosed {
+		log.Printf("[MangaDex] Circuit closed, upstream recovered")
+	}
+	b.state = stateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		if b.state != stateOpen {
+			log.Printf("[MangaDex] Circuit opened after %d failure(s)", b.failures)
+		}
+		b.state = stateOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+func (b *circuitBreaker) currentState() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Degraded reports whether the MangaDex circuit is not fully closed, i.e.
+// callers should expect to be served from local data.
+func (c *Client) Degraded() bool {
+	return c.breaker.currentState() != stateClosed
+}
+
+// CircuitState returns "closed", "open" or "half_open".
+func (c *Client) CircuitState() string {
+	return c.breaker.currentState().String()
+}
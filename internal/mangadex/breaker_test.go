@@ -0,0 +1,103 @@
+package mangadex
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreakerStateTransitions(t *testing.T) {
+	b := newCircuitBreaker(2, time.Minute)
+	step := func(name string, op func(), want breakerState) {
+		t.Helper()
+		op()
+		if got := b.currentState(); got != want {
+			t.Fatalf("%s: state %s, want %s", name, got, want)
+		}
+	}
+	cooledDown := func() { b.openedAt = time.Now().Add(-b.cooldown) }
+
+	step("first failure", b.failure, stateClosed)
+	step("success resets the count", b.success, stateClosed)
+	step("failure", b.failure, stateClosed)
+	step("threshold reached", b.failure, stateOpen)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open: %v, want %v", err, ErrCircuitOpen)
+	}
+
+	step("cooldown over", cooledDown, stateOpen)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	step("probe sent", func() {}, stateHalfOpen)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request while probing: %v, want %v", err, ErrCircuitOpen)
+	}
+	step("probe failed", b.failure, stateOpen)
+
+	cooledDown()
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	step("probe succeeded", b.success, stateClosed)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after recovery: %v", err)
+	}
+}
+
+func TestDoCountsUnavailableResponsesAsFailures(t *testing.T) {
+	tests := []struct {
+		status      int
+		unavailable bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, false},
+		{http.StatusBadRequest, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			c := &Client{httpClient: srv.Client(), breaker: newCircuitBreaker(1, time.Minute)}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if got := errors.Is(err, ErrUnavailable); got != tt.unavailable {
+				t.Fatalf("do: %v, want unavailable %v", err, tt.unavailable)
+			}
+			if c.Degraded() != tt.unavailable {
+				t.Fatalf("circuit %s after %d, want open %v", c.CircuitState(), tt.status, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestDoFailsFastWhileOpen(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := &Client{httpClient: srv.Client(), breaker: newCircuitBreaker(1, time.Minute)}
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if _, err := c.do(req); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("request %d: %v, want %v", i, err, ErrUnavailable)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("%d request(s) reached MangaDex, want only the one that tripped the circuit", requests)
+	}
+}
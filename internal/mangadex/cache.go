@@ -0,0 +1,217 @@
+package mangadex
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default cache sizing and TTLs. Search pages change often (new uploads, sort
+// order), manga details rarely, aggregates only when a chapter is released.
+const (
+	defaultCacheSize    = 1000
+	defaultSearchTTL    = 2 * time.Minute
+	defaultMangaTTL     = 15 * time.Minute
+	defaultAggregateTTL = 10 * time.Minute
+	defaultTagsTTL      = time.Hour
+)
+
+// CacheStats is a snapshot of the response cache counters.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"` // requests that waited on an identical in-flight call
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Capacity  int    `json:"capacity"`
+}
+
+type cacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// responseCache is a bounded LRU cache with a per-entry TTL.
+type responseCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List // front = most recently used
+	items    map[string]*list.Element
+
+	hits      uint64
+	misses    uint64
+	coalesced uint64
+	evictions uint64
+}
+
+func newResponseCache(capacity int) *responseCache {
+	if capacity <= 0 {
+		capacity = defaultCacheSize
+	}
+	return &responseCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached value for key if present and not expired.
+func (c *responseCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		c.misses++
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits++
+	return entry.value, true
+}
+
+// set stores value under key for ttl, evicting the least recently used entry
+// when the cache is full.
+func (c *responseCache) set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+func (c *responseCache) recordCoalesced() {
+	c.mu.Lock()
+	c.coalesced++
+	c.mu.Unlock()
+}
+
+func (c *responseCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Coalesced: c.coalesced,
+		Evictions: c.evictions,
+		Entries:   c.ll.Len(),
+		Capacity:  c.capacity,
+	}
+}
+
+// flightCall is an in-flight or completed call shared by concurrent callers.
+type flightCall struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// flightGroup deduplicates concurrent calls with the same key so only one
+// upstream request is made (singleflight).
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// errFlightPanicked is what callers waiting on a call get if fn panicked.
+var errFlightPanicked = errors.New("mangadex: shared call panicked")
+
+// do executes fn once for all concurrent callers sharing key. shared reports
+// whether the result came from another caller's execution. If fn panics, the
+// panic goes on in the caller that ran it and the waiters get an error.
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (val interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.val, call.err, true
+	}
+	call := &flightCall{err: errFlightPanicked}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.val, call.err = fn()
+	return call.val, call.err, false
+}
+
+// cached serves key from the response cache, otherwise runs fetch once for all
+// concurrent callers and caches a successful result for ttl. The value is
+// shared by every caller until it expires, so it must not be modified.
+func (c *Client) cached(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if v, ok := c.cache.get(key); ok {
+		return v, nil
+	}
+
+	v, err, shared := c.flight.do(key, func() (interface{}, error) {
+		v, err := fetch()
+		if err == nil {
+			c.cache.set(key, v, ttl)
+		}
+		return v, err
+	})
+	if shared {
+		c.cache.recordCoalesced()
+	}
+	return v, err
+}
+
+// CacheStats returns hit/miss counters for the response cache.
+func (c *Client) CacheStats() CacheStats {
+	return c.cache.stats()
+}
+
+// envDuration reads a Go duration (e.g. "90s", "5m") from key, or returns def.
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
+
+// envInt reads a positive integer from key, or returns def.
+func envInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
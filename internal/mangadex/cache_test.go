@@ -0,0 +1,147 @@
+package mangadex
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name      string
+		ops       []string // "set k" or "get k"
+		want      []string // keys still cached
+		evictions uint64
+	}{
+		{"under capacity", []string{"set a", "set b"}, []string{"a", "b"}, 0},
+		{"oldest evicted", []string{"set a", "set b", "set c"}, []string{"b", "c"}, 1},
+		{"get refreshes", []string{"set a", "set b", "get a", "set c"}, []string{"a", "c"}, 1},
+		{"set refreshes", []string{"set a", "set b", "set a", "set c"}, []string{"a", "c"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newResponseCache(2)
+			for _, op := range tt.ops {
+				key := op[len("set "):]
+				if op[:3] == "set" {
+					c.set(key, key, time.Hour)
+				} else {
+					c.get(key)
+				}
+			}
+			for _, key := range tt.want {
+				if v, ok := c.get(key); !ok || v != key {
+					t.Errorf("get(%q) = %v, %v; want it cached", key, v, ok)
+				}
+			}
+			if st := c.stats(); st.Entries != len(tt.want) || st.Evictions != tt.evictions {
+				t.Errorf("%d entries and %d evictions, want %d and %d", st.Entries, st.Evictions, len(tt.want), tt.evictions)
+			}
+		})
+	}
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		hit  bool
+	}{
+		{"fresh", time.Hour, true},
+		{"expired", -time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newResponseCache(2)
+			c.set("a", 1, tt.ttl)
+			if _, ok := c.get("a"); ok != tt.hit {
+				t.Fatalf("get after ttl %v: hit = %v, want %v", tt.ttl, ok, tt.hit)
+			}
+			st := c.stats()
+			if tt.hit != (st.Hits == 1) || tt.hit == (st.Misses == 1) {
+				t.Fatalf("%d hits and %d misses", st.Hits, st.Misses)
+			}
+			if !tt.hit && st.Entries != 0 {
+				t.Fatalf("%d entries, want the expired one dropped", st.Entries)
+			}
+		})
+	}
+}
+
+// joinCall starts n callers of g.do(key) that must share a call already in
+// flight, and returns what each of them got.
+func joinCall(t *testing.T, g *flightGroup, key string, n int) <-chan error {
+	t.Helper()
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			v, err, shared := g.do(key, func() (interface{}, error) {
+				return nil, errors.New("ran again")
+			})
+			if err == nil && (!shared || v != "value") {
+				err = errors.New("not shared")
+			}
+			errs <- err
+		}()
+	}
+	// Give the callers time to reach the call in flight.
+	time.Sleep(50 * time.Millisecond)
+	return errs
+}
+
+func TestFlightCoalescesConcurrentCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func() (interface{}, error)
+		waitErr error
+	}{
+		{"result", func() (interface{}, error) { return "value", nil }, nil},
+		{"panic", func() (interface{}, error) { panic("boom") }, errFlightPanicked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g flightGroup
+			var runs atomic.Int32
+			release := make(chan struct{})
+			started := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { _ = recover() }()
+				g.do("k", func() (interface{}, error) {
+					runs.Add(1)
+					close(started)
+					<-release
+					return tt.fn()
+				})
+			}()
+			<-started
+
+			const waiters = 5
+			errs := joinCall(t, &g, "k", waiters)
+			close(release)
+			wg.Wait()
+			for i := 0; i < waiters; i++ {
+				select {
+				case err := <-errs:
+					if !errors.Is(err, tt.waitErr) {
+						t.Fatalf("waiter got %v, want %v", err, tt.waitErr)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("waiter still blocked after the call finished")
+				}
+			}
+			if n := runs.Load(); n != 1 {
+				t.Fatalf("call ran %d times, want once", n)
+			}
+
+			// The finished call is forgotten; the next one runs again.
+			if v, _, shared := g.do("k", func() (interface{}, error) { return "next", nil }); shared || v != "next" {
+				t.Fatalf("call after completion got %v (shared %v), want a fresh run", v, shared)
+			}
+		})
+	}
+}
@@ -19,18 +19,73 @@ const MANGADEX_BASE = "https://api.mangadex.org"
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
+
+	// Response cache and request coalescing for hot lookups.
+	cache        *responseCache
+	flight       flightGroup
+	searchTTL    time.Duration
+	mangaTTL     time.Duration
+	aggregateTTL time.Duration
+	tagsTTL      time.Duration
+
+	breaker *circuitBreaker
 }
 
-// NewClient creates a new MangaDex client
+// NewClient creates a new MangaDex client.
+// Cache behaviour can be tuned with environment variables:
+// - MANGAHUB_MANGADEX_CACHE_SIZE (max entries, default 1000)
+// - MANGAHUB_MANGADEX_SEARCH_TTL (default 2m)
+// - MANGAHUB_MANGADEX_MANGA_TTL (default 15m)
+// - MANGAHUB_MANGADEX_AGGREGATE_TTL (default 10m)
+//
+// Upstream failure handling:
+// - MANGAHUB_MANGADEX_TIMEOUT (per-request timeout, default 10s)
+// - MANGAHUB_MANGADEX_BREAKER_THRESHOLD (consecutive failures before tripping, default 5)
+// - MANGAHUB_MANGADEX_BREAKER_COOLDOWN (time before a recovery probe, default 30s)
 func NewClient() *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: envDuration("MANGAHUB_MANGADEX_TIMEOUT", 10*time.Second),
 		},
-		baseURL: MANGADEX_BASE,
+		baseURL:      MANGADEX_BASE,
+		cache:        newResponseCache(envInt("MANGAHUB_MANGADEX_CACHE_SIZE", defaultCacheSize)),
+		searchTTL:    envDuration("MANGAHUB_MANGADEX_SEARCH_TTL", defaultSearchTTL),
+		mangaTTL:     envDuration("MANGAHUB_MANGADEX_MANGA_TTL", defaultMangaTTL),
+		aggregateTTL: envDuration("MANGAHUB_MANGADEX_AGGREGATE_TTL", defaultAggregateTTL),
+		tagsTTL:      defaultTagsTTL,
+		breaker: newCircuitBreaker(
+			envInt("MANGAHUB_MANGADEX_BREAKER_THRESHOLD", defaultBreakerThreshold),
+			envDuration("MANGAHUB_MANGADEX_BREAKER_COOLDOWN", defaultBreakerCooldown),
+		),
 	}
 }
 
+// do sends req through the circuit breaker. Transport errors, 5xx and 429
+// responses count as failures and are returned as errors wrapping
+// ErrUnavailable; anything else (including 404) counts as success.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	// Rate limiting: wait 200ms between requests
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.breaker.failure()
+		return nil, fmt.Errorf("%w: fetch failed: %w", ErrUnavailable, err)
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		c.breaker.failure()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: MangaDex API error: %d - %s", ErrUnavailable, resp.StatusCode, string(body))
+	}
+	c.breaker.success()
+	return resp, nil
+}
+
 // MangaDexManga represents a manga from MangaDex API
 type MangaDexManga struct {
 	ID         string `json:"id"`
@@ -66,8 +121,21 @@ type MangaDexResponse struct {
 	Offset   int             `json:"offset"`
 }
 
-// SearchManga searches MangaDex for manga
+// SearchManga searches MangaDex for manga. Identical concurrent searches share
+// one upstream request and results are cached for the search TTL; the
+// response is shared with other callers and must be treated as read-only.
 func (c *Client) SearchManga(query, genre, status string, limit, offset int) (*MangaDexResponse, error) {
+	key := fmt.Sprintf("search:%s|%s|%s|%d|%d", query, genre, strings.ToLower(status), limit, offset)
+	v, err := c.cached(key, c.searchTTL, func() (interface{}, error) {
+		return c.searchManga(query, genre, status, limit, offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*MangaDexResponse), nil
+}
+
+func (c *Client) searchManga(query, genre, status string, limit, offset int) (*MangaDexResponse, error) {
 	params := url.Values{}
 	params.Add("limit", fmt.Sprintf("%d", limit))
 	params.Add("offset", fmt.Sprintf("%d", offset))
@@ -101,12 +169,9 @@ func (c *Client) SearchManga(query, genre, status string, limit, offset int) (*M
 	req.Header.Set("User-Agent", "MangaHub/1.0 (Net Centric Project)")
 	req.Header.Set("Accept", "application/json")
 
-	// Rate limiting: wait 200ms between requests
-	time.Sleep(200 * time.Millisecond)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetch failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -124,8 +189,19 @@ func (c *Client) SearchManga(query, genre, status string, limit, offset int) (*M
 	return &result, nil
 }
 
-// GetMangaByID fetches a single manga from MangaDex by ID
+// GetMangaByID fetches a single manga from MangaDex by ID (cached and
+// coalesced). The result is shared with other callers and must not be modified.
 func (c *Client) GetMangaByID(mangaID string) (*MangaDexManga, error) {
+	v, err := c.cached("manga:"+mangaID, c.mangaTTL, func() (interface{}, error) {
+		return c.getMangaByID(mangaID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*MangaDexManga), nil
+}
+
+func (c *Client) getMangaByID(mangaID string) (*MangaDexManga, error) {
 	params := url.Values{}
 	params.Add("includes[]", "cover_art")
 	params.Add("includes[]", "author")
@@ -143,12 +219,9 @@ func (c *Client) GetMangaByID(mangaID string) (*MangaDexManga, error) {
 	req.Header.Set("User-Agent", "MangaHub/1.0 (Net Centric Project)")
 	req.Header.Set("Accept", "application/json")
 
-	// Rate limiting: wait 200ms between requests
-	time.Sleep(200 * time.Millisecond)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetch failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -194,6 +267,16 @@ type AggregateResponse struct {
 // GetChapterCount fetches the highest chapter number from the aggregate endpoint
 // This avoids language filter issues and gives accurate chapter counts
 func (c *Client) GetChapterCount(mangaID string) (int, error) {
+	v, err := c.cached("aggregate:"+mangaID, c.aggregateTTL, func() (interface{}, error) {
+		return c.getChapterCount(mangaID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+func (c *Client) getChapterCount(mangaID string) (int, error) {
 	reqURL := fmt.Sprintf("%s/manga/%s/aggregate", c.baseURL, mangaID)
 
 	log.Printf("[MangaDex] Fetching chapter count from aggregate: %s", reqURL)
@@ -206,12 +289,9 @@ func (c *Client) GetChapterCount(mangaID string) (int, error) {
 	req.Header.Set("User-Agent", "MangaHub/1.0 (Net Centric Project)")
 	req.Header.Set("Accept", "application/json")
 
-	// Rate limiting: wait 200ms between requests
-	time.Sleep(200 * time.Millisecond)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return 0, fmt.Errorf("fetch failed: %w", err)
+		return 0, err
 	}
 	defer resp.Body.Close()
 
@@ -255,6 +335,137 @@ func (c *Client) GetChapterCount(mangaID string) (int, error) {
 	return 0, nil
 }
 
+// Chapter is a single chapter entry from a manga feed.
+type Chapter struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Chapter            string `json:"chapter"`
+		Title              string `json:"title"`
+		TranslatedLanguage string `json:"translatedLanguage"`
+		PublishAt          string `json:"publishAt"`
+	} `json:"attributes"`
+}
+
+// GetLatestChapter returns the highest-numbered chapter in the manga's feed,
+// optionally restricted to the given translated languages. Returns nil if the
+// feed has no chapters. Not cached: callers poll this to detect releases.
+func (c *Client) GetLatestChapter(mangaID string, languages []string) (*Chapter, error) {
+	params := url.Values{}
+	params.Add("limit", "1")
+	params.Add("order[chapter]", "desc")
+	params.Add("contentRating[]", "safe")
+	params.Add("contentRating[]", "suggestive")
+	params.Add("contentRating[]", "erotica")
+	for _, lang := range languages {
+		params.Add("translatedLanguage[]", lang)
+	}
+
+	reqURL := fmt.Sprintf("%s/manga/%s/feed?%s", c.baseURL, mangaID, params.Encode())
+
+	log.Printf("[MangaDex] Fetching latest chapter: %s", reqURL)
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "MangaHub/1.0 (Net Centric Project)")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("manga not found")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("MangaDex API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Result string    `json:"result"`
+		Data   []Chapter `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	if result.Result != "ok" {
+		return nil, fmt.Errorf("MangaDex API error: result is not ok")
+	}
+	if len(result.Data) == 0 {
+		return nil, nil
+	}
+	return &result.Data[0], nil
+}
+
+// tagResponse represents the MangaDex /manga/tag endpoint response
+type tagResponse struct {
+	Result string `json:"result"`
+	Data   []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Name  map[string]string `json:"name"`
+			Group string            `json:"group"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// GetTags returns the English names of all MangaDex tags (cached). The slice
+// is shared with other callers and must not be modified.
+func (c *Client) GetTags() ([]string, error) {
+	v, err := c.cached("tags", c.tagsTTL, func() (interface{}, error) {
+		return c.getTags()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+func (c *Client) getTags() ([]string, error) {
+	reqURL := fmt.Sprintf("%s/manga/tag", c.baseURL)
+
+	log.Printf("[MangaDex] Fetching tags: %s", reqURL)
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "MangaHub/1.0 (Net Centric Project)")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("MangaDex API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result tagResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	tags := make([]string, 0, len(result.Data))
+	for _, t := range result.Data {
+		if name := t.Attributes.Name["en"]; name != "" {
+			tags = append(tags, name)
+		}
+	}
+	return tags, nil
+}
+
 func TransformMangaDexToManga(md *MangaDexManga, client *Client, useAggregate bool) *models.Manga {
 	if md == nil || md.Attributes.Title == nil {
 		return nil
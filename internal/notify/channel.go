@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"mangahub/internal/udp"
+)
+
+// Channel names accepted in preferences.
+const (
+	ChannelUDP     = "udp"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Recipient is the user a batch of notifications is delivered to, with the
+// addresses their preferences resolve to.
+type Recipient struct {
+	UserID        string
+	Email         string
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// Channel delivers notifications to a user. A batch holds a single
+// notification for immediate delivery, or several for a digest or for
+// notifications held back during quiet hours.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, batch []udp.Notification) error
+}
+
+// UDPChannel pushes notifications to the user's registered UDP devices.
+type UDPChannel struct {
+	Server *udp.Server
+}
+
+func NewUDPChannel(s *udp.Server) *UDPChannel {
+	return &UDPChannel{Server: s}
+}
+
+func (c *UDPChannel) Name() string { return ChannelUDP }
+
+func (c *UDPChannel) Send(ctx context.Context, to Recipient, batch []udp.Notification) error {
+	for _, n := range batch {
+		if err := c.Server.DeliverToUser(to.UserID, n); err != nil {
+			return fmt.Errorf("udp deliver: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"mangahub/internal/udp"
+)
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+	DigestOff    = "off"
+)
+
+// DigestSchedule is when a user receives their digest of new chapters for
+// the manga they follow (notification subscriptions and library entries
+// marked "reading").
+type DigestSchedule struct {
+	Frequency string `json:"frequency"`          // daily | weekly | off
+	Hour      int    `json:"hour"`               // local hour 0-23
+	Weekday   string `json:"weekday,omitempty"`  // weekly only, e.g. "monday"
+	Timezone  string `json:"timezone,omitempty"` // IANA name, default UTC
+
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+}
+
+func parseWeekday(v string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(v, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", v)
+}
+
+func (s *DigestSchedule) validate() error {
+	switch s.Frequency {
+	case DigestDaily, DigestOff:
+	case DigestWeekly:
+		if _, err := parseWeekday(s.Weekday); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("frequency must be %s, %s or %s", DigestDaily, DigestWeekly, DigestOff)
+	}
+	if s.Hour < 0 || s.Hour > 23 {
+		return errors.New("hour must be between 0 and 23")
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", s.Timezone)
+		}
+	}
+	return nil
+}
+
+// next returns the first scheduled time strictly after t.
+func (s *DigestSchedule) next(t time.Time) time.Time {
+	loc := time.UTC
+	if s.Timezone != "" {
+		if l, err := time.LoadLocation(s.Timezone); err == nil {
+			loc = l
+		}
+	}
+	local := t.In(loc)
+	run := time.Date(local.Year(), local.Month(), local.Day(), s.Hour, 0, 0, 0, loc)
+
+	if s.Frequency == DigestWeekly {
+		wd, _ := parseWeekday(s.Weekday)
+		run = run.AddDate(0, 0, (int(wd)-int(run.Weekday())+7)%7)
+		if !run.After(t) {
+			run = run.AddDate(0, 0, 7)
+		}
+		return run
+	}
+	if !run.After(t) {
+		run = run.AddDate(0, 0, 1)
+	}
+	return run
+}
+
+// window is how far back the first digest looks.
+func (s *DigestSchedule) window() time.Duration {
+	if s.Frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// DigestSchedule returns the user's digest schedule; users without one
+// have frequency "off".
+func (d *Dispatcher) DigestSchedule(userID string) (*DigestSchedule, error) {
+	var s DigestSchedule
+	var weekday int
+	var tz sql.NullString
+	var lastSent sql.NullInt64
+	var nextRun int64
+	err := d.DB.QueryRow(
+		`SELECT frequency, hour, weekday, timezone, last_sent_at, next_run_at
+		FROM digest_schedules WHERE user_id = ?`,
+		userID,
+	).Scan(&s.Frequency, &s.Hour, &weekday, &tz, &lastSent, &nextRun)
+	if err == sql.ErrNoRows {
+		return &DigestSchedule{Frequency: DigestOff, Hour: 8}, nil
+	}
+	if err != nil {
+		log.Printf("Error querying digest schedule: %v", err)
+		return nil, errors.New("database_error: failed to load digest schedule")
+	}
+
+	s.Timezone = tz.String
+	if s.Frequency == DigestWeekly {
+		s.Weekday = strings.ToLower(time.Weekday(weekday).String())
+	}
+	if lastSent.Valid {
+		t := time.Unix(lastSent.Int64, 0).UTC()
+		s.LastSentAt = &t
+	}
+	if s.Frequency != DigestOff {
+		t := time.Unix(nextRun, 0).UTC()
+		s.NextRunAt = &t
+	}
+	return &s, nil
+}
+
+// SaveDigestSchedule validates and stores the user's digest schedule.
+func (d *Dispatcher) SaveDigestSchedule(userID string, s *DigestSchedule) error {
+	s.Frequency = strings.ToLower(s.Frequency)
+	if err := s.validate(); err != nil {
+		return fmt.Errorf("validation_error: %w", err)
+	}
+
+	var weekday time.Weekday
+	if s.Frequency == DigestWeekly {
+		weekday, _ = parseWeekday(s.Weekday)
+		s.Weekday = strings.ToLower(weekday.String())
+	} else {
+		s.Weekday = ""
+	}
+	next := s.next(time.Now())
+	s.NextRunAt = nil
+	if s.Frequency != DigestOff {
+		t := next.UTC()
+		s.NextRunAt = &t
+	}
+
+	_, err := d.DB.Exec(
+		`INSERT INTO digest_schedules (user_id, frequency, hour, weekday, timezone, next_run_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			frequency = excluded.frequency,
+			hour = excluded.hour,
+			weekday = excluded.weekday,
+			timezone = excluded.timezone,
+			next_run_at = excluded.next_run_at,
+			updated_at = CURRENT_TIMESTAMP`,
+		userID, s.Frequency, s.Hour, int(weekday), s.Timezone, next.Unix(),
+	)
+	if err != nil {
+		log.Printf("Error saving digest schedule: %v", err)
+		return errors.New("database_error: failed to save digest schedule")
+	}
+	return nil
+}
+
+// digestActive reports whether the user receives digests instead of
+// per-chapter alerts.
+func (d *Dispatcher) digestActive(userID string) bool {
+	var active bool
+	err := d.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM digest_schedules WHERE user_id = ? AND frequency != ?)`,
+		userID, DigestOff,
+	).Scan(&active)
+	return err == nil && active
+}
+
+// inDigest reports whether n is a release recorded in chapter_releases, the
+// only source digests are built from.
+func (d *Dispatcher) inDigest(n udp.Notification) bool {
+	var recorded bool
+	err := d.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM chapter_releases WHERE manga_id = ? AND CAST(chapter AS INTEGER) = ?)`,
+		n.MangaID, n.Chapter,
+	).Scan(&recorded)
+	return err == nil && recorded
+}
+
+// MigrateLegacyDigests gives users who saved the old "digest" preference,
+// and never set a digest schedule, a daily schedule at LegacyDigestHour.
+func (d *Dispatcher) MigrateLegacyDigests() {
+	rows, err := d.DB.Query(
+		`SELECT user_id, prefs FROM notification_preferences
+		WHERE user_id NOT IN (SELECT user_id FROM digest_schedules)`,
+	)
+	if err != nil {
+		log.Printf("[Digest] query legacy preferences: %v", err)
+		return
+	}
+	var users []string
+	for rows.Next() {
+		var id, raw string
+		var p Preferences
+		if rows.Scan(&id, &raw) == nil && json.Unmarshal([]byte(raw), &p) == nil && p.Digest {
+			users = append(users, id)
+		}
+	}
+	rows.Close()
+
+	for _, userID := range users {
+		if err := d.adoptLegacyDigest(userID); err != nil {
+			log.Printf("[Digest] %s: %v", userID, err)
+		}
+	}
+}
+
+// adoptLegacyDigest turns the old "digest" preference into a daily schedule
+// unless the user already has a schedule.
+func (d *Dispatcher) adoptLegacyDigest(userID string) error {
+	var exists bool
+	err := d.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM digest_schedules WHERE user_id = ?)`, userID).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	log.Printf("[Digest] migrating digest preference of %s to a daily schedule at %02d:00 UTC", userID, d.LegacyDigestHour)
+	return d.SaveDigestSchedule(userID, &DigestSchedule{Frequency: DigestDaily, Hour: d.LegacyDigestHour})
+}
+
+// DigestScheduler sends every due digest once per Interval.
+type DigestScheduler struct {
+	Dispatcher *Dispatcher
+	Interval   time.Duration
+}
+
+func NewDigestScheduler(d *Dispatcher) *DigestScheduler {
+	return &DigestScheduler{Dispatcher: d, Interval: time.Minute}
+}
+
+// Run sends due digests until ctx is cancelled.
+func (s *DigestScheduler) Run(ctx context.Context) {
+	s.Dispatcher.MigrateLegacyDigests()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunDue(time.Now())
+		}
+	}
+}
+
+// RunDue sends the digests scheduled at or before now.
+func (s *DigestScheduler) RunDue(now time.Time) {
+	rows, err := s.Dispatcher.DB.Query(
+		`SELECT user_id FROM digest_schedules WHERE frequency != ? AND next_run_at <= ?`,
+		DigestOff, now.Unix(),
+	)
+	if err != nil {
+		log.Printf("[Digest] query schedules: %v", err)
+		return
+	}
+	var users []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			users = append(users, id)
+		}
+	}
+	rows.Close()
+
+	for _, userID := range users {
+		if err := s.runUser(userID, now); err != nil {
+			log.Printf("[Digest] %s: %v", userID, err)
+		}
+	}
+}
+
+// runUser sends one user's digest covering the releases since the previous
+// digest and schedules the next one.
+func (s *DigestScheduler) runUser(userID string, now time.Time) error {
+	d := s.Dispatcher
+	sched, err := d.DigestSchedule(userID)
+	if err != nil {
+		return err
+	}
+
+	since := now.Add(-sched.window())
+	if sched.LastSentAt != nil {
+		since = *sched.LastSentAt
+	}
+	batch, err := s.releases(userID, since, now)
+	if err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		held, err := d.SendDigest(userID, sched.Frequency, batch)
+		if !held.IsZero() {
+			// Quiet hours: try again once they end, keeping the window open.
+			_, err := d.DB.Exec(`UPDATE digest_schedules SET next_run_at = ? WHERE user_id = ?`, held.Unix(), userID)
+			return err
+		}
+		if err != nil {
+			log.Printf("[Digest] %s: partial delivery: %v", userID, err)
+		}
+		log.Printf("[Digest] sent %s digest with %d chapter(s) to %s", sched.Frequency, len(batch), userID)
+	}
+
+	_, err = d.DB.Exec(
+		`UPDATE digest_schedules SET last_sent_at = ?, next_run_at = ? WHERE user_id = ?`,
+		now.Unix(), sched.next(now).Unix(), userID,
+	)
+	return err
+}
+
+// releases returns the chapters released in (since, until] for manga the
+// user follows or is reading.
+func (s *DigestScheduler) releases(userID string, since, until time.Time) ([]udp.Notification, error) {
+	rows, err := s.Dispatcher.DB.Query(
+		`SELECT manga_id, title, chapter, chapter_label, released_at FROM chapter_releases
+		WHERE released_at > ? AND released_at <= ? AND manga_id IN (
+			SELECT manga_id FROM user_notifications WHERE user_id = ?
+			UNION
+			SELECT manga_id FROM user_progress WHERE user_id = ? AND status = 'reading'
+		)
+		ORDER BY released_at, manga_id`,
+		since.Unix(), until.Unix(), userID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query releases: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []udp.Notification
+	for rows.Next() {
+		var n udp.Notification
+		var chapter float64
+		var title, label sql.NullString
+		if err := rows.Scan(&n.MangaID, &title, &chapter, &label, &n.Timestamp); err != nil {
+			continue
+		}
+		n.Type = "chapter_release"
+		n.Title = title.String
+		if n.Title == "" {
+			n.Title = n.MangaID
+		}
+		n.Chapter = int(chapter)
+		chapterLabel := label.String
+		if chapterLabel == "" {
+			chapterLabel = fmt.Sprint(n.Chapter)
+		}
+		n.Message = fmt.Sprintf("Chapter %s of %s is out!", chapterLabel, n.Title)
+		batch = append(batch, n)
+	}
+	return batch, rows.Err()
+}
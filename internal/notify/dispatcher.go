@@ -0,0 +1,339 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mangahub/internal/udp"
+)
+
+// Held deliveries that keep failing are dropped after maxQueueAttempts.
+const (
+	maxQueueAttempts = 5
+	queueRetryDelay  = 5 * time.Minute
+	sendTimeout      = 30 * time.Second
+)
+
+// Dispatcher routes each notification to the channels a recipient chose,
+// holding deliveries back during quiet hours. It implements udp.Dispatcher.
+type Dispatcher struct {
+	DB            *sql.DB
+	Channels      map[string]Channel
+	FlushInterval time.Duration
+
+	// LegacyDigestHour is the UTC hour of the daily digest schedule given to
+	// users who saved the old "digest" preference.
+	LegacyDigestHour int
+
+	// Deliveries started by Dispatch; see Close.
+	mu          sync.Mutex
+	closed      bool
+	sending     sync.WaitGroup
+	sendCtx     context.Context
+	cancelSends context.CancelFunc
+}
+
+// NewDispatcher creates a Dispatcher over channels, configured from
+// environment variables:
+// - MANGAHUB_NOTIFY_DIGEST_HOUR (UTC hour 0-23 for migrated "digest"
+// preferences, default 8)
+func NewDispatcher(db *sql.DB, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{
+		DB:               db,
+		Channels:         make(map[string]Channel),
+		FlushInterval:    time.Minute,
+		LegacyDigestHour: 8,
+	}
+	d.sendCtx, d.cancelSends = context.WithCancel(context.Background())
+	for _, ch := range channels {
+		d.Channels[ch.Name()] = ch
+	}
+	if v := os.Getenv("MANGAHUB_NOTIFY_DIGEST_HOUR"); v != "" {
+		if h, err := strconv.Atoi(v); err == nil && h >= 0 && h < 24 {
+			d.LegacyDigestHour = h
+		}
+	}
+	return d
+}
+
+// Dispatch delivers n to userID according to their preferences. Users with
+// an active digest schedule get no per-chapter alerts for chapters the
+// release detector recorded; those reach them in their next digest instead.
+// Anything else, such as an admin-published release, is delivered as usual.
+func (d *Dispatcher) Dispatch(userID string, n udp.Notification) {
+	if n.Type == "chapter_release" && d.digestActive(userID) && d.inDigest(n) {
+		return
+	}
+
+	prefs, err := d.Preferences(userID)
+	if err != nil {
+		prefs = DefaultPreferences()
+	}
+
+	now := time.Now()
+	quietUntil := prefs.QuietHours.until(now)
+
+	for _, name := range prefs.channelsFor(n.MangaID) {
+		ch, ok := d.Channels[name]
+		if !ok {
+			continue
+		}
+		if !quietUntil.IsZero() {
+			d.enqueue(userID, name, n, "quiet_hours", quietUntil)
+			continue
+		}
+		to := d.recipient(userID, prefs)
+		if !d.begin() {
+			log.Printf("[Notify] dispatcher closed, dropped %s delivery to %s", name, userID)
+			return
+		}
+		go func() {
+			defer d.sending.Done()
+			if err := d.send(ch, to, []udp.Notification{n}); err != nil {
+				log.Printf("[Notify] %s delivery to %s failed: %v", ch.Name(), userID, err)
+			}
+		}()
+	}
+}
+
+// begin registers a delivery started by Dispatch; it reports false once the
+// dispatcher is closed. Callers that get true must call d.sending.Done.
+func (d *Dispatcher) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+	d.sending.Add(1)
+	return true
+}
+
+// Close stops Dispatch from starting deliveries and waits for those in
+// progress. When ctx expires first, every send still running is cancelled
+// and ctx's error is returned without waiting for them. It is safe to call
+// more than once.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.sending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.cancelSends()
+		return ctx.Err()
+	}
+}
+
+// SendDigest delivers a digest of new chapters over the user's default
+// channels: email and webhooks receive the whole batch, UDP devices a single
+// summary notification. During quiet hours nothing is sent and the time the
+// window ends is returned so the caller can retry then.
+func (d *Dispatcher) SendDigest(userID, period string, batch []udp.Notification) (time.Time, error) {
+	prefs, err := d.Preferences(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if until := prefs.QuietHours.until(time.Now()); !until.IsZero() {
+		return until, nil
+	}
+
+	to := d.recipient(userID, prefs)
+	var firstErr error
+	for _, name := range prefs.Channels {
+		ch, ok := d.Channels[name]
+		if !ok {
+			continue
+		}
+		items := batch
+		if name == ChannelUDP {
+			items = []udp.Notification{digestSummary(period, batch)}
+		}
+		if err := d.send(ch, to, items); err != nil {
+			log.Printf("[Notify] %s digest to %s failed: %v", name, userID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// digestSummary condenses a digest into one notification for UDP devices.
+func digestSummary(period string, batch []udp.Notification) udp.Notification {
+	parts := make([]string, 0, len(batch))
+	for _, n := range batch {
+		parts = append(parts, fmt.Sprintf("%s ch.%d", n.Title, n.Chapter))
+	}
+	return udp.Notification{
+		Type:      "digest",
+		Title:     fmt.Sprintf("Your %s digest", period),
+		Message:   fmt.Sprintf("%d new chapter(s): %s", len(batch), strings.Join(parts, ", ")),
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// Run flushes held deliveries every FlushInterval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.flush()
+		}
+	}
+}
+
+type queuedItem struct {
+	id       int64
+	userID   string
+	channel  string
+	n        udp.Notification
+	attempts int
+}
+
+// flush sends every held delivery that is due, one batch per user and
+// channel.
+func (d *Dispatcher) flush() {
+	now := time.Now()
+	rows, err := d.DB.Query(
+		`SELECT id, user_id, channel, payload, attempts FROM notification_queue
+		WHERE deliver_after <= ? ORDER BY id`,
+		now.Unix(),
+	)
+	if err != nil {
+		log.Printf("[Notify] query queue: %v", err)
+		return
+	}
+
+	type groupKey struct{ userID, channel string }
+	groups := make(map[groupKey][]queuedItem)
+	var order []groupKey
+	for rows.Next() {
+		var it queuedItem
+		var payload string
+		if err := rows.Scan(&it.id, &it.userID, &it.channel, &payload, &it.attempts); err != nil {
+			continue
+		}
+		if err := json.Unmarshal([]byte(payload), &it.n); err != nil {
+			continue
+		}
+		key := groupKey{it.userID, it.channel}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], it)
+	}
+	rows.Close()
+
+	for _, key := range order {
+		items := groups[key]
+		prefs, err := d.Preferences(key.userID)
+		if err != nil {
+			continue
+		}
+
+		// Quiet hours may have started since the item was queued.
+		if until := prefs.QuietHours.until(now); !until.IsZero() {
+			d.reschedule(items, until, false)
+			continue
+		}
+
+		ch, ok := d.Channels[key.channel]
+		if !ok {
+			d.remove(items)
+			continue
+		}
+
+		batch := make([]udp.Notification, 0, len(items))
+		for _, it := range items {
+			batch = append(batch, it.n)
+		}
+		if err := d.send(ch, d.recipient(key.userID, prefs), batch); err != nil {
+			log.Printf("[Notify] %s delivery of %d held notification(s) to %s failed: %v",
+				key.channel, len(batch), key.userID, err)
+			d.reschedule(items, now.Add(queueRetryDelay), true)
+			continue
+		}
+		d.remove(items)
+	}
+}
+
+func (d *Dispatcher) send(ch Channel, to Recipient, batch []udp.Notification) error {
+	ctx, cancel := context.WithTimeout(d.sendCtx, sendTimeout)
+	defer cancel()
+	return ch.Send(ctx, to, batch)
+}
+
+// recipient resolves the addresses for userID.
+func (d *Dispatcher) recipient(userID string, prefs *Preferences) Recipient {
+	to := Recipient{
+		UserID:        userID,
+		Email:         prefs.Email,
+		WebhookURL:    prefs.WebhookURL,
+		WebhookSecret: prefs.WebhookSecret,
+	}
+	if to.Email == "" {
+		_ = d.DB.QueryRow(`SELECT email FROM users WHERE id = ?`, userID).Scan(&to.Email)
+	}
+	return to
+}
+
+func (d *Dispatcher) enqueue(userID, channel string, n udp.Notification, reason string, at time.Time) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return
+	}
+	_, err = d.DB.Exec(
+		`INSERT INTO notification_queue (user_id, channel, payload, reason, deliver_after)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, channel, string(payload), reason, at.Unix(),
+	)
+	if err != nil {
+		log.Printf("[Notify] enqueue for %s: %v", userID, err)
+	}
+}
+
+// reschedule moves items to at. Failed attempts are counted and items that
+// keep failing are dropped.
+func (d *Dispatcher) reschedule(items []queuedItem, at time.Time, failed bool) {
+	for _, it := range items {
+		if failed && it.attempts+1 >= maxQueueAttempts {
+			log.Printf("[Notify] dropping %s notification for %s after %d attempts", it.channel, it.userID, it.attempts+1)
+			_, _ = d.DB.Exec(`DELETE FROM notification_queue WHERE id = ?`, it.id)
+			continue
+		}
+		attempts := it.attempts
+		if failed {
+			attempts++
+		}
+		_, _ = d.DB.Exec(
+			`UPDATE notification_queue SET deliver_after = ?, attempts = ? WHERE id = ?`,
+			at.Unix(), attempts, it.id,
+		)
+	}
+}
+
+func (d *Dispatcher) remove(items []queuedItem) {
+	for _, it := range items {
+		_, _ = d.DB.Exec(`DELETE FROM notification_queue WHERE id = ?`, it.id)
+	}
+}
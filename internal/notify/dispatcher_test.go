@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mangahub/internal/database"
+	"mangahub/internal/udp"
+)
+
+// blockingChannel stands in for the UDP channel; each Send blocks until
+// release is closed or the send is cancelled.
+type blockingChannel struct {
+	started   chan struct{}
+	release   chan struct{}
+	sends     atomic.Int32
+	cancelled atomic.Int32
+}
+
+func newBlockingChannel() *blockingChannel {
+	return &blockingChannel{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (c *blockingChannel) Name() string { return ChannelUDP }
+
+func (c *blockingChannel) Send(ctx context.Context, to Recipient, batch []udp.Notification) error {
+	c.sends.Add(1)
+	c.started <- struct{}{}
+	select {
+	case <-c.release:
+		return nil
+	case <-ctx.Done():
+		c.cancelled.Add(1)
+		return ctx.Err()
+	}
+}
+
+func newTestDispatcher(t *testing.T, ch Channel) *Dispatcher {
+	t.Helper()
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewDispatcher(db, ch)
+}
+
+func release(chapter int) udp.Notification {
+	return udp.Notification{Type: "chapter_release", MangaID: "one-piece", Chapter: chapter}
+}
+
+func TestCloseWaitsForDeliveries(t *testing.T) {
+	ch := newBlockingChannel()
+	d := newTestDispatcher(t, ch)
+
+	d.Dispatch("user_a", release(1))
+	<-ch.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- d.Close(context.Background()) }()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v with a delivery in progress", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(ch.release)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the delivery finished")
+	}
+
+	d.Dispatch("user_a", release(2))
+	if n := ch.sends.Load(); n != 1 {
+		t.Fatalf("%d send(s) after Close, want only the one before", n)
+	}
+}
+
+func TestCloseCancelsDeliveriesWhenContextExpires(t *testing.T) {
+	ch := newBlockingChannel()
+	d := newTestDispatcher(t, ch)
+
+	d.Dispatch("user_a", release(1))
+	<-ch.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ch.cancelled.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the delivery in progress was not cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
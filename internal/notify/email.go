@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"mangahub/internal/udp"
+)
+
+// Mailer sends a plain-text email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends mail through an SMTP server, using PLAIN auth when a
+// username is configured.
+type SMTPMailer struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var a smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("smtp addr: %w", err)
+		}
+		a = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	msg := "From: " + m.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	return smtp.SendMail(m.Addr, a, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer is a local stand-in that writes emails to the log instead of
+// sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, body string) error {
+	log.Printf("[Mail] to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
+
+// MailerFromEnv returns an SMTPMailer configured from environment variables,
+// or a LogMailer when MANGAHUB_SMTP_ADDR is unset:
+// - MANGAHUB_SMTP_ADDR (host:port)
+// - MANGAHUB_SMTP_FROM (default "MangaHub <no-reply@mangahub.local>")
+// - MANGAHUB_SMTP_USERNAME / MANGAHUB_SMTP_PASSWORD
+func MailerFromEnv() Mailer {
+	addr := os.Getenv("MANGAHUB_SMTP_ADDR")
+	if addr == "" {
+		return LogMailer{}
+	}
+	from := os.Getenv("MANGAHUB_SMTP_FROM")
+	if from == "" {
+		from = "MangaHub <no-reply@mangahub.local>"
+	}
+	return &SMTPMailer{
+		Addr:     addr,
+		From:     from,
+		Username: os.Getenv("MANGAHUB_SMTP_USERNAME"),
+		Password: os.Getenv("MANGAHUB_SMTP_PASSWORD"),
+	}
+}
+
+// EmailChannel sends notifications by email.
+type EmailChannel struct {
+	Mailer Mailer
+}
+
+func NewEmailChannel(m Mailer) *EmailChannel {
+	return &EmailChannel{Mailer: m}
+}
+
+func (c *EmailChannel) Name() string { return ChannelEmail }
+
+func (c *EmailChannel) Send(ctx context.Context, to Recipient, batch []udp.Notification) error {
+	if to.Email == "" {
+		return errors.New("no email address")
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var subject string
+	var body strings.Builder
+	if len(batch) == 1 {
+		n := batch[0]
+		subject = fmt.Sprintf("%s: chapter %d is out", n.Title, n.Chapter)
+		body.WriteString(n.Message + "\n")
+	} else {
+		subject = fmt.Sprintf("Your MangaHub digest: %d new chapter(s)", len(batch))
+		for _, n := range batch {
+			fmt.Fprintf(&body, "- %s, chapter %d: %s\n", n.Title, n.Chapter, n.Message)
+		}
+	}
+	body.WriteString("\nManage notifications in your MangaHub settings.\n")
+
+	return c.Mailer.Send(to.Email, subject, body.String())
+}
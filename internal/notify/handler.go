@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	Dispatcher *Dispatcher
+}
+
+// RegisterRoutes registers the notification preference and digest schedule
+// endpoints.
+func RegisterRoutes(r *gin.RouterGroup, d *Dispatcher) {
+	h := &Handler{Dispatcher: d}
+
+	r.GET("/users/notification-preferences", h.HandleGetPreferences)
+	r.PUT("/users/notification-preferences", h.HandleUpdatePreferences)
+	r.GET("/users/digest-schedule", h.HandleGetDigestSchedule)
+	r.PUT("/users/digest-schedule", h.HandleUpdateDigestSchedule)
+}
+
+// HandleGetPreferences returns the user's notification preferences and the
+// channels they can choose from.
+func (h *Handler) HandleGetPreferences(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	prefs, err := h.Dispatcher.Preferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+
+	available := make([]string, 0, len(h.Dispatcher.Channels))
+	for _, name := range []string{ChannelUDP, ChannelEmail, ChannelWebhook} {
+		if _, ok := h.Dispatcher.Channels[name]; ok {
+			available = append(available, name)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences":        prefs,
+		"available_channels": available,
+	})
+}
+
+// HandleUpdatePreferences replaces the user's notification preferences.
+func (h *Handler) HandleUpdatePreferences(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var prefs Preferences
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"type":  "validation_error",
+		})
+		return
+	}
+
+	if err := h.Dispatcher.SavePreferences(userID, &prefs); err != nil {
+		msg := err.Error()
+		if strings.HasPrefix(msg, "validation_error: ") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": strings.TrimPrefix(msg, "validation_error: "),
+				"type":  "validation_error",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Notification preferences updated",
+		"preferences": prefs,
+	})
+}
+
+// HandleGetDigestSchedule returns the user's digest schedule.
+func (h *Handler) HandleGetDigestSchedule(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	sched, err := h.Dispatcher.DigestSchedule(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, sched)
+}
+
+// HandleUpdateDigestSchedule sets how often the user receives digests.
+// While a digest is active, per-chapter alerts are not sent.
+func (h *Handler) HandleUpdateDigestSchedule(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var sched DigestSchedule
+	if err := c.ShouldBindJSON(&sched); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"type":  "validation_error",
+		})
+		return
+	}
+
+	if err := h.Dispatcher.SaveDigestSchedule(userID, &sched); err != nil {
+		msg := err.Error()
+		if strings.HasPrefix(msg, "validation_error: ") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": strings.TrimPrefix(msg, "validation_error: "),
+				"type":  "validation_error",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Digest schedule updated",
+		"schedule": sched,
+	})
+}
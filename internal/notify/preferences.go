@@ -0,0 +1,194 @@
+package notify
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// QuietHours is a daily window in which nothing is delivered; notifications
+// are held and sent together when it ends. Start may be after End to span
+// midnight (e.g. 22:00-07:00).
+type QuietHours struct {
+	Start    string `json:"start"`              // "HH:MM"
+	End      string `json:"end"`                // "HH:MM"
+	Timezone string `json:"timezone,omitempty"` // IANA name, default UTC
+}
+
+// Preferences controls how a user's notifications are delivered.
+type Preferences struct {
+	// Channels used for every subscribed manga unless overridden.
+	Channels []string `json:"channels"`
+	// MangaChannels overrides Channels per manga ID; an empty list mutes it.
+	MangaChannels map[string][]string `json:"manga_channels,omitempty"`
+
+	// Email overrides the account email address.
+	Email         string `json:"email,omitempty"`
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+
+	// Digest is the daily digest switch from before digest schedules. It is
+	// still accepted: users who set it get a daily schedule at
+	// MANGAHUB_NOTIFY_DIGEST_HOUR unless they already have one.
+	Digest bool `json:"digest,omitempty"`
+}
+
+// DefaultPreferences applies to users who never saved any.
+func DefaultPreferences() *Preferences {
+	return &Preferences{Channels: []string{ChannelUDP}}
+}
+
+// channelsFor returns the channels to use for a manga.
+func (p *Preferences) channelsFor(mangaID string) []string {
+	if chans, ok := p.MangaChannels[mangaID]; ok {
+		return chans
+	}
+	return p.Channels
+}
+
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q *QuietHours) location() *time.Location {
+	if q.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// until returns when the quiet window containing now ends, or the zero time
+// if now is outside quiet hours.
+func (q *QuietHours) until(now time.Time) time.Time {
+	if q == nil {
+		return time.Time{}
+	}
+	start, err1 := parseClock(q.Start)
+	end, err2 := parseClock(q.End)
+	if err1 != nil || err2 != nil || start == end {
+		return time.Time{}
+	}
+
+	local := now.In(q.location())
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+	switch {
+	case start < end && minute >= start && minute < end:
+		return midnight.Add(time.Duration(end) * time.Minute)
+	case start > end && minute >= start:
+		return midnight.AddDate(0, 0, 1).Add(time.Duration(end) * time.Minute)
+	case start > end && minute < end:
+		return midnight.Add(time.Duration(end) * time.Minute)
+	}
+	return time.Time{}
+}
+
+// validate checks p against the available channels.
+func (p *Preferences) validate(channels map[string]Channel) error {
+	check := func(list []string) error {
+		for _, name := range list {
+			if _, ok := channels[name]; !ok {
+				return fmt.Errorf("unknown channel %q", name)
+			}
+			if name == ChannelWebhook && p.WebhookURL == "" {
+				return errors.New("webhook_url is required for the webhook channel")
+			}
+		}
+		return nil
+	}
+	if err := check(p.Channels); err != nil {
+		return err
+	}
+	for _, list := range p.MangaChannels {
+		if err := check(list); err != nil {
+			return err
+		}
+	}
+
+	if p.WebhookURL != "" {
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("webhook_url must be an http(s) URL")
+		}
+	}
+	if q := p.QuietHours; q != nil {
+		if _, err := parseClock(q.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(q.End); err != nil {
+			return err
+		}
+		if q.Timezone != "" {
+			if _, err := time.LoadLocation(q.Timezone); err != nil {
+				return fmt.Errorf("unknown timezone %q", q.Timezone)
+			}
+		}
+	}
+	return nil
+}
+
+// Preferences returns the user's saved preferences, or the defaults.
+func (d *Dispatcher) Preferences(userID string) (*Preferences, error) {
+	var raw string
+	err := d.DB.QueryRow(`SELECT prefs FROM notification_preferences WHERE user_id = ?`, userID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return DefaultPreferences(), nil
+	}
+	if err != nil {
+		log.Printf("Error querying notification preferences: %v", err)
+		return nil, errors.New("database_error: failed to load preferences")
+	}
+
+	p := DefaultPreferences()
+	if err := json.Unmarshal([]byte(raw), p); err != nil {
+		log.Printf("Error decoding notification preferences for %s: %v", userID, err)
+		return DefaultPreferences(), nil
+	}
+	return p, nil
+}
+
+// SavePreferences validates and stores the user's preferences.
+func (d *Dispatcher) SavePreferences(userID string, p *Preferences) error {
+	if p.Channels == nil {
+		p.Channels = []string{}
+	}
+	if err := p.validate(d.Channels); err != nil {
+		return fmt.Errorf("validation_error: %w", err)
+	}
+
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = d.DB.Exec(
+		`INSERT INTO notification_preferences (user_id, prefs, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET prefs = excluded.prefs, updated_at = CURRENT_TIMESTAMP`,
+		userID, string(raw),
+	)
+	if err != nil {
+		log.Printf("Error saving notification preferences: %v", err)
+		return errors.New("database_error: failed to save preferences")
+	}
+	if p.Digest {
+		if err := d.adoptLegacyDigest(userID); err != nil {
+			log.Printf("Error migrating digest preference: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mangahub/internal/udp"
+)
+
+// WebhookPayload is the JSON body POSTed to user webhooks.
+type WebhookPayload struct {
+	Event         string             `json:"event"` // "chapter_release"
+	UserID        string             `json:"user_id"`
+	Notifications []udp.Notification `json:"notifications"`
+	SentAt        int64              `json:"sent_at"`
+}
+
+// WebhookChannel POSTs notifications as signed JSON. The body is signed with
+// the user's webhook secret: X-MangaHub-Signature is "sha256=" followed by the
+// hex HMAC-SHA256 of the raw body.
+type WebhookChannel struct {
+	Client      *http.Client
+	MaxAttempts int
+	RetryBase   time.Duration
+}
+
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 3,
+		RetryBase:   time.Second,
+	}
+}
+
+func (c *WebhookChannel) Name() string { return ChannelWebhook }
+
+// Send delivers the batch, retrying network errors, 429 and 5xx responses
+// with exponential backoff.
+func (c *WebhookChannel) Send(ctx context.Context, to Recipient, batch []udp.Notification) error {
+	if to.WebhookURL == "" {
+		return errors.New("no webhook url")
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		Event:         "chapter_release",
+		UserID:        to.UserID,
+		Notifications: batch,
+		SentAt:        time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < c.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.RetryBase << uint(attempt-1)):
+			}
+		}
+
+		retry, err := c.post(ctx, to, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return fmt.Errorf("webhook %s: %w", to.WebhookURL, lastErr)
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (c *WebhookChannel) post(ctx context.Context, to Recipient, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MangaHub-Webhook/1.0")
+	req.Header.Set("X-MangaHub-Event", "chapter_release")
+	if to.WebhookSecret != "" {
+		req.Header.Set("X-MangaHub-Signature", "sha256="+signBody(to.WebhookSecret, body))
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, errors.New("status " + strconv.Itoa(resp.StatusCode))
+}
+
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
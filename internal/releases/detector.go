@@ -0,0 +1,258 @@
+package releases
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mangahub/internal/events"
+	"mangahub/internal/manga"
+	"mangahub/internal/mangadex"
+)
+
+// Detector periodically polls MangaDex chapter feeds for every manga that
+// someone is subscribed to or is reading, records new chapters in
+// chapter_releases (the source for digests) and publishes a chapter_released
+// event for them, which the UDP server turns into notifications.
+type Detector struct {
+	DB          *sql.DB
+	Manga       *manga.Service
+	Bus         *events.Bus
+	Interval    time.Duration
+	Languages   []string
+	Concurrency int
+
+	// LatestChapter fetches the newest chapter of a manga by its MangaDex
+	// ID; it defaults to the MangaDex client's GetLatestChapter.
+	LatestChapter func(nativeID string, languages []string) (*mangadex.Chapter, error)
+}
+
+// NewDetector creates a Detector configured from environment variables:
+// - MANGAHUB_RELEASE_POLL_INTERVAL (Go duration, default 15m)
+// - MANGAHUB_RELEASE_LANGUAGES (comma-separated, default "en"; empty = all)
+// - MANGAHUB_RELEASE_CONCURRENCY (parallel feed checks, default 4)
+func NewDetector(db *sql.DB, mangaSvc *manga.Service, bus *events.Bus) *Detector {
+	d := &Detector{
+		DB:          db,
+		Manga:       mangaSvc,
+		Bus:         bus,
+		Interval:    15 * time.Minute,
+		Languages:   []string{"en"},
+		Concurrency: 4,
+	}
+	if mangaSvc != nil {
+		d.LatestChapter = mangaSvc.MangaDex.GetLatestChapter
+	}
+
+	if v := os.Getenv("MANGAHUB_RELEASE_POLL_INTERVAL"); v != "" {
+		if dur, err := time.ParseDuration(v); err == nil && dur > 0 {
+			d.Interval = dur
+		}
+	}
+	if v, ok := os.LookupEnv("MANGAHUB_RELEASE_LANGUAGES"); ok {
+		d.Languages = nil
+		for _, lang := range strings.Split(v, ",") {
+			if lang = strings.TrimSpace(lang); lang != "" {
+				d.Languages = append(d.Languages, lang)
+			}
+		}
+	}
+	if v := os.Getenv("MANGAHUB_RELEASE_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			d.Concurrency = n
+		}
+	}
+	return d
+}
+
+// Run checks for releases immediately and then every Interval until ctx is
+// cancelled.
+func (d *Detector) Run(ctx context.Context) {
+	log.Printf("Release detector started (interval=%s, languages=%v, concurrency=%d)",
+		d.Interval, d.Languages, d.Concurrency)
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.CheckOnce(ctx)
+		select {
+		case <-ctx.Done():
+			log.Println("Release detector stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce polls every watched manga once.
+func (d *Detector) CheckOnce(ctx context.Context) {
+	mangaIDs, err := d.watchedManga()
+	if err != nil {
+		log.Printf("Release detector: %v", err)
+		return
+	}
+	if len(mangaIDs) == 0 {
+		return
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < d.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				if err := d.check(id); err != nil {
+					log.Printf("Release detector: manga %s: %v", id, err)
+				}
+			}
+		}()
+	}
+
+	for _, id := range mangaIDs {
+		select {
+		case jobs <- id:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// watchedManga returns the distinct manga IDs with at least one subscriber
+// or reader.
+func (d *Detector) watchedManga() ([]string, error) {
+	rows, err := d.DB.Query(
+		`SELECT manga_id FROM user_notifications
+		UNION
+		SELECT manga_id FROM user_progress WHERE status = 'reading'`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// check fetches the latest chapter for one manga, compares it with the last
+// one seen and publishes a chapter_released event if it is newer. The first
+// time a manga is checked its latest chapter only becomes the baseline, since
+// it is not new to anyone. After that, fractional chapters (extras such as
+// 10.5) are skipped and leave the baseline alone, as notifications carry
+// whole chapter numbers.
+func (d *Detector) check(mangaID string) error {
+	provider, nativeID := d.Manga.ParseID(mangaID)
+	if provider != "mangadex" {
+		return nil // only MangaDex feeds are polled
+	}
+
+	latest, err := d.LatestChapter(nativeID, d.Languages)
+	if err != nil {
+		return fmt.Errorf("fetch feed: %w", err)
+	}
+	if latest == nil {
+		return nil
+	}
+	chapter, err := strconv.ParseFloat(latest.Attributes.Chapter, 64)
+	if err != nil {
+		return nil // oneshots and unnumbered extras have no chapter number
+	}
+
+	var lastSeen sql.NullFloat64
+	err = d.DB.QueryRow(
+		`SELECT last_chapter FROM manga_release_state WHERE manga_id = ?`, mangaID,
+	).Scan(&lastSeen)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("query release state: %w", err)
+	}
+	if err == sql.ErrNoRows || !lastSeen.Valid {
+		log.Printf("Release detector: watching manga %s from chapter %s", mangaID, latest.Attributes.Chapter)
+		return d.saveState(mangaID, chapter, latest.ID)
+	}
+	if chapter <= lastSeen.Float64 || chapter != math.Trunc(chapter) {
+		_, _ = d.DB.Exec(`UPDATE manga_release_state SET checked_at = CURRENT_TIMESTAMP WHERE manga_id = ?`, mangaID)
+		return nil
+	}
+
+	// Keep the cached chapter count in line so progress validation accepts it.
+	_, _ = d.DB.Exec(
+		`UPDATE manga SET total_chapters = ? WHERE id = ? AND total_chapters < ?`,
+		int(chapter), mangaID, int(chapter),
+	)
+
+	title := mangaID
+	var genres []string
+	if m, err := d.Manga.GetMangaByID(mangaID); err == nil && m != nil {
+		title = m.Title
+		genres = m.Genres
+	}
+
+	release := events.Release{
+		MangaID:   mangaID,
+		Title:     title,
+		Chapter:   int(chapter),
+		Message:   fmt.Sprintf("Chapter %s of %s is out!", latest.Attributes.Chapter, title),
+		Timestamp: time.Now().Unix(),
+		Genres:    genres,
+	}
+	log.Printf("Release detector: new chapter %s for manga %s", latest.Attributes.Chapter, mangaID)
+
+	if err := d.recordRelease(mangaID, title, chapter, latest.Attributes.Chapter); err != nil {
+		return err
+	}
+
+	d.Bus.Publish(events.Event{Type: events.ChapterReleased, Source: "releases", Payload: release})
+	return d.saveState(mangaID, chapter, latest.ID)
+}
+
+// saveState records chapter as the last seen chapter for mangaID.
+func (d *Detector) saveState(mangaID string, chapter float64, chapterID string) error {
+	_, err := d.DB.Exec(
+		`INSERT INTO manga_release_state (manga_id, last_chapter, last_chapter_id, checked_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(manga_id) DO UPDATE SET
+			last_chapter = excluded.last_chapter,
+			last_chapter_id = excluded.last_chapter_id,
+			checked_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP`,
+		mangaID, chapter, chapterID,
+	)
+	if err != nil {
+		return fmt.Errorf("save release state: %w", err)
+	}
+	return nil
+}
+
+// recordRelease logs a new chapter for digests. Re-recording the same
+// chapter (after a failed state update) is a no-op.
+func (d *Detector) recordRelease(mangaID, title string, chapter float64, label string) error {
+	_, err := d.DB.Exec(
+		`INSERT OR IGNORE INTO chapter_releases (manga_id, title, chapter, chapter_label, released_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		mangaID, title, chapter, label, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("record release: %w", err)
+	}
+	return nil
+}
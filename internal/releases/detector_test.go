@@ -0,0 +1,159 @@
+package releases
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mangahub/internal/database"
+	"mangahub/internal/events"
+	"mangahub/internal/manga"
+	"mangahub/internal/mangadex"
+)
+
+const testManga = "mangadex-test-manga"
+
+// feed is a fake MangaDex feed whose latest chapter the test sets.
+type feed struct{ latest string }
+
+func (f *feed) latestChapter(nativeID string, languages []string) (*mangadex.Chapter, error) {
+	ch := &mangadex.Chapter{ID: "chapter-" + f.latest}
+	ch.Attributes.Chapter = f.latest
+	return ch, nil
+}
+
+// newTestDetector returns a detector over a fresh database that knows
+// testManga, its fake feed and the releases it publishes.
+func newTestDetector(t *testing.T) (*Detector, *feed, <-chan events.Release, *events.Bus) {
+	t.Helper()
+	t.Setenv("MANGAHUB_USE_MANGADEX", "true")
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(
+		`INSERT INTO manga (id, title, author, genres, status, total_chapters, description, cover_url)
+		VALUES (?, 'Test Manga', '', '[]', 'ongoing', 10, '', '')`, testManga,
+	); err != nil {
+		t.Fatalf("insert manga: %v", err)
+	}
+
+	bus := events.NewBus()
+	released := make(chan events.Release, 16)
+	unsubscribe := bus.Subscribe("test", func(e events.Event) {
+		if r, ok := e.Payload.(events.Release); ok {
+			released <- r
+		} else {
+			close(released)
+		}
+	})
+	t.Cleanup(unsubscribe)
+
+	f := &feed{}
+	d := NewDetector(db, manga.NewService(db), bus)
+	d.LatestChapter = f.latestChapter
+	return d, f, released, bus
+}
+
+// releases returns what was published so far. Events arrive in order, so a
+// marker published after the check comes after any release it published.
+func releases(t *testing.T, bus *events.Bus, released <-chan events.Release) []events.Release {
+	t.Helper()
+	bus.Publish(events.Event{Type: "test_marker"})
+	var out []events.Release
+	for {
+		select {
+		case r, ok := <-released:
+			if !ok {
+				return out
+			}
+			out = append(out, r)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for events")
+		}
+	}
+}
+
+// lastChapter returns the baseline stored for testManga.
+func lastChapter(t *testing.T, d *Detector) float64 {
+	t.Helper()
+	var last float64
+	if err := d.DB.QueryRow(
+		`SELECT last_chapter FROM manga_release_state WHERE manga_id = ?`, testManga,
+	).Scan(&last); err != nil {
+		t.Fatalf("query release state: %v", err)
+	}
+	return last
+}
+
+func TestCheckFirstSightOnlyRecordsBaseline(t *testing.T) {
+	d, f, released, bus := newTestDetector(t)
+	f.latest = "42"
+
+	if err := d.check(testManga); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if got := releases(t, bus, released); len(got) != 0 {
+		t.Fatalf("first sight published %+v, want nothing", got)
+	}
+	if last := lastChapter(t, d); last != 42 {
+		t.Fatalf("baseline %v, want 42", last)
+	}
+	var recorded int
+	_ = d.DB.QueryRow(`SELECT COUNT(*) FROM chapter_releases`).Scan(&recorded)
+	if recorded != 0 {
+		t.Fatalf("%d release(s) recorded for digests, want none", recorded)
+	}
+}
+
+func TestCheckPublishesNewChapter(t *testing.T) {
+	d, f, released, bus := newTestDetector(t)
+	f.latest = "42"
+	if err := d.check(testManga); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	f.latest = "43"
+	if err := d.check(testManga); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	got := releases(t, bus, released)
+	if len(got) != 1 || got[0].MangaID != testManga || got[0].Chapter != 43 || got[0].Title != "Test Manga" {
+		t.Fatalf("published %+v, want chapter 43 of Test Manga", got)
+	}
+	if last := lastChapter(t, d); last != 43 {
+		t.Fatalf("baseline %v, want 43", last)
+	}
+}
+
+func TestCheckIgnoresUnchangedAndFractionalChapters(t *testing.T) {
+	tests := []struct {
+		name   string
+		latest string
+	}{
+		{"unchanged", "42"},
+		{"older", "41"},
+		{"fractional", "42.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, f, released, bus := newTestDetector(t)
+			f.latest = "42"
+			if err := d.check(testManga); err != nil {
+				t.Fatalf("check: %v", err)
+			}
+
+			f.latest = tt.latest
+			if err := d.check(testManga); err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if got := releases(t, bus, released); len(got) != 0 {
+				t.Fatalf("published %+v, want nothing", got)
+			}
+			if last := lastChapter(t, d); last != 42 {
+				t.Fatalf("baseline %v, want 42", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package sse
+
+import "mangahub/internal/events"
+
+// Consume forwards the bus events that concern a single user to that user's
+// streams. Event names stay compatible with earlier clients: "progress",
+// "chapter_release" and "library_changed".
+func (h *Hub) Consume(bus *events.Bus) {
+	bus.Subscribe("sse", func(e events.Event) {
+		if e.UserID == "" {
+			return
+		}
+		switch e.Type {
+		case events.ProgressUpdated:
+			h.Publish(e.UserID, "progress", e.Payload)
+		case events.ChapterReleased:
+			h.Publish(e.UserID, "chapter_release", e.Payload)
+		case events.LibraryChanged:
+			h.Publish(e.UserID, "library_changed", e.Payload)
+		}
+	}, events.ProgressUpdated, events.ChapterReleased, events.LibraryChanged)
+}
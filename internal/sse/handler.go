@@ -0,0 +1,133 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mangahub/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keepAliveInterval keeps proxies from closing idle streams.
+const keepAliveInterval = 15 * time.Second
+
+type Handler struct {
+	Hub       *Hub
+	JWTSecret []byte
+}
+
+// RegisterRoutes wires GET /events. The route authenticates itself because
+// browsers' EventSource cannot set headers: the JWT may be passed either as
+// "Authorization: Bearer <token>" or as ?token=<token>. Servers that log
+// request URLs should use LogFormatter so the token stays out of the logs.
+func RegisterRoutes(r gin.IRoutes, hub *Hub, jwtSecret []byte) {
+	h := &Handler{Hub: hub, JWTSecret: jwtSecret}
+	r.GET("/events", h.HandleEvents)
+}
+
+// HandleEvents streams progress and chapter_release events for the current
+// user. A Last-Event-ID header (or ?last_event_id=) replays buffered events.
+func (h *Handler) HandleEvents(c *gin.Context) {
+	raw := c.Query("token")
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		raw = strings.TrimPrefix(header, "Bearer ")
+	}
+	if raw == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+		return
+	}
+	claims, err := auth.ParseToken(h.JWTSecret, raw)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	since, _ := strconv.ParseUint(lastID, 10, 64)
+
+	events, backlog, cancel := h.Hub.Subscribe(claims.UserID, since)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, ev := range backlog {
+		writeEvent(w, ev)
+	}
+	w.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// Fell behind or shutting down: the browser reconnects
+				// after the retry delay and resumes with Last-Event-ID.
+				return
+			}
+			writeEvent(w, ev)
+			w.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			w.Flush()
+		}
+	}
+}
+
+func writeEvent(w gin.ResponseWriter, ev Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
+
+// LogFormatter formats access log lines like gin's default logger, with the
+// value of any token query parameter replaced by "REDACTED".
+func LogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactToken(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactToken hides the token parameter in a path with a query string.
+func redactToken(path string) string {
+	base, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		if strings.HasPrefix(p, "token=") {
+			params[i] = "token=REDACTED"
+		}
+	}
+	return base + "?" + strings.Join(params, "&")
+}
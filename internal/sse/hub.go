@@ -0,0 +1,190 @@
+package sse
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is how many recent events are kept per user for
+// Last-Event-ID resume.
+const DefaultBufferSize = 100
+
+// DefaultMaxUsers is how many users' events are kept; the least recently
+// active are forgotten first.
+const DefaultMaxUsers = 10000
+
+// streamQueueSize is how many events a stream may fall behind before it is
+// closed.
+const streamQueueSize = 32
+
+// Event is one server-sent event addressed to a user.
+type Event struct {
+	ID   uint64
+	Type string // SSE "event:" field, e.g. "progress" or "chapter_release"
+	Data []byte // JSON payload
+}
+
+// Hub fans events out to the SSE streams of each user and remembers the
+// last BufferSize events of the MaxUsers most recently active users so
+// reconnecting browsers can catch up.
+type Hub struct {
+	BufferSize int
+	MaxUsers   int
+
+	mu      sync.Mutex
+	lastID  uint64
+	closed  bool
+	buffers map[string]*list.Element           // of *userBuffer
+	lru     *list.List                         // most recently used first
+	streams map[string]map[chan Event]struct{} // userID -> open streams
+}
+
+type userBuffer struct {
+	userID string
+	events []Event // oldest first, at most BufferSize
+}
+
+// NewHub creates a Hub configured from environment variables:
+// - MANGAHUB_SSE_BUFFER (events kept per user, default 100)
+// - MANGAHUB_SSE_USERS (users whose events are kept, default 10000)
+func NewHub() *Hub {
+	h := &Hub{
+		BufferSize: DefaultBufferSize,
+		MaxUsers:   DefaultMaxUsers,
+		// IDs start from the clock so a browser resuming after a restart
+		// never holds a Last-Event-ID ahead of the new events.
+		lastID:  uint64(time.Now().UnixNano()),
+		buffers: make(map[string]*list.Element),
+		lru:     list.New(),
+		streams: make(map[string]map[chan Event]struct{}),
+	}
+	if v := os.Getenv("MANGAHUB_SSE_BUFFER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			h.BufferSize = n
+		}
+	}
+	if v := os.Getenv("MANGAHUB_SSE_USERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			h.MaxUsers = n
+		}
+	}
+	return h
+}
+
+// buffer returns userID's buffer, marking it most recently used, or nil if
+// there is none and create is false; h.mu must be held.
+func (h *Hub) buffer(userID string, create bool) *userBuffer {
+	if el, ok := h.buffers[userID]; ok {
+		h.lru.MoveToFront(el)
+		return el.Value.(*userBuffer)
+	}
+	if !create {
+		return nil
+	}
+	b := &userBuffer{userID: userID}
+	h.buffers[userID] = h.lru.PushFront(b)
+	for h.lru.Len() > h.MaxUsers {
+		oldest := h.lru.Back()
+		h.lru.Remove(oldest)
+		delete(h.buffers, oldest.Value.(*userBuffer).userID)
+	}
+	return b
+}
+
+// Publish sends payload, encoded as JSON, to every stream of userID. A stream
+// that has fallen streamQueueSize events behind is closed instead: its
+// browser reconnects with Last-Event-ID and catches up from the buffer.
+func (h *Hub) Publish(userID, eventType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.lastID++
+	ev := Event{ID: h.lastID, Type: eventType, Data: data}
+
+	b := h.buffer(userID, true)
+	b.events = append(b.events, ev)
+	if len(b.events) > h.BufferSize {
+		b.events = b.events[len(b.events)-h.BufferSize:]
+	}
+
+	for ch := range h.streams[userID] {
+		select {
+		case ch <- ev:
+		default:
+			h.remove(userID, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe opens a stream for userID. Events newer than lastID that are
+// still buffered are returned as backlog. The stream's channel is closed when
+// the stream falls behind or the hub is closed; the returned func closes the
+// stream.
+func (h *Hub) Subscribe(userID string, lastID uint64) (<-chan Event, []Event, func()) {
+	ch := make(chan Event, streamQueueSize)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, nil, func() {}
+	}
+	var backlog []Event
+	if b := h.buffer(userID, false); b != nil && lastID > 0 {
+		for _, ev := range b.events {
+			if ev.ID > lastID {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	if h.streams[userID] == nil {
+		h.streams[userID] = make(map[chan Event]struct{})
+	}
+	h.streams[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, ch)
+	}
+	return ch, backlog, cancel
+}
+
+// Close closes every stream, so their handlers return, and every stream
+// opened later. Register it with http.Server.RegisterOnShutdown: Shutdown
+// does not cancel the requests it waits for.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for userID, streams := range h.streams {
+		for ch := range streams {
+			close(ch)
+		}
+		delete(h.streams, userID)
+	}
+}
+
+// remove forgets one stream of userID; h.mu must be held.
+func (h *Hub) remove(userID string, ch chan Event) {
+	if streams, ok := h.streams[userID]; ok {
+		delete(streams, ch)
+		if len(streams) == 0 {
+			delete(h.streams, userID)
+		}
+	}
+}
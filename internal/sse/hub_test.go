@@ -0,0 +1,81 @@
+package sse
+
+import "testing"
+
+func newTestHub() *Hub {
+	h := NewHub()
+	h.BufferSize = 100
+	h.MaxUsers = 2
+	return h
+}
+
+func TestSlowStreamIsClosedAndResumes(t *testing.T) {
+	h := newTestHub()
+	events, _, cancel := h.Subscribe("user_a", 0)
+	defer cancel()
+
+	for i := 0; i <= streamQueueSize; i++ {
+		h.Publish("user_a", "progress", i)
+	}
+
+	var last uint64
+	for ev := range events {
+		last = ev.ID
+	}
+	if n := len(h.streams["user_a"]); n != 0 {
+		t.Fatalf("%d stream(s) still registered after falling behind", n)
+	}
+
+	// The dropped event is replayed to the reconnecting browser.
+	_, backlog, cancel2 := h.Subscribe("user_a", last)
+	defer cancel2()
+	if len(backlog) != 1 || string(backlog[0].Data) != "32" {
+		t.Fatalf("backlog %+v, want only the dropped event", backlog)
+	}
+}
+
+func TestHubForgetsLeastRecentUser(t *testing.T) {
+	h := newTestHub()
+	h.Publish("user_a", "progress", 1)
+	h.Publish("user_b", "progress", 1)
+	h.Subscribe("user_a", 1) // touches user_a
+	h.Publish("user_c", "progress", 1)
+
+	if len(h.buffers) != 2 {
+		t.Fatalf("%d users buffered, want 2", len(h.buffers))
+	}
+	if _, ok := h.buffers["user_b"]; ok {
+		t.Fatal("user_b was kept, want the least recently active user forgotten")
+	}
+}
+
+func TestCloseEndsStreams(t *testing.T) {
+	h := newTestHub()
+	events, _, cancel := h.Subscribe("user_a", 0)
+	defer cancel()
+
+	h.Close()
+	if _, ok := <-events; ok {
+		t.Fatal("stream still open after Close")
+	}
+	later, _, cancel2 := h.Subscribe("user_a", 0)
+	defer cancel2()
+	if _, ok := <-later; ok {
+		t.Fatal("stream opened after Close is not closed")
+	}
+	h.Publish("user_a", "progress", 1) // must not panic
+}
+
+func TestRedactToken(t *testing.T) {
+	tests := map[string]string{
+		"/events":                           "/events",
+		"/events?token=abc.def":             "/events?token=REDACTED",
+		"/events?last_event_id=5&token=abc": "/events?last_event_id=5&token=REDACTED",
+		"/manga?q=tokens":                   "/manga?q=tokens",
+	}
+	for in, want := range tests {
+		if got := redactToken(in); got != want {
+			t.Errorf("redactToken(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package tcp
+
+import (
+	"errors"
+
+	"mangahub/internal/auth"
+)
+
+// authenticate validates the token from an AuthMessage and returns the user
+// the connection belongs to and the token's role. Regular users are bound to
+// the token subject; admin and service tokens must name the user they act
+// for.
+func (s *Server) authenticate(msg AuthMessage) (string, string, error) {
+	if len(s.JWTSecret) == 0 {
+		return "", "", errors.New("auth_not_configured")
+	}
+	if msg.Token == "" {
+		return "", "", errors.New("missing_token")
+	}
+	claims, err := auth.ParseToken(s.JWTSecret, msg.Token)
+	if err != nil {
+		return "", "", errors.New("invalid_token")
+	}
+
+	switch claims.Role {
+	case auth.RoleAdmin, auth.RoleService:
+		if msg.UserID != "" {
+			return msg.UserID, claims.Role, nil
+		}
+		if claims.Role == auth.RoleService {
+			return "", "", errors.New("missing_user_id")
+		}
+	default:
+		if msg.UserID != "" && msg.UserID != claims.UserID {
+			return "", "", errors.New("user_mismatch")
+		}
+	}
+	return claims.UserID, claims.Role, nil
+}
@@ -0,0 +1,168 @@
+package tcp
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Connection defaults. A client that sends nothing (not even a pong) for
+// IdleTimeout is disconnected; the server pings every PingInterval so a
+// healthy client always has something to answer.
+const (
+	DefaultPingInterval  = 30 * time.Second
+	DefaultIdleTimeout   = 90 * time.Second
+	DefaultWriteTimeout  = 10 * time.Second
+	DefaultSendQueueSize = 256
+	DefaultAuthTimeout   = 10 * time.Second
+)
+
+// HeartbeatMessage is a "ping" or "pong". The server pings every
+// PingInterval and expects a pong; clients may ping the server too.
+type HeartbeatMessage struct {
+	Type      string `json:"type"` // "ping" | "pong"
+	Timestamp int64  `json:"timestamp"`
+}
+
+// client is one authenticated connection. All writes go through its bounded
+// send queue so a stalled peer can never block broadcasts to other clients.
+type client struct {
+	conn    net.Conn
+	userID  string
+	role    string          // role of the token the client authenticated with
+	version int             // negotiated protocol version
+	framing string          // FramingJSON or FramingProtobuf after auth
+	types   map[string]bool // message types the client receives
+	send    chan []byte
+
+	// While a snapshot is being built, broadcasts for the client are held
+	// here and queued after it; both are guarded by Server.seqMu.
+	holding bool
+	held    [][]byte
+
+	closeOnce sync.Once
+	done      chan struct{}
+	drainOnce sync.Once
+	draining  chan struct{} // closed to flush the queue and disconnect
+}
+
+func newClient(conn net.Conn, userID, role string, version int, framing string, queueSize int) *client {
+	types := make(map[string]bool)
+	for _, t := range messageTypes[version] {
+		types[t] = true
+	}
+	return &client{
+		conn:     conn,
+		userID:   userID,
+		role:     role,
+		version:  version,
+		framing:  framing,
+		types:    types,
+		send:     make(chan []byte, queueSize),
+		done:     make(chan struct{}),
+		draining: make(chan struct{}),
+	}
+}
+
+// close shuts the connection down; it is safe to call more than once.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// drain makes the writer send what is queued and then close the connection.
+func (c *client) drain() {
+	c.drainOnce.Do(func() { close(c.draining) })
+}
+
+// enqueue queues v for the writer in the client's framing. It reports false
+// if v could not be encoded or queued.
+func (s *Server) enqueue(c *client, v interface{}) bool {
+	frame, err := encodeMessage(c.framing, v)
+	if err != nil {
+		log.Printf("TCP: failed to encode message for %s: %v\n", c.userID, err)
+		return false
+	}
+	return s.enqueueFrame(c, frame)
+}
+
+// enqueueFrame queues an encoded line or frame. It reports false, and evicts
+// the client, if the queue is full.
+func (s *Server) enqueueFrame(c *client, data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	case c.send <- data:
+		return true
+	default:
+		log.Printf("TCP: evicting slow consumer %s at %s (%d messages queued)\n",
+			c.userID, c.conn.RemoteAddr(), len(c.send))
+		c.close()
+		return false
+	}
+}
+
+// writeLoop drains the send queue and pings the client every PingInterval.
+// Every write has a deadline; a write that misses it closes the connection.
+// After drain it flushes the queue and closes the connection.
+func (s *Server) writeLoop(c *client) {
+	ticker := time.NewTicker(s.PingInterval)
+	defer ticker.Stop()
+
+	write := func(data []byte) bool {
+		select {
+		case <-c.done:
+			return false // evicted or disconnected meanwhile
+		default:
+		}
+		_ = c.conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+		if _, err := c.conn.Write(data); err != nil {
+			select {
+			case <-c.done: // closed while writing, already logged
+			default:
+				log.Printf("TCP: write to %s failed, closing: %v\n", c.userID, err)
+			}
+			c.close()
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			// Coalesce whatever else is queued into the same write. Frames
+			// are shared between clients, so copy before appending.
+			if n := len(c.send); n > 0 {
+				batch := append([]byte(nil), data...)
+				for ; n > 0; n-- {
+					batch = append(batch, <-c.send...)
+				}
+				data = batch
+			}
+			if !write(data) {
+				return
+			}
+		case <-c.draining:
+			var batch []byte
+			for n := len(c.send); n > 0; n-- {
+				batch = append(batch, <-c.send...)
+			}
+			if len(batch) > 0 {
+				write(batch)
+			}
+			c.close()
+			return
+		case <-ticker.C:
+			frame, _ := encodeMessage(c.framing, HeartbeatMessage{Type: "ping", Timestamp: time.Now().Unix()})
+			if !write(frame) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package tcp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "mangahub/proto"
+)
+
+// Framings a client can ask for in its auth message. The auth exchange is
+// always a JSON line; with protobuf both sides switch to frames of a 4-byte
+// big-endian length followed by a pb.SyncMessage right after auth_response.
+const (
+	FramingJSON     = "json"
+	FramingProtobuf = "protobuf"
+)
+
+// DefaultMaxFrameSize bounds one JSON line or protobuf frame read from a
+// client, so a peer can't make the server buffer unbounded input.
+const DefaultMaxFrameSize = 64 << 10
+
+// ErrFrameTooLarge is returned when a client's line or frame exceeds the
+// maximum frame size; the connection is closed.
+var ErrFrameTooLarge = errors.New("frame_too_large")
+
+// negotiateFraming returns the framing to use with a client asking for
+// requested, falling back to JSON for anything unknown.
+func negotiateFraming(requested string) string {
+	if requested == FramingProtobuf {
+		return FramingProtobuf
+	}
+	return FramingJSON
+}
+
+// readLine reads one newline-terminated line of at most max bytes.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return nil, ErrFrameTooLarge
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// inbound is one message read from a client: a JSON line, or a decoded
+// protobuf frame.
+type inbound struct {
+	line []byte
+	msg  *pb.SyncMessage
+}
+
+// decode fills v from the message. Protobuf frames are converted directly
+// for the types clients send (*Header, *ProgressUpdate, *SnapshotRequest).
+func (in inbound) decode(v interface{}) error {
+	m := in.msg
+	if m == nil {
+		return json.Unmarshal(in.line, v)
+	}
+	header := Header{Type: m.Type, Seq: m.Seq, UserID: m.UserId}
+	switch v := v.(type) {
+	case *Header:
+		*v = header
+	case *ProgressUpdate:
+		*v = ProgressUpdate{
+			Header:          header,
+			MangaID:         m.MangaId,
+			Chapter:         int(m.Chapter),
+			Status:          m.Status,
+			Timestamp:       m.Timestamp,
+			Version:         m.Version,
+			BaseVersion:     m.BaseVersion,
+			ClientTimestamp: m.ClientTimestamp,
+		}
+	case *SnapshotRequest:
+		*v = SnapshotRequest{Type: m.Type, RequestID: m.RequestId}
+	default:
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, v)
+	}
+	return nil
+}
+
+// readFrame reads one length-prefixed protobuf frame of at most max bytes.
+func readFrame(r *bufio.Reader, max int) (*pb.SyncMessage, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > uint32(max) {
+		return nil, ErrFrameTooLarge
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	var msg pb.SyncMessage
+	if err := proto.Unmarshal(buf, &msg); err != nil {
+		return nil, fmt.Errorf("decode frame: %w", err)
+	}
+	return &msg, nil
+}
+
+// readMessage reads the next message from a client in its framing.
+func readMessage(r *bufio.Reader, framing string, max int) (inbound, error) {
+	if framing == FramingProtobuf {
+		msg, err := readFrame(r, max)
+		return inbound{msg: msg}, err
+	}
+	line, err := readLine(r, max)
+	return inbound{line: line}, err
+}
+
+// encodeMessage encodes v for the wire in framing. Protobuf frames are built
+// from v directly; only types without a conversion go through JSON.
+func encodeMessage(framing string, v interface{}) ([]byte, error) {
+	if framing == FramingProtobuf {
+		if msg := syncMessage(v); msg != nil {
+			return protoFrame(msg)
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return encodeFrame(framing, data)
+}
+
+// encodeFrame wraps an encoded JSON message for the wire: a newline-terminated
+// copy for JSON, a length-prefixed pb.SyncMessage for protobuf. It serves
+// messages that only exist as JSON, such as replayed ones.
+func encodeFrame(framing string, data []byte) ([]byte, error) {
+	if framing != FramingProtobuf {
+		return append(append(make([]byte, 0, len(data)+1), data...), '\n'), nil
+	}
+	var msg pb.SyncMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("convert to frame: %w", err)
+	}
+	return protoFrame(&msg)
+}
+
+func protoFrame(msg *pb.SyncMessage) ([]byte, error) {
+	size := proto.Size(msg)
+	frame := make([]byte, 4, 4+size)
+	binary.BigEndian.PutUint32(frame, uint32(size))
+	return proto.MarshalOptions{}.MarshalAppend(frame, msg)
+}
+
+// syncMessage converts the messages the server sends to a pb.SyncMessage,
+// or returns nil for types it doesn't know.
+func syncMessage(v interface{}) *pb.SyncMessage {
+	switch m := v.(type) {
+	case *ProgressUpdate:
+		return &pb.SyncMessage{
+			Type: m.Type, Seq: m.Seq, UserId: m.UserID,
+			MangaId: m.MangaID, Chapter: int32(m.Chapter), Status: m.Status, Timestamp: m.Timestamp,
+			Version: m.Version, BaseVersion: m.BaseVersion, ClientTimestamp: m.ClientTimestamp,
+		}
+	case *LibraryAdd:
+		return &pb.SyncMessage{
+			Type: m.Type, Seq: m.Seq, UserId: m.UserID,
+			MangaId: m.MangaID, Chapter: int32(m.Chapter), Status: m.Status, Timestamp: m.Timestamp,
+		}
+	case *LibraryRemove:
+		return &pb.SyncMessage{
+			Type: m.Type, Seq: m.Seq, UserId: m.UserID,
+			MangaId: m.MangaID, Timestamp: m.Timestamp,
+		}
+	case *StatusChange:
+		return &pb.SyncMessage{
+			Type: m.Type, Seq: m.Seq, UserId: m.UserID,
+			MangaId: m.MangaID, Status: m.Status, Timestamp: m.Timestamp,
+		}
+	case *SubscriptionChange:
+		return &pb.SyncMessage{
+			Type: m.Type, Seq: m.Seq, UserId: m.UserID,
+			MangaId: m.MangaID, Subscribed: m.Subscribed, Timestamp: m.Timestamp,
+		}
+	case ProgressConflict:
+		return &pb.SyncMessage{
+			Type: m.Type, UserId: m.UserID,
+			MangaId: m.MangaID, Chapter: int32(m.Chapter), Status: m.Status,
+			Version: m.Version, ClientTimestamp: m.ClientTimestamp, Rejected: m.Rejected,
+		}
+	case Snapshot:
+		library := make([]*pb.SyncLibraryEntry, 0, len(m.Library))
+		for _, e := range m.Library {
+			library = append(library, &pb.SyncLibraryEntry{
+				MangaId: e.MangaID, Chapter: int32(e.Chapter), Status: e.Status,
+				Version: e.Version, UpdatedAt: e.UpdatedAt,
+			})
+		}
+		return &pb.SyncMessage{
+			Type: m.Type, UserId: m.UserID, RequestId: m.RequestID, LastSeq: m.LastSeq,
+			Library: library, Subscriptions: m.Subscriptions, Timestamp: m.Timestamp,
+		}
+	case ErrorMessage:
+		return &pb.SyncMessage{Type: m.Type, Error: m.Error}
+	case HeartbeatMessage:
+		return &pb.SyncMessage{Type: m.Type, Timestamp: m.Timestamp}
+	case ShutdownMessage:
+		return &pb.SyncMessage{Type: m.Type, Timestamp: m.Timestamp}
+	}
+	return nil
+}
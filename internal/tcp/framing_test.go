@@ -0,0 +1,111 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// framingMessages covers every type syncMessage converts.
+var framingMessages = []interface{}{
+	&ProgressUpdate{
+		Header:  Header{Type: TypeProgress, Seq: 7, UserID: "user_a"},
+		MangaID: "one-piece", Chapter: 1095, Status: "reading", Timestamp: 1700000000,
+		Version: 3, BaseVersion: 2, ClientTimestamp: 1700000000123,
+	},
+	&LibraryAdd{Header: Header{Type: "library_add", Seq: 8, UserID: "user_a"},
+		MangaID: "bleach", Chapter: 1, Status: "plan_to_read", Timestamp: 1700000001},
+	&LibraryRemove{Header: Header{Type: "library_remove", Seq: 9, UserID: "user_a"},
+		MangaID: "bleach", Timestamp: 1700000002},
+	&StatusChange{Header: Header{Type: "status_change", Seq: 10, UserID: "user_a"},
+		MangaID: "one-piece", Status: "completed", Timestamp: 1700000003},
+	&SubscriptionChange{Header: Header{Type: "subscription_change", Seq: 11, UserID: "user_a"},
+		MangaID: "one-piece", Subscribed: true, Timestamp: 1700000004},
+	ProgressConflict{Type: "progress_conflict", UserID: "user_a", MangaID: "one-piece",
+		Chapter: 1096, Status: "reading", Version: 4, ClientTimestamp: 1700000000456, Rejected: true},
+	Snapshot{Type: TypeSnapshot, RequestID: "r1", UserID: "user_a", LastSeq: 11,
+		Library: []LibraryEntry{
+			{MangaID: "one-piece", Chapter: 1096, Status: "reading", Version: 4, UpdatedAt: 1700000005},
+		},
+		Subscriptions: []string{"one-piece"}, Timestamp: 1700000006},
+	ErrorMessage{Type: "error", Error: "user_mismatch"},
+	HeartbeatMessage{Type: "ping", Timestamp: 1700000007},
+	ShutdownMessage{Type: TypeServerShutdown, Timestamp: 1700000008},
+}
+
+func TestEncodeMessageMatchesJSONConversion(t *testing.T) {
+	for _, v := range framingMessages {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := encodeFrame(FramingProtobuf, data)
+		if err != nil {
+			t.Fatalf("%T: encodeFrame: %v", v, err)
+		}
+		got, err := encodeMessage(FramingProtobuf, v)
+		if err != nil {
+			t.Fatalf("%T: encodeMessage: %v", v, err)
+		}
+
+		wantMsg, err := readFrame(bufio.NewReader(bytes.NewReader(want)), len(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotMsg, err := readFrame(bufio.NewReader(bytes.NewReader(got)), len(got))
+		if err != nil {
+			t.Fatalf("%T: readFrame: %v", v, err)
+		}
+		if !proto.Equal(gotMsg, wantMsg) {
+			t.Errorf("%T: got %v, want %v", v, gotMsg, wantMsg)
+		}
+	}
+}
+
+func TestDecodeProtobufProgress(t *testing.T) {
+	want := *framingMessages[0].(*ProgressUpdate)
+	frame, err := encodeMessage(FramingProtobuf, &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := readMessage(bufio.NewReader(bytes.NewReader(frame)), FramingProtobuf, len(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hdr Header
+	if err := in.decode(&hdr); err != nil || hdr != want.Header {
+		t.Fatalf("header: got %+v (%v), want %+v", hdr, err, want.Header)
+	}
+	var got ProgressUpdate
+	if err := in.decode(&got); err != nil || got != want {
+		t.Fatalf("progress: got %+v (%v), want %+v", got, err, want)
+	}
+}
+
+// benchmarkFraming encodes a progress update for the wire and reads it back,
+// as a broadcast to and an update from a client would.
+func benchmarkFraming(b *testing.B, framing string) {
+	upd := framingMessages[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		frame, err := encodeMessage(framing, upd)
+		if err != nil {
+			b.Fatal(err)
+		}
+		in, err := readMessage(bufio.NewReader(bytes.NewReader(frame)), framing, DefaultMaxFrameSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var got ProgressUpdate
+		if err := in.decode(&got); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFramingJSON(b *testing.B)     { benchmarkFraming(b, FramingJSON) }
+func BenchmarkFramingProtobuf(b *testing.B) { benchmarkFraming(b, FramingProtobuf) }
@@ -0,0 +1,171 @@
+package tcp
+
+// Protocol versions. Version 1 clients only receive progress; version 2 adds
+// the library messages and full snapshots. Clients that don't send a version
+// in their auth message get version 1.
+const (
+	ProtocolV1      = 1
+	ProtocolV2      = 2
+	ProtocolVersion = ProtocolV2 // newest version the server speaks
+)
+
+// Message types pushed to clients, and sent by them.
+const (
+	TypeProgress           = "progress"
+	TypeLibraryAdd         = "library_add"
+	TypeLibraryRemove      = "library_remove"
+	TypeStatusChange       = "status_change"
+	TypeSubscriptionChange = "subscription_change"
+	TypeSnapshotRequest    = "full_snapshot_request"
+	TypeSnapshot           = "full_snapshot"
+	TypeProgressConflict   = "progress_conflict"
+	TypeServerShutdown     = "server_shutdown"
+)
+
+// messageTypes lists the sync message types each protocol version receives.
+var messageTypes = map[int][]string{
+	ProtocolV1: {TypeProgress},
+	ProtocolV2: {TypeProgress, TypeLibraryAdd, TypeLibraryRemove, TypeStatusChange,
+		TypeSubscriptionChange, TypeSnapshot},
+}
+
+// negotiate returns the protocol version to use with a client asking for
+// requested: the highest version both sides speak.
+func negotiate(requested int) int {
+	switch {
+	case requested <= 0:
+		return ProtocolV1
+	case requested > ProtocolVersion:
+		return ProtocolVersion
+	}
+	return requested
+}
+
+// Message is a change to a user's library pushed to their devices. Every
+// message carries the user's next sequence number.
+type Message interface {
+	header() *Header
+}
+
+// Header is shared by every Message.
+type Header struct {
+	Type   string `json:"type"`
+	Seq    uint64 `json:"seq,omitempty"`
+	UserID string `json:"user_id"`
+}
+
+func (h *Header) header() *Header { return h }
+
+// ProgressUpdate represents a progress update to broadcast via TCP.
+// Seq is assigned by the server and increases by one per message for each
+// user; a seq sent by a client is ignored.
+//
+// Clients may send client_timestamp (unix ms, when the change was made) and
+// base_version (the version they last saw) for conflict resolution; updates
+// from the server carry the stored version.
+type ProgressUpdate struct {
+	Header
+	MangaID   string `json:"manga_id"`
+	Chapter   int    `json:"chapter"`
+	Status    string `json:"status,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+
+	Version         int64 `json:"version,omitempty"`
+	BaseVersion     int64 `json:"base_version,omitempty"`
+	ClientTimestamp int64 `json:"client_timestamp,omitempty"`
+}
+
+// ProgressConflict answers a progress update that lost against the stored
+// progress, which it carries. Rejected is set when the reject_stale policy
+// refused the update rather than ignoring it.
+type ProgressConflict struct {
+	Type            string `json:"type"` // "progress_conflict"
+	UserID          string `json:"user_id"`
+	MangaID         string `json:"manga_id"`
+	Chapter         int    `json:"chapter"`
+	Status          string `json:"status"`
+	Version         int64  `json:"version"`
+	ClientTimestamp int64  `json:"client_timestamp"`
+	Rejected        bool   `json:"rejected"`
+}
+
+// ProgressResult is the outcome of storing a client's progress update,
+// with the authoritative state.
+type ProgressResult struct {
+	Applied         bool
+	Rejected        bool
+	Chapter         int
+	Status          string
+	Version         int64
+	ClientTimestamp int64
+}
+
+// ProgressStore persists progress sent by a TCP client, resolving conflicts
+// with the stored progress. Stored updates reach the user's devices through
+// the event bus.
+type ProgressStore func(upd ProgressUpdate) (*ProgressResult, error)
+
+// LibraryAdd announces a manga added to the user's library.
+type LibraryAdd struct {
+	Header
+	MangaID   string `json:"manga_id"`
+	Chapter   int    `json:"chapter"`
+	Status    string `json:"status,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// LibraryRemove announces a manga removed from the user's library.
+type LibraryRemove struct {
+	Header
+	MangaID   string `json:"manga_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// StatusChange announces a new reading status for a library entry.
+type StatusChange struct {
+	Header
+	MangaID   string `json:"manga_id"`
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// SubscriptionChange announces a chapter notification subscription turned
+// on or off.
+type SubscriptionChange struct {
+	Header
+	MangaID    string `json:"manga_id"`
+	Subscribed bool   `json:"subscribed"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// SnapshotRequest asks for the user's whole library. RequestID, if set, is
+// echoed in the response.
+type SnapshotRequest struct {
+	Type      string `json:"type"` // "full_snapshot_request"
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Snapshot is the answer to a SnapshotRequest. It reflects at least every
+// message up to LastSeq; later messages follow it on the connection.
+type Snapshot struct {
+	Type          string         `json:"type"` // "full_snapshot"
+	RequestID     string         `json:"request_id,omitempty"`
+	UserID        string         `json:"user_id"`
+	LastSeq       uint64         `json:"last_seq"`
+	Library       []LibraryEntry `json:"library"`
+	Subscriptions []string       `json:"subscriptions"`
+	Timestamp     int64          `json:"timestamp"`
+}
+
+// LibraryEntry is one manga in a Snapshot.
+type LibraryEntry struct {
+	MangaID   string `json:"manga_id"`
+	Chapter   int    `json:"chapter"`
+	Status    string `json:"status"`
+	Version   int64  `json:"version"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// SnapshotProvider loads a user's library and notification subscriptions
+// for full_snapshot responses.
+type SnapshotProvider func(userID string) (library []LibraryEntry, subscriptions []string, err error)
@@ -0,0 +1,361 @@
+package tcp
+
+import (
+	"container/list"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DefaultReplaySize is the number of messages kept per user for clients that
+// reconnect with last_seq.
+const DefaultReplaySize = 100
+
+// DefaultReplayUsers is the number of users an in-memory log keeps history
+// for; the least recently active are forgotten first.
+const DefaultReplayUsers = 10000
+
+// LogEntry is one encoded Message in a ReplayLog.
+type LogEntry struct {
+	Seq  uint64
+	Type string
+	Data []byte // JSON without the trailing newline; never appended to
+
+	// msg is the Message encoded into Data, set only on entries returned by
+	// Append so live deliveries can frame it without decoding Data.
+	msg Message
+}
+
+// frame encodes e for a connection using framing.
+func (e LogEntry) frame(framing string) ([]byte, error) {
+	if e.msg != nil {
+		return encodeMessage(framing, e.msg)
+	}
+	return encodeFrame(framing, e.Data)
+}
+
+// ReplayLog numbers each user's messages and keeps the most recent ones so a
+// reconnecting client can catch up on what it missed.
+type ReplayLog interface {
+	// Append assigns msg the user's next sequence number, encodes and stores
+	// it. The returned entry is usable even when storing it failed.
+	Append(msg Message) (LogEntry, error)
+	// Last returns the user's latest sequence number, 0 if there is none.
+	Last(userID string) (uint64, error)
+	// Since returns the stored entries with a sequence number above after,
+	// oldest first, and the user's latest sequence number. complete is false
+	// when entries after `after` have already been dropped from the log, or
+	// when after is ahead of the log (it was reset) and everything retained
+	// is returned instead.
+	Since(userID string, after uint64) (entries []LogEntry, last uint64, complete bool, err error)
+	// Record stores an entry numbered by another node, so the log keeps
+	// numbering after it. Entries at or below the latest seq are ignored.
+	Record(userID string, e LogEntry) error
+}
+
+// encode stamps msg with seq and encodes it.
+func encode(msg Message, seq uint64) (LogEntry, error) {
+	h := msg.header()
+	h.Seq = seq
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("encode %s: %w", h.Type, err)
+	}
+	return LogEntry{Seq: seq, Type: h.Type, Data: data, msg: msg}, nil
+}
+
+// since filters entries (oldest first) for Since implementations.
+func since(entries []LogEntry, after, last uint64) ([]LogEntry, bool) {
+	if after == last {
+		return nil, true
+	}
+	reset := after > last
+	if reset {
+		after = 0
+	}
+	var out []LogEntry
+	for _, e := range entries {
+		if e.Seq > after {
+			out = append(out, e)
+		}
+	}
+	return out, !reset && len(out) > 0 && out[0].Seq == after+1
+}
+
+// memoryLog is a ReplayLog that lives in memory; sequence numbers restart
+// with the process, and for a user who is forgotten to make room for others.
+type memoryLog struct {
+	size     int
+	maxUsers int
+
+	mu    sync.Mutex
+	users map[string]*list.Element // of *userLog
+	lru   *list.List               // most recently used first
+}
+
+type userLog struct {
+	userID  string
+	last    uint64
+	entries []LogEntry // oldest first, at most size
+}
+
+// NewMemoryLog creates an in-memory ReplayLog keeping size messages for each
+// of the maxUsers most recently active users.
+func NewMemoryLog(size, maxUsers int) ReplayLog {
+	if maxUsers <= 0 {
+		maxUsers = DefaultReplayUsers
+	}
+	return &memoryLog{size: size, maxUsers: maxUsers, users: make(map[string]*list.Element), lru: list.New()}
+}
+
+// user returns userID's log, marking it most recently used, or nil if there
+// is none and create is false; l.mu must be held.
+func (l *memoryLog) user(userID string, create bool) *userLog {
+	if el, ok := l.users[userID]; ok {
+		l.lru.MoveToFront(el)
+		return el.Value.(*userLog)
+	}
+	if !create {
+		return nil
+	}
+	u := &userLog{userID: userID}
+	l.users[userID] = l.lru.PushFront(u)
+	for l.lru.Len() > l.maxUsers {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.users, oldest.Value.(*userLog).userID)
+	}
+	return u
+}
+
+func (l *memoryLog) Append(msg Message) (LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.user(msg.header().UserID, true)
+	e, err := encode(msg, u.last+1)
+	if err != nil {
+		return e, err
+	}
+	u.last = e.Seq
+	u.entries = append(u.entries, LogEntry{Seq: e.Seq, Type: e.Type, Data: e.Data})
+	if len(u.entries) > l.size {
+		u.entries = u.entries[len(u.entries)-l.size:]
+	}
+	return e, nil
+}
+
+func (l *memoryLog) Record(userID string, e LogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.user(userID, true)
+	if e.Seq <= u.last {
+		return nil
+	}
+	u.last = e.Seq
+	u.entries = append(u.entries, e)
+	if len(u.entries) > l.size {
+		u.entries = u.entries[len(u.entries)-l.size:]
+	}
+	return nil
+}
+
+func (l *memoryLog) Last(userID string) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if u := l.user(userID, false); u != nil {
+		return u.last, nil
+	}
+	return 0, nil
+}
+
+func (l *memoryLog) Since(userID string, after uint64) ([]LogEntry, uint64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.user(userID, false)
+	if u == nil {
+		return nil, 0, after == 0, nil
+	}
+	out, complete := since(u.entries, after, u.last)
+	return out, u.last, complete, nil
+}
+
+// dbLog is a ReplayLog stored in the sync_log table, so sequence numbers and
+// history survive restarts.
+type dbLog struct {
+	db       *sql.DB
+	size     int
+	maxUsers int
+
+	mu   sync.Mutex
+	last map[string]*list.Element // of *lastSeq, cached latest seq per user
+	lru  *list.List               // most recently used first
+}
+
+type lastSeq struct {
+	userID string
+	seq    uint64
+}
+
+// NewDBLog creates a ReplayLog backed by the sync_log table keeping size
+// messages per user. The latest seq of the DefaultReplayUsers most recently
+// active users is cached; others are read from the table again.
+func NewDBLog(db *sql.DB, size int) ReplayLog {
+	return &dbLog{
+		db:       db,
+		size:     size,
+		maxUsers: DefaultReplayUsers,
+		last:     make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// lastSeq returns the user's latest sequence number; l.mu must be held.
+func (l *dbLog) lastSeq(userID string) (uint64, error) {
+	if el, ok := l.last[userID]; ok {
+		l.lru.MoveToFront(el)
+		return el.Value.(*lastSeq).seq, nil
+	}
+	var seq sql.NullInt64
+	if err := l.db.QueryRow(`SELECT MAX(seq) FROM sync_log WHERE user_id = ?`, userID).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("query last seq: %w", err)
+	}
+	l.setLast(userID, uint64(seq.Int64))
+	return uint64(seq.Int64), nil
+}
+
+// setLast caches seq as the user's latest, forgetting the least recently
+// used user when the cache is full; l.mu must be held.
+func (l *dbLog) setLast(userID string, seq uint64) {
+	if el, ok := l.last[userID]; ok {
+		el.Value.(*lastSeq).seq = seq
+		l.lru.MoveToFront(el)
+		return
+	}
+	l.last[userID] = l.lru.PushFront(&lastSeq{userID: userID, seq: seq})
+	for l.lru.Len() > l.maxUsers {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.last, oldest.Value.(*lastSeq).userID)
+	}
+}
+
+// forgetLast drops the user's cached seq; l.mu must be held.
+func (l *dbLog) forgetLast(userID string) {
+	if el, ok := l.last[userID]; ok {
+		l.lru.Remove(el)
+		delete(l.last, userID)
+	}
+}
+
+func (l *dbLog) Append(msg Message) (LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	userID := msg.header().UserID
+	var e LogEntry
+	// Another node sharing the database may have taken the cached next seq;
+	// the insert then fails and is retried once with a fresh one.
+	for attempt := 0; ; attempt++ {
+		last, err := l.lastSeq(userID)
+		if err != nil {
+			e, _ = encode(msg, 0) // still deliver live, just unnumbered
+			return e, err
+		}
+		if e, err = encode(msg, last+1); err != nil {
+			return e, err
+		}
+		_, err = l.db.Exec(
+			`INSERT INTO sync_log (user_id, seq, type, payload) VALUES (?, ?, ?, ?)`,
+			userID, e.Seq, e.Type, string(e.Data),
+		)
+		if err == nil {
+			break
+		}
+		l.forgetLast(userID)
+		if attempt > 0 {
+			return e, fmt.Errorf("append sync log: %w", err)
+		}
+	}
+	l.setLast(userID, e.Seq)
+	l.trim(userID, e.Seq)
+	return e, nil
+}
+
+// Record stores the entry unless it is already there, as it is when the
+// nodes share the database.
+func (l *dbLog) Record(userID string, e LogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last, err := l.lastSeq(userID)
+	if err != nil {
+		return err
+	}
+	if e.Seq <= last {
+		return nil
+	}
+	if _, err := l.db.Exec(
+		`INSERT OR IGNORE INTO sync_log (user_id, seq, type, payload) VALUES (?, ?, ?, ?)`,
+		userID, e.Seq, e.Type, string(e.Data),
+	); err != nil {
+		return fmt.Errorf("record sync log: %w", err)
+	}
+	l.setLast(userID, e.Seq)
+	l.trim(userID, e.Seq)
+	return nil
+}
+
+// trim drops the user's entries older than the newest size; l.mu must be
+// held.
+func (l *dbLog) trim(userID string, last uint64) {
+	if last > uint64(l.size) {
+		_, _ = l.db.Exec(`DELETE FROM sync_log WHERE user_id = ? AND seq <= ?`,
+			userID, last-uint64(l.size))
+	}
+}
+
+func (l *dbLog) Last(userID string) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastSeq(userID)
+}
+
+func (l *dbLog) Since(userID string, after uint64) ([]LogEntry, uint64, bool, error) {
+	last, err := l.Last(userID)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if after == last {
+		return nil, last, true, nil
+	}
+
+	rows, err := l.db.Query(
+		`SELECT seq, type, payload FROM sync_log WHERE user_id = ? ORDER BY seq`,
+		userID,
+	)
+	if err != nil {
+		return nil, last, false, fmt.Errorf("query sync log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []LogEntry
+	for rows.Next() {
+		var e LogEntry
+		var payload string
+		if err := rows.Scan(&e.Seq, &e.Type, &payload); err != nil {
+			return nil, last, false, err
+		}
+		e.Data = []byte(payload)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, last, false, err
+	}
+	out, complete := since(entries, after, last)
+	return out, last, complete, nil
+}
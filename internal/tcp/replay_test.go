@@ -0,0 +1,58 @@
+package tcp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"mangahub/internal/database"
+)
+
+func TestMemoryLogForgetsLeastRecentUser(t *testing.T) {
+	l := NewMemoryLog(10, 2)
+	for _, userID := range []string{"a", "b", "a", "c"} {
+		if _, err := l.Append(progress(userID, 1, 1)); err != nil {
+			t.Fatalf("Append(%s): %v", userID, err)
+		}
+	}
+
+	want := map[string]uint64{"a": 2, "b": 0, "c": 1}
+	for userID, seq := range want {
+		if last, _ := l.Last(userID); last != seq {
+			t.Errorf("Last(%s) = %d, want %d", userID, last, seq)
+		}
+	}
+	if _, _, complete, _ := l.Since("b", 1); complete {
+		t.Error("Since on a forgotten user reported a complete replay")
+	}
+}
+
+func TestDBLogBoundsSeqCache(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	defer db.Close()
+	l := NewDBLog(db, 10).(*dbLog)
+	l.maxUsers = 2
+
+	for _, userID := range []string{"a", "b", "a", "c"} {
+		if _, err := l.Append(progress(userID, 1, 1)); err != nil {
+			t.Fatalf("Append(%s): %v", userID, err)
+		}
+	}
+	if n := len(l.last); n != 2 {
+		t.Fatalf("%d users cached, want 2", n)
+	}
+	if _, ok := l.last["b"]; ok {
+		t.Fatal("b still cached, want the least recently active user forgotten")
+	}
+
+	// A forgotten user keeps numbering from the table.
+	e, err := l.Append(progress("b", 2, 1))
+	if err != nil {
+		t.Fatalf("Append(b): %v", err)
+	}
+	if e.Seq != 2 {
+		t.Fatalf("b's next seq is %d, want 2", e.Seq)
+	}
+}
@@ -2,6 +2,8 @@ package tcp
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,41 +11,132 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"mangahub/internal/auth"
+	"mangahub/internal/broker"
+	"mangahub/internal/events"
 )
 
 // AuthMessage is sent by the TCP client immediately after connecting.
 // It authenticates the client and registers it for progress updates.
+// The user is taken from the JWT; user_id is only needed when an admin or
+// service token connects on behalf of a user.
+//
+// A reconnecting client sends the seq of the last message it processed as
+// last_seq; the server then replays the messages it missed before any live
+// ones. Without last_seq nothing is replayed. Version is the newest protocol
+// version the client speaks (1 if omitted), and Framing the wire format it
+// wants after the handshake (json if omitted).
 type AuthMessage struct {
-	Type   string `json:"type"`             // "auth"
-	UserID string `json:"user_id,omitempty"` // user identifier
-	Token  string `json:"token,omitempty"` // optional JWT token (reserved)
+	Type    string  `json:"type"`               // "auth"
+	UserID  string  `json:"user_id,omitempty"`  // user identifier
+	Token   string  `json:"token"`              // JWT issued by the HTTP API
+	LastSeq *uint64 `json:"last_seq,omitempty"` // last seq seen, for replay
+	Version int     `json:"version,omitempty"`  // protocol version requested
+	Framing string  `json:"framing,omitempty"`  // "json" or "protobuf"
 }
 
 // AuthResponse is sent by the server to confirm or reject registration.
+// On success it carries the negotiated protocol version and framing and the
+// message types the connection will receive; LastSeq is the user's latest
+// sequence number and Replayed the number of missed messages that follow.
+// Truncated means some missed messages are no longer in the log and the
+// client should resync (with a full_snapshot_request on version 2).
 type AuthResponse struct {
-	Type   string `json:"type"`   // "auth_response"
-	Status string `json:"status"` // "ok" or "error"
-	Error  string `json:"error,omitempty"`
+	Type      string   `json:"type"`              // "auth_response"
+	Status    string   `json:"status"`            // "ok" or "error"
+	UserID    string   `json:"user_id,omitempty"` // authenticated user on success
+	Version   int      `json:"version,omitempty"`
+	Framing   string   `json:"framing,omitempty"`
+	Types     []string `json:"types,omitempty"`
+	LastSeq   uint64   `json:"last_seq,omitempty"`
+	Replayed  int      `json:"replayed,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+	Error     string   `json:"error,omitempty"`
 }
 
-// ProgressUpdate represents a progress update to broadcast via TCP.
-type ProgressUpdate struct {
-	Type      string `json:"type"` // always "progress"
-	UserID    string `json:"user_id"`
-	MangaID   string `json:"manga_id"`
-	Chapter   int    `json:"chapter"`
-	Timestamp int64  `json:"timestamp"`
+// ErrorMessage reports a rejected message on an authenticated connection.
+type ErrorMessage struct {
+	Type  string `json:"type"` // "error"
+	Error string `json:"error"`
 }
 
 // Server is the TCP sync server implementation for UC-007/UC-008.
 type Server struct {
-	Port       string
+	Port       string // port, or host:port to listen on one interface
 	MaxClients int
 
+	// JWTSecret validates client tokens; it must match the HTTP API's.
+	JWTSecret []byte
+	// TLSConfig, if set, makes the server accept TLS connections only.
+	TLSConfig *tls.Config
+
+	// Connection health; see the Default* constants.
+	PingInterval  time.Duration
+	IdleTimeout   time.Duration
+	WriteTimeout  time.Duration
+	SendQueueSize int
+	// MaxFrameSize bounds each JSON line or protobuf frame a client sends.
+	MaxFrameSize int
+
+	// Replay numbers and keeps recent messages for reconnecting clients.
+	// Start falls back to an in-memory log of ReplaySize messages for each
+	// of the ReplayUsers most recently active users.
+	Replay      ReplayLog
+	ReplaySize  int
+	ReplayUsers int
+
+	// Progress, if set, stores progress sent by user connections and
+	// resolves conflicts; the stored update comes back through the Bus.
+	// Without it (and for service connections, which relay progress that is
+	// already stored) progress is broadcast as received.
+	Progress ProgressStore
+
+	// Snapshots answers full_snapshot_request; without it such requests
+	// get a snapshot_unavailable error.
+	Snapshots SnapshotProvider
+
+	// seqMu orders broadcasts against replays and snapshots so a client
+	// sees every message exactly once.
+	seqMu       sync.Mutex
 	mu          sync.RWMutex
-	connections map[string]map[net.Conn]struct{} // userID -> set of conns
-	Broadcast   chan ProgressUpdate
+	connections map[string]map[*client]struct{} // userID -> set of conns
+	Broadcast   chan Message
+
+	// Bus, if set, supplies the progress, library and subscription changes
+	// published by other services and receives the progress sent by TCP
+	// clients.
+	Bus *events.Bus
+
+	// Broker, if set, carries broadcasts to the users' connections on other
+	// nodes and theirs to this one. Nodes should share the replay log's
+	// database so sequence numbers stay consistent.
+	Broker broker.Broker
+
+	// Lifecycle; see Start and Shutdown.
+	lifeMu      sync.Mutex
+	ln          net.Listener
+	started     bool
+	handshakes  map[net.Conn]struct{} // connections still authenticating
+	unsubscribe []func()
+	quit        chan struct{} // closed by Shutdown
+	quitOnce    sync.Once
+	loopDone    chan struct{}  // closed once broadcastLoop has drained
+	wg          sync.WaitGroup // Start's accept loop and every connection
+}
+
+// BrokerTopic is the broker topic TCP broadcasts travel on.
+const BrokerTopic = "tcp_sync"
+
+// remoteEntry is a broadcast relayed between nodes.
+type remoteEntry struct {
+	UserID string          `json:"user_id"`
+	Seq    uint64          `json:"seq"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
 }
 
 // NewServer creates a new TCP sync server with sane defaults.
@@ -56,16 +149,35 @@ func NewServer(port string, maxClients int) *Server {
 	}
 
 	return &Server{
-		Port:       port,
-		MaxClients: maxClients,
-		connections: make(map[string]map[net.Conn]struct{}),
-		Broadcast:   make(chan ProgressUpdate, 64),
+		Port:          port,
+		MaxClients:    maxClients,
+		PingInterval:  DefaultPingInterval,
+		IdleTimeout:   DefaultIdleTimeout,
+		WriteTimeout:  DefaultWriteTimeout,
+		SendQueueSize: DefaultSendQueueSize,
+		MaxFrameSize:  DefaultMaxFrameSize,
+		ReplaySize:    DefaultReplaySize,
+		ReplayUsers:   DefaultReplayUsers,
+		connections:   make(map[string]map[*client]struct{}),
+		Broadcast:     make(chan Message, 64),
+		handshakes:    make(map[net.Conn]struct{}),
+		quit:          make(chan struct{}),
+		loopDone:      make(chan struct{}),
 	}
 }
 
 // FromEnv constructs a Server using environment variables:
 // - MANGAHUB_TCP_PORT
 // - MANGAHUB_TCP_MAX_CLIENTS
+// - MANGAHUB_TCP_PING_INTERVAL (Go duration, default 30s)
+// - MANGAHUB_TCP_IDLE_TIMEOUT (Go duration, default 90s)
+// - MANGAHUB_TCP_WRITE_TIMEOUT (Go duration, default 10s)
+// - MANGAHUB_TCP_SEND_QUEUE (messages buffered per connection, default 256)
+// - MANGAHUB_TCP_REPLAY_SIZE (updates kept per user for replay, default 100)
+// - MANGAHUB_TCP_REPLAY_USERS (users kept by the in-memory replay log, default 10000)
+// - MANGAHUB_TCP_MAX_FRAME_SIZE (bytes per client line or frame, default 65536)
+//
+// JWTSecret must be set by the caller.
 func FromEnv() *Server {
 	port := os.Getenv("MANGAHUB_TCP_PORT")
 	maxClients := 0
@@ -74,118 +186,445 @@ func FromEnv() *Server {
 			maxClients = n
 		}
 	}
-	return NewServer(port, maxClients)
+	s := NewServer(port, maxClients)
+
+	durations := map[string]*time.Duration{
+		"MANGAHUB_TCP_PING_INTERVAL": &s.PingInterval,
+		"MANGAHUB_TCP_IDLE_TIMEOUT":  &s.IdleTimeout,
+		"MANGAHUB_TCP_WRITE_TIMEOUT": &s.WriteTimeout,
+	}
+	for key, dst := range durations {
+		if v := os.Getenv(key); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				*dst = d
+			}
+		}
+	}
+	if v := os.Getenv("MANGAHUB_TCP_SEND_QUEUE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			s.SendQueueSize = n
+		}
+	}
+	if v := os.Getenv("MANGAHUB_TCP_REPLAY_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			s.ReplaySize = n
+		}
+	}
+	if v := os.Getenv("MANGAHUB_TCP_REPLAY_USERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			s.ReplayUsers = n
+		}
+	}
+	if v := os.Getenv("MANGAHUB_TCP_MAX_FRAME_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			s.MaxFrameSize = n
+		}
+	}
+	return s
 }
 
-// Start begins listening on the configured port and handling connections.
-func (s *Server) Start() error {
-	ln, err := net.Listen("tcp", ":"+s.Port)
+// Start listens on the configured port and handles connections until ctx is
+// cancelled or Shutdown is called. Cancelling ctx shuts the server down
+// gracefully within DefaultShutdownTimeout. Start returns nil once the
+// listener is closed; Shutdown reports when the connections are done.
+func (s *Server) Start(ctx context.Context) error {
+	addr := s.Port
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("tcp listen error: %w", err)
 	}
-	log.Println("TCP progress sync listening on :" + s.Port)
+	if s.TLSConfig != nil {
+		ln = tls.NewListener(ln, s.TLSConfig)
+		log.Println("TCP progress sync listening on " + ln.Addr().String() + " (TLS)")
+	} else {
+		log.Println("TCP progress sync listening on " + ln.Addr().String())
+	}
+
+	s.lifeMu.Lock()
+	select {
+	case <-s.quit:
+		s.lifeMu.Unlock()
+		ln.Close()
+		return nil
+	default:
+	}
+	s.ln = ln
+	s.started = true
+	s.wg.Add(1)
+	defer s.wg.Done()
 
+	if s.Replay == nil {
+		s.Replay = NewMemoryLog(s.ReplaySize, s.ReplayUsers)
+	}
 	go s.broadcastLoop()
+	if s.Bus != nil {
+		s.unsubscribe = append(s.unsubscribe, s.Bus.Subscribe("tcp", s.handleEvent,
+			events.ProgressUpdated, events.LibraryChanged, events.SubscriptionChanged))
+	}
+	if s.Broker != nil {
+		s.unsubscribe = append(s.unsubscribe, s.Broker.Subscribe(BrokerTopic, s.handleRemote))
+	}
+	s.lifeMu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Println("TCP: shutdown:", err)
+		}
+	})
+	defer stop()
 
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			select {
+			case <-s.quit:
+				return nil
+			default:
+			}
 			log.Println("tcp accept error:", err)
 			continue
 		}
-		go s.handleConn(conn)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
 	}
 }
 
+// Addr returns the address the server listens on, or nil before Start.
+func (s *Server) Addr() net.Addr {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
 // handleConn implements UC-007 connection and registration flow.
 func (s *Server) handleConn(conn net.Conn) {
 	defer conn.Close()
+	if !s.trackHandshake(conn) {
+		return // shutting down
+	}
+	defer s.untrackHandshake(conn)
 
 	reader := bufio.NewReader(conn)
 
-	// 1) Read authentication message from client
-	line, err := reader.ReadBytes('\n')
+	// 1) Read authentication message from client. The handshake (including
+	// a TLS handshake) must finish within DefaultAuthTimeout.
+	_ = conn.SetDeadline(time.Now().Add(DefaultAuthTimeout))
+	line, err := readLine(reader, s.MaxFrameSize)
 	if err != nil {
 		log.Println("failed to read auth message:", err)
+		if errors.Is(err, ErrFrameTooLarge) {
+			_ = sendAuthResponse(conn, "error", ErrFrameTooLarge.Error(), "")
+		}
 		return
 	}
 
 	var authMsg AuthMessage
 	if err := json.Unmarshal(line, &authMsg); err != nil {
 		log.Println("invalid auth message:", err)
-		_ = sendAuthResponse(conn, "error", "invalid_auth_message")
+		_ = sendAuthResponse(conn, "error", "invalid_auth_message", "")
 		return
 	}
 
 	if authMsg.Type != "auth" {
-		_ = sendAuthResponse(conn, "error", "expected_auth_message")
+		_ = sendAuthResponse(conn, "error", "expected_auth_message", "")
 		return
 	}
 
-	userID := authMsg.UserID
-	if userID == "" {
-		_ = sendAuthResponse(conn, "error", "missing_user_id")
+	userID, role, err := s.authenticate(authMsg)
+	if err != nil {
+		log.Printf("TCP: auth rejected from %s: %v\n", conn.RemoteAddr(), err)
+		_ = sendAuthResponse(conn, "error", err.Error(), "")
 		return
 	}
 
+	// The acknowledgement and any replay are queued before registration so
+	// they precede live broadcasts.
+	c := newClient(conn, userID, role, negotiate(authMsg.Version),
+		negotiateFraming(authMsg.Framing), s.SendQueueSize)
+
 	// A2: Server at capacity.
-	if err := s.registerClient(userID, conn); err != nil {
-		if errors.Is(err, ErrServerAtCapacity) {
-			_ = sendAuthResponse(conn, "error", "server_at_capacity")
+	if err := s.attach(c, authMsg.LastSeq); err != nil {
+		if errors.Is(err, ErrServerAtCapacity) || errors.Is(err, ErrServerClosed) {
+			_ = sendAuthResponse(conn, "error", err.Error(), "")
 		} else {
-			_ = sendAuthResponse(conn, "error", "registration_failed")
+			_ = sendAuthResponse(conn, "error", "registration_failed", "")
 		}
 		return
 	}
+	defer s.unregisterClient(c)
+	s.untrackHandshake(conn)
 
-	log.Printf("TCP: user %s connected\n", userID)
+	_ = conn.SetDeadline(time.Time{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.writeLoop(c)
+	}()
 
-	// Acknowledge successful registration to client.
-	if err := sendAuthResponse(conn, "ok", ""); err != nil {
-		log.Println("failed to send auth_ok:", err)
-		s.unregisterClient(userID, conn)
-		return
-	}
+	log.Printf("TCP: user %s connected (protocol v%d, %s)\n", userID, c.version, c.framing)
 
-	// 2) Main loop: receive progress updates from this client.
+	// 2) Main loop: receive messages from this client. Any message,
+	// including a pong, keeps the connection alive for IdleTimeout.
 	for {
-		line, err := reader.ReadBytes('\n')
+		_ = conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		in, err := readMessage(reader, c.framing, s.MaxFrameSize)
 		if err != nil {
+			var ne net.Error
+			switch {
+			case errors.As(err, &ne) && ne.Timeout():
+				log.Printf("TCP: user %s idle for %s, disconnecting\n", userID, s.IdleTimeout)
+			case errors.Is(err, ErrFrameTooLarge):
+				log.Printf("TCP: user %s sent more than %d bytes in one message, disconnecting\n",
+					userID, s.MaxFrameSize)
+			}
 			break
 		}
 
-		var upd ProgressUpdate
-		if err := json.Unmarshal(line, &upd); err != nil {
-			log.Printf("TCP: failed to unmarshal progress from %s: %v\n", userID, err)
+		var hdr Header
+		if err := in.decode(&hdr); err != nil {
+			log.Printf("TCP: failed to unmarshal message from %s: %v\n", userID, err)
 			continue
 		}
-		if upd.Type != "progress" {
-			// Ignore unknown message types for now.
-			continue
+		switch hdr.Type {
+		case TypeProgress:
+			s.handleProgress(c, in)
+		case TypeSnapshotRequest:
+			s.handleSnapshotRequest(c, in)
+		case "ping":
+			s.enqueue(c, HeartbeatMessage{Type: "pong", Timestamp: time.Now().Unix()})
+		default:
+			// pong and unknown message types only refresh the deadline.
 		}
+	}
 
-		// Ensure the user ID on the update matches the authenticated user.
-		if upd.UserID == "" {
-			upd.UserID = userID
-		}
+	log.Printf("TCP: user %s disconnected\n", userID)
+}
 
-		log.Printf("TCP: received progress from %s: manga=%s, chapter=%d\n",
-			userID, upd.MangaID, upd.Chapter)
+// handleProgress broadcasts a progress update sent by client c to the user's
+// other devices and publishes it on the bus.
+func (s *Server) handleProgress(c *client, in inbound) {
+	var upd ProgressUpdate
+	if err := in.decode(&upd); err != nil {
+		log.Printf("TCP: failed to unmarshal progress from %s: %v\n", c.userID, err)
+		return
+	}
 
-		s.Broadcast <- upd
+	// Connections may only publish progress for their own user.
+	if upd.UserID == "" {
+		upd.UserID = c.userID
+	} else if upd.UserID != c.userID {
+		log.Printf("TCP: rejected progress for %s from connection of %s\n", upd.UserID, c.userID)
+		s.enqueue(c, ErrorMessage{Type: "error", Error: "user_mismatch"})
+		return
 	}
 
-	s.unregisterClient(userID, conn)
-	log.Printf("TCP: user %s disconnected\n", userID)
+	log.Printf("TCP: received progress from %s: manga=%s, chapter=%d\n",
+		c.userID, upd.MangaID, upd.Chapter)
+
+	if s.Progress != nil && c.role != auth.RoleService {
+		s.storeProgress(c, upd)
+		return
+	}
+
+	s.submit(&upd)
+	if s.Bus != nil {
+		s.Bus.Publish(events.Event{
+			Type:   events.ProgressUpdated,
+			UserID: upd.UserID,
+			Source: "tcp",
+			Payload: events.Progress{
+				UserID:    upd.UserID,
+				MangaID:   upd.MangaID,
+				Chapter:   upd.Chapter,
+				Status:    upd.Status,
+				Timestamp: upd.Timestamp,
+
+				Version:         upd.Version,
+				ClientTimestamp: upd.ClientTimestamp,
+			},
+		})
+	}
+}
+
+// storeProgress hands a client's update to the Progress store and answers
+// with the stored state if it lost a conflict.
+func (s *Server) storeProgress(c *client, upd ProgressUpdate) {
+	res, err := s.Progress(upd)
+	if err != nil {
+		log.Printf("TCP: storing progress from %s failed: %v\n", c.userID, err)
+		s.enqueue(c, ErrorMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	if res.Applied {
+		return
+	}
+	s.enqueue(c, ProgressConflict{
+		Type:            TypeProgressConflict,
+		UserID:          c.userID,
+		MangaID:         upd.MangaID,
+		Chapter:         res.Chapter,
+		Status:          res.Status,
+		Version:         res.Version,
+		ClientTimestamp: res.ClientTimestamp,
+		Rejected:        res.Rejected,
+	})
+}
+
+// handleSnapshotRequest answers a full_snapshot_request with the user's
+// library and subscriptions as of the latest seq.
+func (s *Server) handleSnapshotRequest(c *client, in inbound) {
+	if !c.types[TypeSnapshot] {
+		s.enqueue(c, ErrorMessage{Type: "error", Error: "unsupported_message"})
+		return
+	}
+	if s.Snapshots == nil {
+		s.enqueue(c, ErrorMessage{Type: "error", Error: "snapshot_unavailable"})
+		return
+	}
+	var req SnapshotRequest
+	_ = in.decode(&req)
+
+	// Messages broadcast while the snapshot is built would otherwise land
+	// between LastSeq and the snapshot on the wire, so they are held back
+	// for this connection until the snapshot is queued. The query itself
+	// runs without seqMu, so broadcasts to other users carry on.
+	s.seqMu.Lock()
+	last, err := s.Replay.Last(c.userID)
+	if err != nil {
+		log.Printf("TCP: snapshot seq lookup for %s failed: %v\n", c.userID, err)
+	}
+	c.holding = true
+	s.seqMu.Unlock()
+
+	library, subscriptions, err := s.Snapshots(c.userID)
+
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+	defer s.release(c)
+	if err != nil {
+		log.Printf("TCP: snapshot for %s failed: %v\n", c.userID, err)
+		s.enqueue(c, ErrorMessage{Type: "error", Error: "snapshot_failed"})
+		return
+	}
+	if library == nil {
+		library = []LibraryEntry{}
+	}
+	if subscriptions == nil {
+		subscriptions = []string{}
+	}
+	s.enqueue(c, Snapshot{
+		Type:          TypeSnapshot,
+		RequestID:     req.RequestID,
+		UserID:        c.userID,
+		LastSeq:       last,
+		Library:       library,
+		Subscriptions: subscriptions,
+		Timestamp:     time.Now().Unix(),
+	})
+}
+
+// release queues the broadcasts held for c while a snapshot was built;
+// s.seqMu must be held.
+func (s *Server) release(c *client) {
+	held := c.held
+	c.holding, c.held = false, nil
+	for _, frame := range held {
+		if !s.enqueueFrame(c, frame) {
+			return
+		}
+	}
 }
 
 // ErrServerAtCapacity indicates the server cannot accept more clients.
 var ErrServerAtCapacity = errors.New("server_at_capacity")
 
-func (s *Server) registerClient(userID string, conn net.Conn) error {
+// attach queues the auth acknowledgement and the messages missed since
+// lastSeq for c, then registers it for live broadcasts. Holding seqMu keeps
+// broadcasts from landing between the replay and the registration.
+func (s *Server) attach(c *client, lastSeq *uint64) error {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+
+	resp := AuthResponse{
+		Type:    "auth_response",
+		Status:  "ok",
+		UserID:  c.userID,
+		Version: c.version,
+		Framing: c.framing,
+		Types:   messageTypes[c.version],
+	}
+
+	// Without last_seq only the current seq is reported.
+	var logged, missed []LogEntry
+	var last uint64
+	var err error
+	complete := true
+	if lastSeq != nil {
+		logged, last, complete, err = s.Replay.Since(c.userID, *lastSeq)
+	} else {
+		last, err = s.Replay.Last(c.userID)
+	}
+	if err != nil {
+		log.Printf("TCP: replay lookup for %s failed: %v\n", c.userID, err)
+	}
+	for _, e := range logged {
+		if c.types[e.Type] {
+			missed = append(missed, e)
+		}
+	}
+	// Leave room in the send queue for the acknowledgement.
+	if max := s.SendQueueSize - 1; len(missed) > max {
+		missed, complete = missed[len(missed)-max:], false
+	}
+	resp.LastSeq = last
+	resp.Replayed = len(missed)
+	resp.Truncated = !complete
+
+	if err := s.registerClient(c); err != nil {
+		return err
+	}
+	// The acknowledgement is a JSON line whatever the framing; the client
+	// switches framing after reading it.
+	ack, _ := json.Marshal(resp)
+	s.enqueueFrame(c, append(ack, '\n'))
+	for _, e := range missed {
+		frame, err := e.frame(c.framing)
+		if err != nil {
+			log.Printf("TCP: failed to encode replay seq %d for %s: %v\n", e.Seq, c.userID, err)
+			continue
+		}
+		s.enqueueFrame(c, frame)
+	}
+	if lastSeq != nil {
+		log.Printf("TCP: replayed %d message(s) to %s after seq %d (latest %d)\n",
+			len(missed), c.userID, *lastSeq, last)
+	}
+	return nil
+}
+
+func (s *Server) registerClient(c *client) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Shutdown notifies the clients registered when it starts; later ones
+	// are turned away.
+	select {
+	case <-s.quit:
+		return ErrServerClosed
+	default:
+	}
+
 	// Count total connections
 	total := 0
 	for _, conns := range s.connections {
@@ -195,66 +634,263 @@ func (s *Server) registerClient(userID string, conn net.Conn) error {
 		return ErrServerAtCapacity
 	}
 
-	if s.connections[userID] == nil {
-		s.connections[userID] = make(map[net.Conn]struct{})
+	if s.connections[c.userID] == nil {
+		s.connections[c.userID] = make(map[*client]struct{})
 	}
-	s.connections[userID][conn] = struct{}{}
+	s.connections[c.userID][c] = struct{}{}
 	return nil
 }
 
-func (s *Server) unregisterClient(userID string, conn net.Conn) {
+func (s *Server) unregisterClient(c *client) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if conns, ok := s.connections[userID]; ok {
-		if _, exists := conns[conn]; exists {
-			delete(conns, conn)
-			_ = conn.Close()
-		}
+	if conns, ok := s.connections[c.userID]; ok {
+		delete(conns, c)
 		if len(conns) == 0 {
-			delete(s.connections, userID)
+			delete(s.connections, c.userID)
 		}
 	}
+	c.close()
 }
 
-// broadcastLoop sends progress updates to all relevant clients (same user).
+// handleEvent relays the progress, library and subscription changes
+// published on the bus by other services.
+func (s *Server) handleEvent(e events.Event) {
+	if e.Source == "tcp" {
+		return
+	}
+	if msg := messageFor(e); msg != nil {
+		s.submit(msg)
+	}
+}
+
+// messageFor converts a bus event to the message sent to clients, or nil.
+func messageFor(e events.Event) Message {
+	ts := e.Time.Unix()
+	switch p := e.Payload.(type) {
+	case events.Progress:
+		return &ProgressUpdate{
+			Header:    Header{Type: TypeProgress, UserID: p.UserID},
+			MangaID:   p.MangaID,
+			Chapter:   p.Chapter,
+			Status:    p.Status,
+			Timestamp: p.Timestamp,
+
+			Version:         p.Version,
+			ClientTimestamp: p.ClientTimestamp,
+		}
+	case events.LibraryChange:
+		switch p.Action {
+		case events.LibraryAdded:
+			return &LibraryAdd{
+				Header:    Header{Type: TypeLibraryAdd, UserID: p.UserID},
+				MangaID:   p.MangaID,
+				Chapter:   p.Chapter,
+				Status:    p.Status,
+				Timestamp: ts,
+			}
+		case events.LibraryRemoved:
+			return &LibraryRemove{
+				Header:    Header{Type: TypeLibraryRemove, UserID: p.UserID},
+				MangaID:   p.MangaID,
+				Timestamp: ts,
+			}
+		case events.LibraryStatusChanged:
+			return &StatusChange{
+				Header:    Header{Type: TypeStatusChange, UserID: p.UserID},
+				MangaID:   p.MangaID,
+				Status:    p.Status,
+				Timestamp: ts,
+			}
+		}
+	case events.SubscriptionChange:
+		return &SubscriptionChange{
+			Header:     Header{Type: TypeSubscriptionChange, UserID: p.UserID},
+			MangaID:    p.MangaID,
+			Subscribed: p.Subscribed,
+			Timestamp:  ts,
+		}
+	}
+	return nil
+}
+
+// clientsOf returns a snapshot of userID's connections.
+func (s *Server) clientsOf(userID string) []*client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := make([]*client, 0, len(s.connections[userID]))
+	for c := range s.connections[userID] {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// broadcastLoop sends messages to all relevant clients (same user). Once
+// the server shuts down it sends what is already queued and exits.
 func (s *Server) broadcastLoop() {
-	for upd := range s.Broadcast {
-		data, err := json.Marshal(upd)
-		if err != nil {
-			log.Printf("TCP: failed to marshal broadcast update: %v\n", err)
+	defer close(s.loopDone)
+	for {
+		select {
+		case msg := <-s.Broadcast:
+			s.process(msg)
+		case <-s.quit:
+			for {
+				select {
+				case msg := <-s.Broadcast:
+					s.process(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) process(msg Message) {
+	clientCount, err := s.broadcast(msg)
+	if err != nil {
+		log.Printf("TCP: failed to record %s for replay: %v\n", msg.header().Type, err)
+	}
+	logBroadcast(msg, clientCount)
+}
+
+func logBroadcast(msg Message, clientCount int) {
+	h := msg.header()
+	if clientCount > 0 {
+		log.Printf("TCP: broadcasted %s (seq %d) to user %s on %d connection(s)\n",
+			h.Type, h.Seq, h.UserID, clientCount)
+	} else {
+		log.Printf("TCP: broadcasted %s (seq %d) for user %s (no active connections)\n",
+			h.Type, h.Seq, h.UserID)
+	}
+}
+
+// broadcast numbers msg, records it for replay, hands it to the other
+// nodes and queues it for the user's connections that receive its type,
+// returning how many there are. A message that could not be recorded is
+// still sent live when it could be encoded; the error is returned either way.
+func (s *Server) broadcast(msg Message) (int, error) {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+
+	userID := msg.header().UserID
+	e, err := s.Replay.Append(msg)
+	if err != nil && e.Data == nil {
+		return 0, err
+	}
+	if s.Broker != nil {
+		data, _ := json.Marshal(remoteEntry{UserID: userID, Seq: e.Seq, Type: e.Type, Data: e.Data})
+		if err := s.Broker.Publish(BrokerTopic, data); err != nil {
+			log.Printf("TCP: failed to publish %s (seq %d) to other nodes: %v\n", e.Type, e.Seq, err)
+		}
+	}
+	return s.deliver(userID, e), err
+}
+
+// DeliverProgress broadcasts a progress update stored by another service
+// right away, instead of through the bus, and returns an error unless it was
+// recorded for replay. The user service's outbox keeps the update and retries
+// until it succeeds.
+func (s *Server) DeliverProgress(p events.Progress) error {
+	s.lifeMu.Lock()
+	started := s.started
+	s.lifeMu.Unlock()
+	select {
+	case <-s.quit:
+		return ErrServerClosed
+	default:
+	}
+	if !started {
+		return errors.New("tcp server not started")
+	}
+
+	e := events.Event{Type: events.ProgressUpdated, UserID: p.UserID, Source: "tcp", Payload: p, Time: time.Now()}
+	msg := messageFor(e)
+	clientCount, err := s.broadcast(msg)
+	if err != nil {
+		return err
+	}
+	logBroadcast(msg, clientCount)
+	if s.Bus != nil {
+		// Other consumers (e.g. SSE) still learn about it from the bus.
+		s.Bus.Publish(e)
+	}
+	return nil
+}
+
+// handleRemote delivers a broadcast from another node to this node's
+// connections of the user.
+func (s *Server) handleRemote(m broker.Message) {
+	var r remoteEntry
+	if err := json.Unmarshal(m.Data, &r); err != nil || r.UserID == "" {
+		log.Printf("TCP: invalid broadcast from node %s\n", m.Node)
+		return
+	}
+	e := LogEntry{Seq: r.Seq, Type: r.Type, Data: r.Data}
+
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+	if e.Seq != 0 {
+		if err := s.Replay.Record(r.UserID, e); err != nil {
+			log.Printf("TCP: failed to record %s from node %s: %v\n", e.Type, m.Node, err)
+		}
+	}
+	if n := s.deliver(r.UserID, e); n > 0 {
+		log.Printf("TCP: relayed %s (seq %d) from node %s to user %s on %d connection(s)\n",
+			e.Type, e.Seq, m.Node, r.UserID, n)
+	}
+}
+
+// deliver queues e for userID's connections that receive its type and
+// returns how many there are; s.seqMu must be held.
+func (s *Server) deliver(userID string, e LogEntry) int {
+	var err error
+	// Each framing is encoded once and shared by the connections using it.
+	frames := make(map[string][]byte, 2)
+	sent := 0
+	for _, c := range s.clientsOf(userID) {
+		if !c.types[e.Type] {
 			continue
 		}
-		data = append(data, '\n')
-
-		s.mu.RLock()
-		targetUser := upd.UserID
-		conns := s.connections[targetUser]
-		clientCount := len(conns)
-		for conn := range conns {
-			if _, err := conn.Write(data); err != nil {
-				log.Printf("TCP: failed to send to %s: %v\n", targetUser, err)
+		frame, ok := frames[c.framing]
+		if !ok {
+			if frame, err = e.frame(c.framing); err != nil {
+				log.Printf("TCP: failed to encode %s for %s framing: %v\n", e.Type, c.framing, err)
 			}
+			frames[c.framing] = frame
 		}
-		s.mu.RUnlock()
-
-		if clientCount > 0 {
-			log.Printf("TCP: broadcasted progress update to user %s: manga=%s, chapter=%d to %d connection(s)\n",
-				targetUser, upd.MangaID, upd.Chapter, clientCount)
+		if frame == nil {
+			continue
+		}
+		if c.holding {
+			c.held = append(c.held, frame)
+			if len(c.held) > cap(c.send) {
+				log.Printf("TCP: evicting %s at %s, too many messages during a snapshot\n",
+					c.userID, c.conn.RemoteAddr())
+				c.close()
+			}
 		} else {
-			log.Printf("TCP: broadcasted progress update for user %s: manga=%s, chapter=%d (no active connections)\n",
-				targetUser, upd.MangaID, upd.Chapter)
+			s.enqueueFrame(c, frame)
 		}
+		sent++
 	}
+	return sent
 }
 
-func sendAuthResponse(conn net.Conn, status, errMsg string) error {
-	resp := AuthResponse{
+func sendAuthResponse(conn net.Conn, status, errMsg, userID string) error {
+	return writeLine(conn, AuthResponse{
 		Type:   "auth_response",
 		Status: status,
+		UserID: userID,
 		Error:  errMsg,
-	}
-	data, err := json.Marshal(resp)
+	})
+}
+
+// writeLine marshals v and writes it as one newline-terminated JSON line.
+func writeLine(conn net.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
@@ -262,5 +898,3 @@ func sendAuthResponse(conn net.Conn, status, errMsg string) error {
 	_, err = conn.Write(data)
 	return err
 }
-
-
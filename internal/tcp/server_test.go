@@ -0,0 +1,260 @@
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mangahub/internal/auth"
+	"mangahub/pkg/models"
+)
+
+var testSecret = []byte("test-secret")
+
+// startServer starts s on a free loopback port and shuts it down when the
+// test ends.
+func startServer(t *testing.T, s *Server) string {
+	t.Helper()
+	s.Port = "127.0.0.1:0"
+	s.JWTSecret = testSecret
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start(context.Background()) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-errc:
+			t.Fatalf("Start: %v", err)
+		default:
+		}
+		if addr := s.Addr(); addr != nil {
+			return addr.String()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("server did not start listening")
+	return ""
+}
+
+// testConn is an authenticated client connection.
+type testConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// dial connects to addr and authenticates as userID. readBuffer, when
+// positive, shrinks the socket's receive buffer so a client that stops
+// reading backs up quickly.
+func dial(t *testing.T, addr, userID string, readBuffer int) *testConn {
+	t.Helper()
+	token, err := auth.GenerateJWT(testSecret, &models.User{ID: userID, Username: userID})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if readBuffer > 0 {
+		if err := conn.(*net.TCPConn).SetReadBuffer(readBuffer); err != nil {
+			t.Fatalf("SetReadBuffer: %v", err)
+		}
+	}
+
+	c := &testConn{Conn: conn, r: bufio.NewReader(conn)}
+	if err := writeLine(conn, AuthMessage{Type: "auth", Token: token, Version: ProtocolVersion}); err != nil {
+		t.Fatalf("send auth: %v", err)
+	}
+	var resp AuthResponse
+	c.read(t, &resp)
+	if resp.Status != "ok" {
+		t.Fatalf("auth rejected: %+v", resp)
+	}
+	return c
+}
+
+// read decodes the next JSON line into v.
+func (c *testConn) read(t *testing.T, v interface{}) {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := json.Unmarshal(line, v); err != nil {
+		t.Fatalf("decode %q: %v", line, err)
+	}
+}
+
+// waitFor polls cond until it holds or the timeout expires.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// progress returns a progress broadcast for userID whose manga ID is padded
+// to about size bytes.
+func progress(userID string, chapter, size int) *ProgressUpdate {
+	return &ProgressUpdate{
+		Header:  Header{Type: TypeProgress, UserID: userID},
+		MangaID: strings.Repeat("m", size),
+		Chapter: chapter,
+		Status:  "reading",
+	}
+}
+
+func TestStuckClientIsEvicted(t *testing.T) {
+	s := NewServer("", 10)
+	s.SendQueueSize = 4
+	s.WriteTimeout = 200 * time.Millisecond
+	addr := startServer(t, s)
+
+	const userID = "user_stuck"
+	stuck := dial(t, addr, userID, 4096) // never reads again
+	healthy := dial(t, addr, userID, 0)
+	waitFor(t, 5*time.Second, "both connections to register", func() bool {
+		return len(s.clientsOf(userID)) == 2
+	})
+
+	received := make(chan ProgressUpdate)
+	go func() {
+		for {
+			var upd ProgressUpdate
+			_ = healthy.SetReadDeadline(time.Now().Add(10 * time.Second))
+			line, err := healthy.r.ReadBytes('\n')
+			if err != nil {
+				close(received)
+				return
+			}
+			if json.Unmarshal(line, &upd) == nil && upd.Type == TypeProgress {
+				received <- upd
+			}
+		}
+	}()
+
+	// 500 broadcasts of 32KB are far more than the stuck client's socket
+	// buffers hold. Each one must reach the healthy client promptly: if
+	// broadcastLoop blocked on the stuck client, it would not.
+	const count, size = 500, 32 << 10
+	for i := 1; i <= count; i++ {
+		select {
+		case s.Broadcast <- progress(userID, i, size):
+		case <-time.After(2 * time.Second):
+			t.Fatalf("broadcast %d: Broadcast channel blocked", i)
+		}
+		select {
+		case upd, ok := <-received:
+			if !ok {
+				t.Fatalf("broadcast %d: healthy client disconnected", i)
+			}
+			if upd.Chapter != i || upd.Seq != uint64(i) {
+				t.Fatalf("broadcast %d: got chapter %d seq %d", i, upd.Chapter, upd.Seq)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("broadcast %d: not delivered to the healthy client", i)
+		}
+	}
+
+	waitFor(t, 5*time.Second, "the stuck client to be evicted", func() bool {
+		for _, c := range s.clientsOf(userID) {
+			if c.conn.LocalAddr().String() == stuck.RemoteAddr().String() &&
+				c.conn.RemoteAddr().String() == stuck.LocalAddr().String() {
+				return false
+			}
+		}
+		return true
+	})
+	if n := len(s.clientsOf(userID)); n != 1 {
+		t.Fatalf("%d connection(s) registered, want only the healthy one", n)
+	}
+}
+
+func TestEnqueueReportsEncodingFailure(t *testing.T) {
+	s := NewServer("", 10)
+	server, peer := net.Pipe()
+	defer server.Close()
+	defer peer.Close()
+	c := newClient(server, "user_a", auth.RoleUser, ProtocolVersion, FramingJSON, 4)
+
+	if s.enqueue(c, map[string]interface{}{"bad": func() {}}) {
+		t.Fatal("enqueue reported a message that cannot be marshalled as queued")
+	}
+	if len(c.send) != 0 {
+		t.Fatalf("%d message(s) queued, want none", len(c.send))
+	}
+	if !s.enqueue(c, HeartbeatMessage{Type: "ping"}) {
+		t.Fatal("enqueue rejected a valid message")
+	}
+}
+
+func TestSnapshotDoesNotBlockBroadcasts(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := NewServer("", 10)
+	s.Snapshots = func(userID string) ([]LibraryEntry, []string, error) {
+		close(started)
+		<-release
+		return nil, nil, nil
+	}
+	addr := startServer(t, s)
+	a := dial(t, addr, "user_a", 0)
+	b := dial(t, addr, "user_b", 0)
+
+	if err := writeLine(a, SnapshotRequest{Type: TypeSnapshotRequest, RequestID: "r1"}); err != nil {
+		t.Fatalf("send snapshot request: %v", err)
+	}
+	<-started
+
+	// Both broadcasts go through while the snapshot is being built; user_b
+	// gets its update right away.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, userID := range []string{"user_a", "user_b"} {
+			if _, err := s.broadcast(progress(userID, 1, 1)); err != nil {
+				t.Errorf("broadcast to %s: %v", userID, err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcasts blocked by the snapshot query")
+	}
+	var hdr Header
+	b.read(t, &hdr)
+	if hdr.Type != TypeProgress || hdr.Seq != 1 {
+		t.Fatalf("user_b got %+v, want progress seq 1", hdr)
+	}
+
+	// user_a's update is held until after the snapshot it is not part of.
+	close(release)
+	var snap Snapshot
+	a.read(t, &snap)
+	if snap.Type != TypeSnapshot || snap.LastSeq != 0 {
+		t.Fatalf("user_a got %+v, want a snapshot at seq 0", snap)
+	}
+	a.read(t, &hdr)
+	if hdr.Type != TypeProgress || hdr.Seq != 1 {
+		t.Fatalf("user_a got %+v after the snapshot, want progress seq 1", hdr)
+	}
+}
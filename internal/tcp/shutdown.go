@@ -0,0 +1,137 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds the graceful shutdown started by cancelling
+// Start's context.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ErrServerClosed rejects connections that authenticate while the server is
+// shutting down.
+var ErrServerClosed = errors.New("server_shutdown")
+
+// ShutdownMessage tells every connection, whatever its protocol version,
+// that the server is going away. Clients should reconnect (to another node,
+// if there is one) with last_seq.
+type ShutdownMessage struct {
+	Type      string `json:"type"` // "server_shutdown"
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Shutdown stops the server gracefully: it closes the listener, stops taking
+// events, sends the broadcasts already queued, tells every client with a
+// server_shutdown message and waits for the connections to flush and close.
+// When ctx expires first, the remaining connections are closed and ctx's
+// error is returned. It is safe to call more than once.
+func (s *Server) Shutdown(ctx context.Context) error {
+	first := false
+	s.quitOnce.Do(func() {
+		first = true
+		close(s.quit)
+	})
+	if first {
+		s.drain(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		if first {
+			log.Println("TCP: server stopped")
+		}
+		return nil
+	case <-ctx.Done():
+		for _, c := range s.allClients() {
+			c.close()
+		}
+		return ctx.Err()
+	}
+}
+
+// drain stops new work, flushes queued broadcasts and tells every client the
+// server is going away.
+func (s *Server) drain(ctx context.Context) {
+	s.lifeMu.Lock()
+	ln, started := s.ln, s.started
+	unsubscribe := s.unsubscribe
+	s.unsubscribe = nil
+	for conn := range s.handshakes {
+		_ = conn.Close()
+	}
+	s.lifeMu.Unlock()
+
+	if ln != nil {
+		_ = ln.Close()
+	}
+	for _, unsub := range unsubscribe {
+		unsub()
+	}
+	if started {
+		select {
+		case <-s.loopDone:
+		case <-ctx.Done():
+		}
+	}
+
+	clients := s.allClients()
+	log.Printf("TCP: shutting down, notifying %d connection(s)\n", len(clients))
+	for _, c := range clients {
+		s.enqueue(c, ShutdownMessage{Type: TypeServerShutdown, Timestamp: time.Now().Unix()})
+		c.drain()
+	}
+}
+
+// submit queues msg for broadcastLoop. Once the server is shutting down the
+// message may be dropped instead.
+func (s *Server) submit(msg Message) {
+	select {
+	case s.Broadcast <- msg:
+	case <-s.quit:
+		h := msg.header()
+		log.Printf("TCP: shutting down, dropped %s for user %s\n", h.Type, h.UserID)
+	}
+}
+
+// trackHandshake records a connection that is still authenticating so
+// Shutdown can close it; it reports false once the server is shutting down.
+func (s *Server) trackHandshake(conn net.Conn) bool {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	select {
+	case <-s.quit:
+		return false
+	default:
+	}
+	s.handshakes[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackHandshake(conn net.Conn) {
+	s.lifeMu.Lock()
+	delete(s.handshakes, conn)
+	s.lifeMu.Unlock()
+}
+
+// allClients returns a snapshot of every registered connection.
+func (s *Server) allClients() []*client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []*client
+	for _, conns := range s.connections {
+		for c := range conns {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
@@ -0,0 +1,91 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"mangahub/internal/broker"
+	"mangahub/internal/events"
+)
+
+// checkGoroutines fails the test unless the goroutine count falls back to
+// before within a few seconds, and dumps every goroutine if it does not.
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("%d goroutine(s) left after Shutdown, %d before Start:\n%s",
+				runtime.NumGoroutine(), before, buf)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownLeavesNoGoroutines(t *testing.T) {
+	bus := events.NewBus()
+	node := broker.NewMemoryHub().Node("node_a")
+	before := runtime.NumGoroutine()
+
+	s := NewServer("", 10)
+	s.Bus = bus
+	s.Broker = node
+	addr := startServer(t, s)
+
+	const userID = "user_a"
+	clients := []*testConn{dial(t, addr, userID, 0), dial(t, addr, userID, 0), dial(t, addr, "user_b", 0)}
+	waitFor(t, 5*time.Second, "the connections to register", func() bool {
+		return len(s.clientsOf(userID)) == 2 && len(s.clientsOf("user_b")) == 1
+	})
+
+	// Broadcasts still queued when Shutdown starts must be sent before the
+	// server_shutdown message.
+	const queued = 20
+	for i := 1; i <= queued; i++ {
+		s.Broadcast <- progress(userID, i, 16)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	for i, c := range clients {
+		want := 0
+		if i < 2 {
+			want = queued
+		}
+		got := 0
+		for {
+			var hdr Header
+			c.read(t, &hdr)
+			if hdr.Type == TypeProgress {
+				got++
+				continue
+			}
+			if hdr.Type != TypeServerShutdown {
+				t.Fatalf("client %d: unexpected %s", i, hdr.Type)
+			}
+			break
+		}
+		if got != want {
+			t.Fatalf("client %d: %d progress update(s) before server_shutdown, want %d", i, got, want)
+		}
+		if _, err := c.r.ReadByte(); !errors.Is(err, io.EOF) {
+			t.Fatalf("client %d: connection still open after server_shutdown (%v)", i, err)
+		}
+		c.Close()
+	}
+
+	checkGoroutines(t, before)
+
+	if err := s.DeliverProgress(events.Progress{UserID: userID, MangaID: "m1", Chapter: 1}); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("DeliverProgress after Shutdown: got %v, want %v", err, ErrServerClosed)
+	}
+}
@@ -0,0 +1,219 @@
+// Package tlsutil builds the TLS configuration shared by the TCP sync and
+// gRPC servers and their clients. Server certificates are reloaded from disk
+// on SIGHUP so they can be rotated without a restart.
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ServerConfig describes a server's certificate and, for mutual TLS, the CA
+// that client certificates must chain to.
+type ServerConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // optional; enables mTLS when set
+}
+
+// ServerFromEnv reads the server TLS settings for a component (e.g. "TCP"):
+// - MANGAHUB_<NAME>_TLS_CERT
+// - MANGAHUB_<NAME>_TLS_KEY
+// - MANGAHUB_<NAME>_TLS_CLIENT_CA (optional, requires client certificates)
+//
+// It returns nil when no certificate is configured, meaning plain TCP.
+func ServerFromEnv(name string) *ServerConfig {
+	prefix := "MANGAHUB_" + name + "_TLS_"
+	cfg := &ServerConfig{
+		CertFile:     os.Getenv(prefix + "CERT"),
+		KeyFile:      os.Getenv(prefix + "KEY"),
+		ClientCAFile: os.Getenv(prefix + "CLIENT_CA"),
+	}
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil
+	}
+	return cfg
+}
+
+// Reloader serves the most recently loaded certificate and client CA pool.
+type Reloader struct {
+	cfg ServerConfig
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+// NewReloader loads the files in cfg.
+func NewReloader(cfg ServerConfig) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("tls: both certificate and key files are required")
+	}
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the certificate, key and client CA. On error the previous
+// files stay in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		if pool, err = loadPool(r.cfg.ClientCAFile); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.mu.Unlock()
+	return nil
+}
+
+// TLSConfig returns a server config that picks up reloaded files on every
+// handshake. Only the certificate and client verification are dynamic, so
+// settings added by the caller (e.g. gRPC's NextProtos) stay in effect.
+func (r *Reloader) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	if r.cfg.ClientCAFile != "" {
+		// The CA pool can change on reload, so client certificates are
+		// checked against the current one in VerifyConnection instead of a
+		// fixed ClientCAs.
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyConnection = r.verifyClient
+	}
+	return cfg
+}
+
+// verifyClient verifies the client's certificate chain against the current
+// client CA pool.
+func (r *Reloader) verifyClient(cs tls.ConnectionState) error {
+	r.mu.RLock()
+	pool := r.clientCA
+	r.mu.RUnlock()
+
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: client certificate required")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("tls: verify client certificate: %w", err)
+	}
+	return nil
+}
+
+// WatchSIGHUP reloads the files whenever the process receives SIGHUP, until
+// ctx is cancelled.
+func (r *Reloader) WatchSIGHUP(ctx context.Context, name string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.Reload(); err != nil {
+				log.Printf("TLS: %s certificate reload failed, keeping previous: %v", name, err)
+				continue
+			}
+			log.Printf("TLS: %s certificate reloaded", name)
+		}
+	}
+}
+
+// ClientConfig describes how a client verifies the server and, for mutual
+// TLS, which certificate it presents.
+type ClientConfig struct {
+	CAFile     string // optional; system roots are used when empty
+	CertFile   string // optional client certificate
+	KeyFile    string
+	ServerName string // optional; defaults to the dialled host
+}
+
+// ClientFromEnv reads the client TLS settings for a component (e.g. "TCP"):
+// - MANGAHUB_<NAME>_TLS_CA
+// - MANGAHUB_<NAME>_TLS_CLIENT_CERT
+// - MANGAHUB_<NAME>_TLS_CLIENT_KEY
+// - MANGAHUB_<NAME>_TLS_SERVER_NAME
+//
+// It returns nil when neither a CA nor a client certificate is configured,
+// meaning plain TCP.
+func ClientFromEnv(name string) *ClientConfig {
+	prefix := "MANGAHUB_" + name + "_TLS_"
+	cfg := &ClientConfig{
+		CAFile:     os.Getenv(prefix + "CA"),
+		CertFile:   os.Getenv(prefix + "CLIENT_CERT"),
+		KeyFile:    os.Getenv(prefix + "CLIENT_KEY"),
+		ServerName: os.Getenv(prefix + "SERVER_NAME"),
+	}
+	if cfg.CAFile == "" && cfg.CertFile == "" {
+		return nil
+	}
+	return cfg
+}
+
+// TLSConfig loads the files in c into a client config.
+func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CAFile != "" {
+		pool, err := loadPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load client key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tls: read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,27 @@
+package udp
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes registers diagnostics endpoints for the UDP server.
+// The router group is expected to be restricted to admins.
+func RegisterAdminRoutes(r *gin.RouterGroup, s *Server) {
+	r.GET("/udp/subscribers", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"clients":     s.ClientCount(),
+			"subscribers": s.SubscriberCounts(),
+		})
+	})
+	r.GET("/udp/stats", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.DeliveryStats())
+	})
+	r.GET("/udp/registrations", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"client_ttl":    s.ClientTTL.String(),
+			"registrations": s.Registrations(),
+		})
+	})
+}
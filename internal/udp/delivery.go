@@ -0,0 +1,188 @@
+package udp
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Retransmission defaults: 1s, 2s, 4s, 8s between attempts, then give up.
+const (
+	DefaultRetryBase   = time.Second
+	DefaultMaxAttempts = 5
+)
+
+// AckMessage is sent by clients to confirm receipt of a notification.
+type AckMessage struct {
+	Type        string `json:"type"` // "ack"
+	Seq         uint64 `json:"seq"`
+	UserID      string `json:"user_id,omitempty"`
+	ClientLabel string `json:"client_label,omitempty"`
+	Sig         string `json:"sig,omitempty"` // see SignAck; required when the server has a SigningKey
+}
+
+// DeliveryStats summarises notification delivery since the server started.
+type DeliveryStats struct {
+	Sent        uint64 `json:"sent"`        // first transmissions
+	Acked       uint64 `json:"acked"`       // confirmed by the client
+	Retransmits uint64 `json:"retransmits"` // additional transmissions
+	Failed      uint64 `json:"failed"`      // gave up after MaxAttempts
+	Pending     int    `json:"pending"`     // awaiting ack right now
+}
+
+// pendingKey identifies one notification sent to one device.
+type pendingKey struct {
+	Seq    uint64
+	Client clientKey
+}
+
+type pendingDelivery struct {
+	addr        net.UDPAddr
+	data        []byte
+	attempts    int
+	nextAttempt time.Time
+}
+
+// nextSeq returns a new notification sequence ID.
+func (s *Server) nextSeq() uint64 {
+	return atomic.AddUint64(&s.seq, 1)
+}
+
+// send transmits a notification to a client and tracks it until acked.
+func (s *Server) send(conn *net.UDPConn, c clientInfo, seq uint64, data []byte) error {
+	s.deliveryMu.Lock()
+	s.pending[pendingKey{Seq: seq, Client: c.key()}] = &pendingDelivery{
+		addr:        c.Addr,
+		data:        data,
+		attempts:    1,
+		nextAttempt: time.Now().Add(s.RetryBase),
+	}
+	s.stats.Sent++
+	s.deliveryMu.Unlock()
+
+	_, err := conn.WriteToUDP(data, &c.Addr)
+	return err
+}
+
+// DeliverToUser pushes n to every registered device of userID, tracking each
+// for acks. Notifications without a sequence ID get one (and a signature).
+func (s *Server) DeliverToUser(userID string, n Notification) error {
+	s.mu.RLock()
+	conn := s.conn
+	s.mu.RUnlock()
+	if conn == nil {
+		return errors.New("udp server not started")
+	}
+
+	if n.Seq == 0 {
+		n.Seq = s.nextSeq()
+		n.Sig = ""
+		if len(s.SigningKey) > 0 {
+			n.Sig = SignNotification(s.SigningKey, n)
+		}
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range s.snapshot() {
+		if c.UserID != userID {
+			continue
+		}
+		if err := s.send(conn, c, n.Seq, data); err != nil {
+			log.Printf("udp send error to %v (user=%s): %v\n", c.Addr, c.UserID, err)
+		}
+	}
+	return nil
+}
+
+// handleAck stops retransmission of an acknowledged notification. With a
+// SigningKey, acks without a valid MAC are ignored.
+func (s *Server) handleAck(addr *net.UDPAddr, data []byte) {
+	var msg AckMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("udp ack unmarshal error:", err)
+		return
+	}
+	if len(s.SigningKey) > 0 && !VerifyAck(s.SigningKey, msg) {
+		log.Printf("udp ack from %v (user=%s) has an invalid MAC, ignoring\n", addr, msg.UserID)
+		return
+	}
+
+	key := pendingKey{
+		Seq:    msg.Seq,
+		Client: clientKey{UserID: msg.UserID, Addr: addr.String(), Label: msg.ClientLabel},
+	}
+
+	s.deliveryMu.Lock()
+	if _, ok := s.pending[key]; ok {
+		delete(s.pending, key)
+		s.stats.Acked++
+	}
+	s.deliveryMu.Unlock()
+
+	// An ack also proves the device is alive.
+	s.touchClient(key.Client)
+}
+
+// retransmitLoop resends unacknowledged notifications with exponential
+// backoff until they are acked or MaxAttempts is reached.
+func (s *Server) retransmitLoop(conn *net.UDPConn) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+
+		type resend struct {
+			addr net.UDPAddr
+			data []byte
+		}
+		var due []resend
+
+		s.deliveryMu.Lock()
+		for key, p := range s.pending {
+			if now.Before(p.nextAttempt) {
+				continue
+			}
+			if p.attempts >= s.MaxAttempts {
+				delete(s.pending, key)
+				s.stats.Failed++
+				log.Printf("UDP: giving up on notification seq=%d to user=%s at %s after %d attempt(s)\n",
+					key.Seq, key.Client.UserID, key.Client.Addr, p.attempts)
+				continue
+			}
+			p.attempts++
+			p.nextAttempt = now.Add(s.RetryBase << uint(p.attempts-1))
+			s.stats.Retransmits++
+			due = append(due, resend{addr: p.addr, data: p.data})
+		}
+		s.deliveryMu.Unlock()
+
+		for _, r := range due {
+			addr := r.addr
+			if _, err := conn.WriteToUDP(r.data, &addr); err != nil {
+				log.Printf("udp retransmit error to %v: %v\n", r.addr, err)
+			}
+		}
+	}
+}
+
+// DeliveryStats returns a snapshot of the delivery counters.
+func (s *Server) DeliveryStats() DeliveryStats {
+	s.deliveryMu.Lock()
+	defer s.deliveryMu.Unlock()
+
+	stats := s.stats
+	stats.Pending = len(s.pending)
+	return stats
+}
@@ -0,0 +1,56 @@
+package udp
+
+import (
+	"log"
+
+	"mangahub/internal/events"
+)
+
+// handleEvent consumes bus events: newly detected chapters are broadcast and
+// subscription changes refresh the routing table.
+func (s *Server) handleEvent(e events.Event) {
+	switch e.Type {
+	case events.ChapterReleased:
+		// Per-user events are this server's own fan-out.
+		r, ok := e.Payload.(events.Release)
+		if !ok || e.UserID != "" || e.Source == "udp" {
+			return
+		}
+		n := BuildNotification(r.MangaID, r.Title, r.Message, r.Chapter)
+		n.Genres = r.Genres
+		if r.Timestamp != 0 {
+			n.Timestamp = r.Timestamp
+		}
+		if err := s.Publish(n); err != nil {
+			log.Println("UDP:", err)
+		}
+	case events.SubscriptionChanged:
+		if s.DB != nil && e.UserID != "" {
+			s.RefreshUser(e.UserID)
+		}
+	}
+}
+
+// publishRecipients announces a broadcast notification once per recipient so
+// other consumers (e.g. SSE) can reach the same users.
+func (s *Server) publishRecipients(n Notification, recipients []string) {
+	if s.Bus == nil {
+		return
+	}
+	r := events.Release{
+		MangaID:   n.MangaID,
+		Title:     n.Title,
+		Chapter:   n.Chapter,
+		Message:   n.Message,
+		Timestamp: n.Timestamp,
+		Genres:    n.Genres,
+	}
+	for _, userID := range recipients {
+		s.Bus.Publish(events.Event{
+			Type:    events.ChapterReleased,
+			UserID:  userID,
+			Source:  "udp",
+			Payload: r,
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package udp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// recipients returns the users a notification is for: everyone subscribed to
+// the manga, whether or not a device is online, plus users whose registered
+// devices match it by preference.
+func (s *Server) recipients(n Notification, clients []clientInfo) []string {
+	users := make(map[string]bool)
+
+	s.mu.RLock()
+	for userID, mangaIDs := range s.subs {
+		for _, id := range mangaIDs {
+			if id == n.MangaID {
+				users[userID] = true
+				break
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, c := range clients {
+		if c.wants(n) {
+			users[c.UserID] = true
+		}
+	}
+
+	ids := make([]string, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// storeInbox records n in the notifications inbox of every recipient.
+func (s *Server) storeInbox(n Notification, userIDs []string) error {
+	if s.DB == nil || len(userIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("store inbox: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO notifications (user_id, type, manga_id, title, chapter, message)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return fmt.Errorf("store inbox: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, userID := range userIDs {
+		if _, err := stmt.Exec(userID, n.Type, n.MangaID, n.Title, n.Chapter, n.Message); err != nil {
+			return fmt.Errorf("store inbox: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,135 @@
+package udp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"mangahub/internal/auth"
+)
+
+// PublishMessage is a chapter_release sent to the server for broadcast. The
+// token must belong to an admin or service account; it is never forwarded.
+type PublishMessage struct {
+	Notification
+	Token string `json:"token"`
+}
+
+// authenticate validates a client token and returns the user the message
+// acts for. Regular users can only act for themselves; admin and service
+// tokens may name any user in userID.
+func (s *Server) authenticate(token, userID string) (*auth.Claims, string, error) {
+	if len(s.JWTSecret) == 0 {
+		return nil, "", errors.New("auth_not_configured")
+	}
+	if token == "" {
+		return nil, "", errors.New("missing_token")
+	}
+	claims, err := auth.ParseToken(s.JWTSecret, token)
+	if err != nil {
+		return nil, "", errors.New("invalid_token")
+	}
+
+	switch claims.Role {
+	case auth.RoleAdmin, auth.RoleService:
+		if userID != "" {
+			return claims, userID, nil
+		}
+		if claims.Role == auth.RoleService {
+			return nil, "", errors.New("missing_user_id")
+		}
+	default:
+		if userID != "" && userID != claims.UserID {
+			return nil, "", errors.New("user_mismatch")
+		}
+	}
+	return claims, claims.UserID, nil
+}
+
+// canPublish reports whether claims may broadcast notifications.
+func canPublish(claims *auth.Claims) bool {
+	return claims.Role == auth.RoleAdmin || claims.Role == auth.RoleService
+}
+
+// signaturePayload is the canonical form of a notification covered by its
+// signature. Every field a client acts on is included, each prefixed with
+// its length so that no two notifications encode to the same bytes.
+func signaturePayload(n Notification) []byte {
+	var p payload
+	p.putString(n.Type)
+	p.putString(n.MangaID)
+	p.putString(n.Title)
+	p.putUint(uint64(int64(n.Chapter)))
+	p.putString(n.Message)
+	p.putUint(uint64(n.Timestamp))
+	p.putUint(n.Seq)
+	p.putUint(uint64(len(n.Genres)))
+	for _, g := range n.Genres {
+		p.putString(g)
+	}
+	return p
+}
+
+// ackPayload is the canonical form of an ack covered by its MAC.
+func ackPayload(msg AckMessage) []byte {
+	var p payload
+	p.putString("ack")
+	p.putUint(msg.Seq)
+	p.putString(msg.UserID)
+	p.putString(msg.ClientLabel)
+	return p
+}
+
+// payload builds a MAC input from fixed-width integers and length-prefixed
+// strings.
+type payload []byte
+
+func (p *payload) putUint(v uint64) {
+	*p = binary.BigEndian.AppendUint64(*p, v)
+}
+
+func (p *payload) putString(s string) {
+	p.putUint(uint64(len(s)))
+	*p = append(*p, s...)
+}
+
+// sign returns the hex HMAC-SHA256 of data under key.
+func sign(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether sig is the hex HMAC-SHA256 of data under key.
+func verify(key, data []byte, sig string) bool {
+	want, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hmac.Equal(want, mac.Sum(nil))
+}
+
+// SignNotification returns the hex HMAC-SHA256 of n under key.
+func SignNotification(key []byte, n Notification) string {
+	return sign(key, signaturePayload(n))
+}
+
+// VerifyNotification reports whether n.Sig is a valid signature under key.
+func VerifyNotification(key []byte, n Notification) bool {
+	return verify(key, signaturePayload(n), n.Sig)
+}
+
+// SignAck returns the MAC a listener sends with its ack of seq, under the
+// same key the server signs notifications with.
+func SignAck(key []byte, msg AckMessage) string {
+	return sign(key, ackPayload(msg))
+}
+
+// VerifyAck reports whether msg.Sig is a valid MAC under key.
+func VerifyAck(key []byte, msg AckMessage) bool {
+	return verify(key, ackPayload(msg), msg.Sig)
+}
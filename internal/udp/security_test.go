@@ -0,0 +1,98 @@
+package udp
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSignatureCoversFieldBoundaries(t *testing.T) {
+	key := []byte("signing-key")
+	tests := []struct {
+		name string
+		a, b Notification
+	}{
+		{
+			"field boundary",
+			Notification{Type: "chapter_release", MangaID: "one\npiece", Title: "One Piece"},
+			Notification{Type: "chapter_release", MangaID: "one", Title: "piece\nOne Piece"},
+		},
+		{
+			"genre boundary",
+			Notification{Type: "chapter_release", Genres: []string{"Action,Comedy"}},
+			Notification{Type: "chapter_release", Genres: []string{"Action", "Comedy"}},
+		},
+		{
+			"empty genre",
+			Notification{Type: "chapter_release"},
+			Notification{Type: "chapter_release", Genres: []string{""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.b.Sig = SignNotification(key, tt.a)
+			if VerifyNotification(key, tt.b) {
+				t.Fatalf("signature of %+v verifies for %+v", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestAckRequiresMAC(t *testing.T) {
+	key := []byte("signing-key")
+	s := NewServer("")
+	s.SigningKey = key
+	s.RetryBase = time.Hour // only the first transmission
+	addr, _ := startServer(t, s)
+	client := register(t, addr, "user_a", "one-piece")
+
+	if err := s.Publish(Notification{MangaID: "one-piece", Title: "One Piece", Chapter: 1}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	var n Notification
+	read(t, client, &n)
+	if !VerifyNotification(key, n) {
+		t.Fatalf("notification %+v does not verify", n)
+	}
+
+	ack := AckMessage{Type: "ack", Seq: n.Seq, UserID: "user_a"}
+	forged := ack
+	forged.Sig = SignAck([]byte("other-key"), ack)
+	signed := ack
+	signed.Sig = SignAck(key, ack)
+
+	tests := []struct {
+		name  string
+		msg   AckMessage
+		acked uint64
+	}{
+		{"unsigned", ack, 0},
+		{"wrong key", forged, 0},
+		{"signed", signed, 1},
+	}
+	for _, tt := range tests {
+		data, _ := json.Marshal(tt.msg)
+		if _, err := client.Write(data); err != nil {
+			t.Fatalf("%s: send ack: %v", tt.name, err)
+		}
+		awaitHandled(t, client)
+		if st := s.DeliveryStats(); st.Acked != tt.acked || st.Pending != 1-int(tt.acked) {
+			t.Fatalf("%s: %d acked and %d pending, want %d acked", tt.name, st.Acked, st.Pending, tt.acked)
+		}
+	}
+}
+
+// awaitHandled waits until the server has handled every datagram conn sent
+// before: datagrams are handled in order, and a heartbeat is always answered.
+func awaitHandled(t *testing.T, conn *net.UDPConn) {
+	t.Helper()
+	if _, err := conn.Write([]byte(`{"type":"heartbeat"}`)); err != nil {
+		t.Fatalf("send heartbeat: %v", err)
+	}
+	var resp HeartbeatResponse
+	read(t, conn, &resp)
+	if resp.Type != "heartbeat_ack" {
+		t.Fatalf("got %+v, want a heartbeat_ack", resp)
+	}
+}
@@ -1,19 +1,31 @@
 package udp
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"mangahub/internal/events"
 )
 
 // RegisterMessage is sent by UDP clients to register for notifications (UC-009).
+// The user is taken from the token; user_id is only honoured for admin and
+// service tokens registering on behalf of a user. When the server is backed by
+// the database, manga_ids are ignored: the user's subscriptions decide what is
+// delivered and the registration only attaches this device's address.
 type RegisterMessage struct {
 	Type        string   `json:"type"`                  // "register"
+	Token       string   `json:"token"`                 // JWT as issued by /auth/login
 	UserID      string   `json:"user_id,omitempty"`     // user identifier
 	MangaIDs    []string `json:"manga_ids,omitempty"`   // optional list of manga IDs
 	Preferences []string `json:"preferences,omitempty"` // optional tags/genres
@@ -21,10 +33,32 @@ type RegisterMessage struct {
 }
 
 // UnregisterMessage is sent by UDP clients to unregister notifications.
+// Without manga IDs the sending device (user + address) is removed; with
+// manga IDs those manga are dropped from all of the user's devices (only
+// without a database, where subscriptions are managed over HTTP instead).
 type UnregisterMessage struct {
-	Type     string   `json:"type"`              // "unregister"
-	UserID   string   `json:"user_id,omitempty"` // user identifier
-	MangaIDs []string `json:"manga_ids,omitempty"`
+	Type        string   `json:"type"`              // "unregister"
+	Token       string   `json:"token"`             // JWT, see RegisterMessage
+	UserID      string   `json:"user_id,omitempty"` // user identifier
+	MangaIDs    []string `json:"manga_ids,omitempty"`
+	ClientLabel string   `json:"client_label,omitempty"`
+}
+
+// HeartbeatMessage keeps a registration alive. Clients must send one more
+// often than the server's client TTL or they are expired.
+type HeartbeatMessage struct {
+	Type        string `json:"type"` // "heartbeat"
+	Token       string `json:"token"`
+	UserID      string `json:"user_id,omitempty"`
+	ClientLabel string `json:"client_label,omitempty"`
+}
+
+// HeartbeatResponse acknowledges a heartbeat. Status "error" with message
+// "not_registered" tells the client to register again.
+type HeartbeatResponse struct {
+	Type    string `json:"type"`   // "heartbeat_ack"
+	Status  string `json:"status"` // "ok" or "error"
+	Message string `json:"message,omitempty"`
 }
 
 // RegisterResponse confirms registration.
@@ -32,6 +66,7 @@ type RegisterResponse struct {
 	Type    string `json:"type"`              // "register_response"
 	Status  string `json:"status"`            // "ok" or "error"
 	Message string `json:"message,omitempty"` // human-friendly message
+	UserID  string `json:"user_id,omitempty"` // authenticated user, echoed on success
 }
 
 // Notification represents a chapter release notification (UC-010).
@@ -42,44 +77,184 @@ type Notification struct {
 	Chapter   int    `json:"chapter"`
 	Message   string `json:"message"`
 	Timestamp int64  `json:"timestamp"`
+
+	// Genres of the manga, used to match clients registered by preference.
+	Genres []string `json:"genres,omitempty"`
+
+	// Seq identifies the notification for acks and duplicate suppression.
+	Seq uint64 `json:"seq,omitempty"`
+
+	// Sig is the hex HMAC-SHA256 of the notification (see SignNotification),
+	// set when the server has a signing key.
+	Sig string `json:"sig,omitempty"`
+}
+
+// clientKey identifies one registered device: a user may register several
+// addresses (phone, laptop, ...) and several labelled clients per address.
+type clientKey struct {
+	UserID string
+	Addr   string
+	Label  string
 }
 
 // clientInfo stores registration information for a UDP client.
 type clientInfo struct {
-	Addr        net.UDPAddr
-	UserID      string
-	MangaIDs    []string
-	Preferences []string
-	Label       string
+	Addr         net.UDPAddr
+	UserID       string
+	MangaIDs     []string
+	Preferences  []string
+	Label        string
+	RegisteredAt time.Time
+	LastSeen     time.Time
+}
+
+func (c *clientInfo) key() clientKey {
+	return clientKey{UserID: c.UserID, Addr: c.Addr.String(), Label: c.Label}
+}
+
+// Registration is the admin view of a registered client.
+type Registration struct {
+	UserID       string    `json:"user_id"`
+	Addr         string    `json:"addr"`
+	Label        string    `json:"label,omitempty"`
+	MangaIDs     []string  `json:"manga_ids"`
+	Preferences  []string  `json:"preferences"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 // Server is the UDP notification server implementation.
 type Server struct {
-	Port string
+	Port string // port, or host:port to listen on one interface
+
+	// ClientTTL is how long a registration survives without a heartbeat.
+	ClientTTL time.Duration
+
+	// Unacked notifications are resent after RetryBase, doubling each time,
+	// for at most MaxAttempts transmissions.
+	RetryBase   time.Duration
+	MaxAttempts int
+
+	// DB, when set, is the source of truth for subscriptions: routing uses
+	// user_notifications, reloaded every SubscriptionRefresh and on RefreshUser.
+	DB                  *sql.DB
+	SubscriptionRefresh time.Duration
+
+	// JWTSecret validates client tokens; SigningKey signs outgoing
+	// notifications (unsigned when empty).
+	JWTSecret  []byte
+	SigningKey []byte
+
+	// Dispatcher, if set, decides how each recipient is notified (UDP,
+	// email, webhook, ...) instead of pushing to every matching device.
+	Dispatcher Dispatcher
+
+	// Bus, if set, supplies detected chapter releases and subscription
+	// changes, and receives one chapter_released event per recipient of
+	// every broadcast (e.g. for SSE).
+	Bus *events.Bus
+
+	// GenreLookup optionally resolves a manga's genres when a notification
+	// arrives without them, so preference-based registrations still match.
+	GenreLookup func(mangaID string) []string
 
 	mu      sync.RWMutex
-	clients []clientInfo
+	clients map[clientKey]*clientInfo
+	subs    map[string][]string // user ID -> subscribed manga IDs (DB mode)
+	conn    *net.UDPConn        // set once Start is listening
+
+	seq        uint64 // last notification sequence ID
+	deliveryMu sync.Mutex
+	pending    map[pendingKey]*pendingDelivery
+	stats      DeliveryStats
+
+	// Lifecycle; see Start and Shutdown.
+	lifeMu      sync.Mutex
+	closing     bool
+	unsubscribe func()
+	quit        chan struct{}  // closed by Shutdown, stops the background loops
+	busy        sync.WaitGroup // broadcasts in progress
+	wg          sync.WaitGroup // read loop and background loops
 }
 
+// Dispatcher delivers a notification to one recipient over the channels they
+// chose. Implementations call DeliverToUser for UDP delivery.
+type Dispatcher interface {
+	Dispatch(userID string, n Notification)
+	// Close waits for the deliveries Dispatch started, or until ctx is done.
+	Close(ctx context.Context) error
+}
+
+// DefaultClientTTL is the registration lifetime without heartbeats.
+const DefaultClientTTL = 90 * time.Second
+
 // NewServer creates a new UDP server.
 func NewServer(port string) *Server {
 	if port == "" {
 		port = "9091"
 	}
 	return &Server{
-		Port: port,
+		Port:                port,
+		ClientTTL:           DefaultClientTTL,
+		RetryBase:           DefaultRetryBase,
+		MaxAttempts:         DefaultMaxAttempts,
+		SubscriptionRefresh: DefaultSubscriptionRefresh,
+		clients:             make(map[clientKey]*clientInfo),
+		subs:                make(map[string][]string),
+		pending:             make(map[pendingKey]*pendingDelivery),
+		quit:                make(chan struct{}),
+		// Sequence IDs start from the clock so they stay unique across
+		// restarts and clients don't discard fresh notifications as duplicates.
+		seq: uint64(time.Now().UnixNano()),
 	}
 }
 
-// FromEnv constructs a Server using environment variable MANGAHUB_UDP_PORT.
+// FromEnv constructs a Server using environment variables:
+// - MANGAHUB_UDP_PORT
+// - MANGAHUB_UDP_CLIENT_TTL (Go duration, default 90s)
+// - MANGAHUB_UDP_RETRY_BASE (Go duration, default 1s)
+// - MANGAHUB_UDP_MAX_ATTEMPTS (default 5)
+// - MANGAHUB_UDP_SIGNING_KEY (HMAC key for notification signatures)
+// - MANGAHUB_UDP_SUBSCRIPTION_REFRESH (Go duration, default 1m)
+//
+// JWTSecret and DB must be set by the caller.
 func FromEnv() *Server {
 	port := os.Getenv("MANGAHUB_UDP_PORT")
-	return NewServer(port)
+	s := NewServer(port)
+	s.SigningKey = []byte(os.Getenv("MANGAHUB_UDP_SIGNING_KEY"))
+	if v := os.Getenv("MANGAHUB_UDP_CLIENT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			s.ClientTTL = d
+		}
+	}
+	if v := os.Getenv("MANGAHUB_UDP_RETRY_BASE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			s.RetryBase = d
+		}
+	}
+	if v := os.Getenv("MANGAHUB_UDP_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			s.MaxAttempts = n
+		}
+	}
+	if v := os.Getenv("MANGAHUB_UDP_SUBSCRIPTION_REFRESH"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			s.SubscriptionRefresh = d
+		}
+	}
+	return s
 }
 
-// Start listens for UDP packets and handles registration and notifications.
-func (s *Server) Start() error {
-	addr, err := net.ResolveUDPAddr("udp", ":"+s.Port)
+// Start listens for UDP packets and handles registration and notifications
+// until ctx is cancelled or Shutdown is called. Cancelling ctx shuts the
+// server down gracefully within DefaultShutdownTimeout.
+func (s *Server) Start(ctx context.Context) error {
+	listen := s.Port
+	if !strings.Contains(listen, ":") {
+		listen = ":" + listen
+	}
+	addr, err := net.ResolveUDPAddr("udp", listen)
 	if err != nil {
 		return fmt.Errorf("udp resolve error: %w", err)
 	}
@@ -89,12 +264,53 @@ func (s *Server) Start() error {
 	}
 	defer conn.Close()
 
-	log.Println("UDP notification server listening on :" + s.Port)
+	s.lifeMu.Lock()
+	if s.closing {
+		s.lifeMu.Unlock()
+		return nil
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	log.Println("UDP notification server listening on " + conn.LocalAddr().String())
+	if len(s.SigningKey) == 0 {
+		log.Println("UDP: MANGAHUB_UDP_SIGNING_KEY not set, notifications are sent unsigned")
+	}
+
+	if s.DB != nil {
+		if err := s.LoadSubscriptions(); err != nil {
+			log.Println("UDP:", err)
+		}
+		s.goLoop(s.subscriptionLoop)
+	}
+	s.goLoop(s.expireLoop)
+	s.goLoop(func() { s.retransmitLoop(conn) })
+	if s.Bus != nil {
+		s.unsubscribe = s.Bus.Subscribe("udp", s.handleEvent, events.ChapterReleased, events.SubscriptionChanged)
+	}
+	s.lifeMu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Println("UDP: shutdown:", err)
+		}
+	})
+	defer stop()
 
 	buf := make([]byte, 4096)
 	for {
 		n, clientAddr, err := conn.ReadFromUDP(buf)
 		if err != nil {
+			select {
+			case <-s.quit:
+				return nil
+			default:
+			}
 			log.Println("udp read error:", err)
 			continue
 		}
@@ -114,14 +330,28 @@ func (s *Server) Start() error {
 			s.handleRegister(conn, clientAddr, data)
 		case "unregister":
 			s.handleUnregister(conn, clientAddr, data)
+		case "heartbeat":
+			s.handleHeartbeat(conn, clientAddr, data)
+		case "ack":
+			s.handleAck(clientAddr, data)
 		case "chapter_release":
-			s.handleNotification(conn, data)
+			s.handleNotification(conn, clientAddr, data)
 		default:
 			log.Println("udp unknown message type:", envelope.Type)
 		}
 	}
 }
 
+// Addr returns the address the server listens on, or nil before Start.
+func (s *Server) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
+
 // handleRegister processes a registration message from a UDP client (UC-009).
 func (s *Server) handleRegister(conn *net.UDPConn, addr *net.UDPAddr, data []byte) {
 	var msg RegisterMessage
@@ -131,21 +361,23 @@ func (s *Server) handleRegister(conn *net.UDPConn, addr *net.UDPAddr, data []byt
 		return
 	}
 
-	if msg.UserID == "" {
-		_ = s.sendRegisterResponse(conn, addr, "error", "missing_user_id")
+	_, userID, err := s.authenticate(msg.Token, msg.UserID)
+	if err != nil {
+		log.Printf("UDP: rejected register from %v: %v\n", addr, err)
+		_ = s.sendRegisterResponse(conn, addr, "error", err.Error())
 		return
 	}
 
 	s.registerClient(clientInfo{
 		Addr:        *addr,
-		UserID:      msg.UserID,
+		UserID:      userID,
 		MangaIDs:    msg.MangaIDs,
 		Preferences: msg.Preferences,
 		Label:       msg.ClientLabel,
 	})
 
-	log.Printf("UDP: registered client %s at %v (manga_ids=%v)\n", msg.UserID, addr, msg.MangaIDs)
-	_ = s.sendRegisterResponse(conn, addr, "ok", "registered")
+	log.Printf("UDP: registered client %s at %v (manga_ids=%v)\n", userID, addr, msg.MangaIDs)
+	_ = s.sendResponse(conn, addr, RegisterResponse{Type: "register_response", Status: "ok", Message: "registered", UserID: userID})
 }
 
 // handleUnregister removes client registrations.
@@ -155,21 +387,27 @@ func (s *Server) handleUnregister(conn *net.UDPConn, addr *net.UDPAddr, data []b
 		log.Println("udp unregister unmarshal error:", err)
 		return
 	}
-	if msg.UserID == "" {
+	_, userID, err := s.authenticate(msg.Token, msg.UserID)
+	if err != nil {
+		log.Printf("UDP: rejected unregister from %v: %v\n", addr, err)
+		_ = s.sendRegisterResponse(conn, addr, "error", err.Error())
 		return
 	}
 
-	s.unregisterClient(msg.UserID, msg.MangaIDs, addr)
-	log.Printf("UDP: unregistered client %s at %v (manga_ids=%v)\n", msg.UserID, addr, msg.MangaIDs)
+	s.unregisterClient(userID, msg.MangaIDs, addr, msg.ClientLabel)
+	log.Printf("UDP: unregistered client %s at %v (manga_ids=%v)\n", userID, addr, msg.MangaIDs)
 	_ = s.sendRegisterResponse(conn, addr, "ok", "unregistered")
 }
 
 func (s *Server) sendRegisterResponse(conn *net.UDPConn, addr *net.UDPAddr, status, message string) error {
-	resp := RegisterResponse{
+	return s.sendResponse(conn, addr, RegisterResponse{
 		Type:    "register_response",
 		Status:  status,
 		Message: message,
-	}
+	})
+}
+
+func (s *Server) sendResponse(conn *net.UDPConn, addr *net.UDPAddr, resp interface{}) error {
 	data, err := json.Marshal(resp)
 	if err != nil {
 		return err
@@ -179,63 +417,211 @@ func (s *Server) sendRegisterResponse(conn *net.UDPConn, addr *net.UDPAddr, stat
 }
 
 // handleNotification processes a chapter release notification (UC-010).
-func (s *Server) handleNotification(conn *net.UDPConn, data []byte) {
-	var notif Notification
-	if err := json.Unmarshal(data, &notif); err != nil {
+// Only admin and service tokens may publish.
+func (s *Server) handleNotification(conn *net.UDPConn, addr *net.UDPAddr, data []byte) {
+	var msg PublishMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
 		log.Println("udp notification unmarshal error:", err)
 		return
 	}
+	claims, _, err := s.authenticate(msg.Token, "")
+	if err == nil && !canPublish(claims) {
+		err = errors.New("forbidden")
+	}
+	if err != nil {
+		log.Printf("UDP: rejected chapter_release from %v: %v\n", addr, err)
+		_ = s.sendResponse(conn, addr, RegisterResponse{Type: "publish_response", Status: "error", Message: err.Error()})
+		return
+	}
+
+	notif := msg.Notification
+	notif.Seq, notif.Sig = 0, ""
 
 	if notif.Timestamp == 0 {
 		notif.Timestamp = time.Now().Unix()
 	}
 
-	log.Printf("UDP: broadcasting chapter release notification manga=%s chapter=%d\n",
-		notif.MangaID, notif.Chapter)
+	log.Printf("UDP: broadcasting chapter release notification manga=%s chapter=%d (by %s)\n",
+		notif.MangaID, notif.Chapter, claims.UserID)
 
 	s.broadcast(conn, notif)
+	_ = s.sendResponse(conn, addr, RegisterResponse{Type: "publish_response", Status: "ok", Message: "published"})
+}
+
+// Publish broadcasts a notification produced in-process (e.g. by the release
+// detector) to registered clients.
+func (s *Server) Publish(n Notification) error {
+	s.mu.RLock()
+	conn := s.conn
+	s.mu.RUnlock()
+	if conn == nil {
+		return errors.New("udp server not started")
+	}
+	if s.isClosing() {
+		return ErrServerClosed
+	}
+
+	if n.Type == "" {
+		n.Type = "chapter_release"
+	}
+	if n.Timestamp == 0 {
+		n.Timestamp = time.Now().Unix()
+	}
+
+	log.Printf("UDP: publishing chapter release notification manga=%s chapter=%d\n",
+		n.MangaID, n.Chapter)
+
+	s.broadcast(conn, n)
+	return nil
+}
+
+// handleHeartbeat refreshes a registration's expiry.
+func (s *Server) handleHeartbeat(conn *net.UDPConn, addr *net.UDPAddr, data []byte) {
+	var msg HeartbeatMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("udp heartbeat unmarshal error:", err)
+		return
+	}
+
+	status, message := "ok", ""
+	if _, userID, err := s.authenticate(msg.Token, msg.UserID); err != nil {
+		status, message = "error", err.Error()
+	} else if !s.touchClient(clientKey{UserID: userID, Addr: addr.String(), Label: msg.ClientLabel}) {
+		status, message = "error", "not_registered"
+	}
+
+	resp, err := json.Marshal(HeartbeatResponse{Type: "heartbeat_ack", Status: status, Message: message})
+	if err != nil {
+		return
+	}
+	_, _ = conn.WriteToUDP(resp, addr)
 }
 
-// registerClient adds a client to the notification list.
+// registerClient adds or refreshes a device registration. Other devices of
+// the same user are left untouched.
 func (s *Server) registerClient(info clientInfo) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Remove any existing entries for this user (any address) to avoid duplication.
-	var filtered []clientInfo
-	for _, c := range s.clients {
-		if c.UserID == info.UserID {
-			continue
-		}
-		filtered = append(filtered, c)
+	if s.DB != nil {
+		info.MangaIDs = nil // subscriptions come from the database
+	}
+
+	now := time.Now()
+	key := info.key()
+	if existing, ok := s.clients[key]; ok {
+		info.RegisteredAt = existing.RegisteredAt
+	} else {
+		info.RegisteredAt = now
 	}
-	filtered = append(filtered, info)
-	s.clients = filtered
+	info.LastSeen = now
+	s.clients[key] = &info
 }
 
-// unregisterClient removes entries for a user. If mangaIDs provided, remove matching those manga; otherwise remove all for the user (and address, if provided).
-func (s *Server) unregisterClient(userID string, mangaIDs []string, addr *net.UDPAddr) {
+// touchClient marks a registration as alive. Returns false if unknown.
+func (s *Server) touchClient(key clientKey) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var filtered []clientInfo
-	for _, c := range s.clients {
+	c, ok := s.clients[key]
+	if !ok {
+		return false
+	}
+	c.LastSeen = time.Now()
+	return true
+}
+
+// unregisterClient removes registrations for a user. Without mangaIDs the
+// device at addr (optionally narrowed by label) is removed; with mangaIDs
+// those manga are dropped from every device of the user.
+func (s *Server) unregisterClient(userID string, mangaIDs []string, addr *net.UDPAddr, label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, c := range s.clients {
 		if c.UserID != userID {
-			filtered = append(filtered, c)
 			continue
 		}
-		// If no mangaIDs specified, drop all for this user (any addr)
 		if len(mangaIDs) == 0 {
+			if addr != nil && key.Addr == addr.String() && (label == "" || key.Label == label) {
+				delete(s.clients, key)
+			}
 			continue
 		}
-		// Drop if there is overlap with requested mangaIDs (or stored entry has no mangaIDs)
-		if overlap(c.MangaIDs, mangaIDs) || len(c.MangaIDs) == 0 {
-			continue
+		var kept []string
+		for _, id := range c.MangaIDs {
+			if !overlap([]string{id}, mangaIDs) {
+				kept = append(kept, id)
+			}
+		}
+		c.MangaIDs = kept
+	}
+}
+
+// expireLoop periodically drops registrations that stopped heart-beating.
+func (s *Server) expireLoop() {
+	interval := s.ClientTTL / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
 		}
-		// Otherwise keep
-		filtered = append(filtered, c)
+		cutoff := time.Now().Add(-s.ClientTTL)
+		s.mu.Lock()
+		for key, c := range s.clients {
+			if c.LastSeen.Before(cutoff) {
+				delete(s.clients, key)
+				log.Printf("UDP: registration expired user=%s addr=%s label=%s\n", key.UserID, key.Addr, key.Label)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// snapshot returns a copy of the current registrations.
+func (s *Server) snapshot() []clientInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := make([]clientInfo, 0, len(s.clients))
+	for _, c := range s.clients {
+		info := *c
+		info.MangaIDs = s.mangaFor(c)
+		clients = append(clients, info)
+	}
+	return clients
+}
+
+// Registrations returns the current registration table, sorted by user.
+func (s *Server) Registrations() []Registration {
+	clients := s.snapshot()
+	regs := make([]Registration, 0, len(clients))
+	for _, c := range clients {
+		regs = append(regs, Registration{
+			UserID:       c.UserID,
+			Addr:         c.Addr.String(),
+			Label:        c.Label,
+			MangaIDs:     c.MangaIDs,
+			Preferences:  c.Preferences,
+			RegisteredAt: c.RegisteredAt,
+			LastSeen:     c.LastSeen,
+			ExpiresAt:    c.LastSeen.Add(s.ClientTTL),
+		})
 	}
-	s.clients = filtered
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].UserID != regs[j].UserID {
+			return regs[i].UserID < regs[j].UserID
+		}
+		return regs[i].Addr < regs[j].Addr
+	})
+	return regs
 }
 
 func overlap(a, b []string) bool {
@@ -249,29 +635,111 @@ func overlap(a, b []string) bool {
 	return false
 }
 
-// broadcast sends the notification to all registered clients (UC-010).
+// wants reports whether a client should receive n: either it registered for
+// the manga explicitly or one of its preferences matches the manga's genres.
+func (c clientInfo) wants(n Notification) bool {
+	for _, id := range c.MangaIDs {
+		if id == n.MangaID {
+			return true
+		}
+	}
+	for _, pref := range c.Preferences {
+		for _, g := range n.Genres {
+			if strings.EqualFold(strings.TrimSpace(pref), strings.TrimSpace(g)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// broadcast sends the notification to the clients subscribed to its manga
+// (UC-010).
 func (s *Server) broadcast(conn *net.UDPConn, n Notification) {
+	if !s.begin() {
+		log.Printf("UDP: shutting down, dropped notification manga=%s chapter=%d\n", n.MangaID, n.Chapter)
+		return
+	}
+	defer s.busy.Done()
+
+	clients := s.snapshot()
+
+	if len(n.Genres) == 0 && s.GenreLookup != nil {
+		for _, c := range clients {
+			if len(c.Preferences) > 0 {
+				n.Genres = s.GenreLookup(n.MangaID)
+				break
+			}
+		}
+	}
+
+	// Persist before sending so offline users still see it in their inbox.
+	recipients := s.recipients(n, clients)
+	if err := s.storeInbox(n, recipients); err != nil {
+		log.Println("UDP:", err)
+	}
+
+	n.Seq = s.nextSeq()
+	n.Sig = ""
+	if len(s.SigningKey) > 0 {
+		n.Sig = SignNotification(s.SigningKey, n)
+	}
 	data, err := json.Marshal(n)
 	if err != nil {
 		log.Println("udp marshal notification error:", err)
 		return
 	}
 
-	s.mu.RLock()
-	clients := make([]clientInfo, len(s.clients))
-	copy(clients, s.clients)
-	s.mu.RUnlock()
+	if n.Type == "chapter_release" {
+		s.publishRecipients(n, recipients)
+	}
+
+	if s.Dispatcher != nil {
+		for _, userID := range recipients {
+			s.Dispatcher.Dispatch(userID, n)
+		}
+		log.Printf("UDP: dispatched notification seq=%d to %d recipient(s) for manga=%s chapter=%d\n",
+			n.Seq, len(recipients), n.MangaID, n.Chapter)
+		return
+	}
 
+	sent := 0
 	for _, c := range clients {
-		if _, err := conn.WriteToUDP(data, &c.Addr); err != nil {
+		if !c.wants(n) {
+			continue
+		}
+		if err := s.send(conn, c, n.Seq, data); err != nil {
 			// A1/A2: client unreachable / network error - log and continue
 			log.Printf("udp send error to %v (user=%s): %v\n", c.Addr, c.UserID, err)
 			continue
 		}
+		sent++
+	}
+
+	log.Printf("UDP: delivered notification seq=%d to %d of %d client(s) for manga=%s chapter=%d\n",
+		n.Seq, sent, len(clients), n.MangaID, n.Chapter)
+}
+
+// SubscriberCounts returns, per manga ID, how many registered clients have
+// explicitly subscribed to it.
+func (s *Server) SubscriberCounts() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, c := range s.clients {
+		for _, id := range s.mangaFor(c) {
+			counts[id]++
+		}
 	}
+	return counts
+}
 
-	log.Printf("UDP: broadcasted notification to %d client(s) for manga=%s chapter=%d\n",
-		len(clients), n.MangaID, n.Chapter)
+// ClientCount returns the number of registered clients.
+func (s *Server) ClientCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clients)
 }
 
 // Helper to build a notification from CLI/admin args (optional convenience).
@@ -298,5 +766,3 @@ func ParseChapterFromEnv(key string, def int) int {
 	}
 	return n
 }
-
-
@@ -0,0 +1,130 @@
+package udp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds the graceful shutdown started by cancelling
+// Start's context.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ErrServerClosed is returned by Publish once the server is shutting down.
+var ErrServerClosed = errors.New("udp server shutting down")
+
+// ShutdownMessage tells registered clients the server is going away; their
+// heartbeats re-register them once it is back.
+type ShutdownMessage struct {
+	Type      string `json:"type"` // "server_shutdown"
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Shutdown stops the server gracefully: it stops taking events, lets the
+// broadcasts in progress and the Dispatcher's deliveries finish, sends
+// server_shutdown to every registered client, closes the socket and waits
+// for the read loop and background loops to return. Unacknowledged
+// deliveries are not retransmitted; the notifications stay in the
+// recipients' inboxes. When ctx expires first, ctx's error is returned. It
+// is safe to call more than once.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lifeMu.Lock()
+	first := !s.closing
+	s.closing = true
+	unsubscribe := s.unsubscribe
+	s.unsubscribe = nil
+	s.lifeMu.Unlock()
+
+	if first {
+		close(s.quit)
+		if unsubscribe != nil {
+			unsubscribe()
+		}
+		if err := wait(ctx, &s.busy); err != nil {
+			log.Println("UDP: shutdown: broadcasts still in progress:", err)
+		}
+		if s.Dispatcher != nil {
+			if err := s.Dispatcher.Close(ctx); err != nil {
+				log.Println("UDP: shutdown: notification deliveries still in progress:", err)
+			}
+		}
+
+		s.mu.RLock()
+		conn := s.conn
+		s.mu.RUnlock()
+		if conn != nil {
+			data, _ := json.Marshal(ShutdownMessage{Type: "server_shutdown", Timestamp: time.Now().Unix()})
+			notified := make(map[string]bool)
+			for _, c := range s.snapshot() {
+				if notified[c.Addr.String()] {
+					continue
+				}
+				notified[c.Addr.String()] = true
+				addr := c.Addr
+				if _, err := conn.WriteToUDP(data, &addr); err != nil {
+					log.Printf("UDP: shutdown notice to %s failed: %v\n", c.Addr.String(), err)
+				}
+			}
+
+			s.deliveryMu.Lock()
+			abandoned := len(s.pending)
+			s.deliveryMu.Unlock()
+			log.Printf("UDP: shutting down, notified %d client(s), %d delivery(ies) left unacknowledged\n",
+				len(notified), abandoned)
+			_ = conn.Close()
+		}
+	}
+
+	if err := wait(ctx, &s.wg); err != nil {
+		return err
+	}
+	if first {
+		log.Println("UDP: server stopped")
+	}
+	return nil
+}
+
+// begin registers a broadcast with the server; it reports false once the
+// server is shutting down. Callers that get true must call s.busy.Done.
+func (s *Server) begin() bool {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.busy.Add(1)
+	return true
+}
+
+func (s *Server) isClosing() bool {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	return s.closing
+}
+
+// goLoop runs a background loop tracked by Shutdown.
+func (s *Server) goLoop(loop func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		loop()
+	}()
+}
+
+// wait waits for wg or until ctx is done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,226 @@
+package udp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mangahub/internal/auth"
+	"mangahub/internal/events"
+	"mangahub/pkg/models"
+)
+
+var testSecret = []byte("test-secret")
+
+// startServer starts s on a free loopback port and returns its address and
+// the channel Start's result arrives on.
+func startServer(t *testing.T, s *Server) (*net.UDPAddr, <-chan error) {
+	t.Helper()
+	s.Port = "127.0.0.1:0"
+	s.JWTSecret = testSecret
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start(context.Background()) }()
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-errc:
+			t.Fatalf("Start: %v", err)
+		default:
+		}
+		if addr := s.Addr(); addr != nil {
+			return addr.(*net.UDPAddr), errc
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("server did not start listening")
+	return nil, nil
+}
+
+// register opens a client socket registered as userID for mangaID.
+func register(t *testing.T, addr *net.UDPAddr, userID, mangaID string) *net.UDPConn {
+	t.Helper()
+	token, err := auth.GenerateJWT(testSecret, &models.User{ID: userID, Username: userID})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	data, _ := json.Marshal(RegisterMessage{Type: "register", Token: token, MangaIDs: []string{mangaID}})
+	if _, err := conn.Write(data); err != nil {
+		t.Fatalf("send register: %v", err)
+	}
+	var resp RegisterResponse
+	read(t, conn, &resp)
+	if resp.Status != "ok" {
+		t.Fatalf("register rejected: %+v", resp)
+	}
+	return conn
+}
+
+// read decodes the next datagram into v.
+func read(t *testing.T, conn *net.UDPConn, v interface{}) {
+	t.Helper()
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := json.Unmarshal(buf[:n], v); err != nil {
+		t.Fatalf("decode %q: %v", buf[:n], err)
+	}
+}
+
+// untilShutdown reads datagrams until server_shutdown and returns the
+// distinct notification seqs received before it; retransmissions repeat a seq.
+func untilShutdown(t *testing.T, conn *net.UDPConn) map[uint64]bool {
+	t.Helper()
+	seqs := make(map[uint64]bool)
+	for {
+		var n Notification
+		read(t, conn, &n)
+		switch n.Type {
+		case "chapter_release":
+			seqs[n.Seq] = true
+		case "server_shutdown":
+			return seqs
+		default:
+			t.Fatalf("unexpected %s", n.Type)
+		}
+	}
+}
+
+// checkGoroutines fails the test unless the goroutine count falls back to
+// before within a few seconds, and dumps every goroutine if it does not.
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("%d goroutine(s) left after Shutdown, %d before Start:\n%s",
+				runtime.NumGoroutine(), before, buf)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// shutdown shuts s down and waits for Start to return.
+func shutdown(t *testing.T, s *Server, errc <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after Shutdown")
+	}
+}
+
+func TestShutdownLeavesNoGoroutines(t *testing.T) {
+	bus := events.NewBus()
+	before := runtime.NumGoroutine()
+
+	s := NewServer("")
+	s.Bus = bus
+	s.RetryBase = time.Hour // keep the deliveries pending
+	addr, errc := startServer(t, s)
+
+	clients := []*net.UDPConn{
+		register(t, addr, "user_a", "one-piece"),
+		register(t, addr, "user_a", "one-piece"),
+		register(t, addr, "user_b", "one-piece"),
+	}
+
+	// Nobody acks, so every delivery is still pending at Shutdown.
+	const published = 10
+	for i := 1; i <= published; i++ {
+		if err := s.Publish(Notification{MangaID: "one-piece", Title: "One Piece", Chapter: i}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	shutdown(t, s, errc)
+
+	for i, c := range clients {
+		if got := len(untilShutdown(t, c)); got != published {
+			t.Fatalf("client %d: %d notification(s) before server_shutdown, want %d", i, got, published)
+		}
+		c.Close()
+	}
+
+	checkGoroutines(t, before)
+
+	if err := s.Publish(Notification{MangaID: "one-piece", Chapter: published + 1}); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Publish after Shutdown: got %v, want %v", err, ErrServerClosed)
+	}
+}
+
+// slowDispatcher delivers each notification over UDP after a delay, from a
+// goroutine of its own, as notify.Dispatcher does.
+type slowDispatcher struct {
+	s      *Server
+	wg     sync.WaitGroup
+	closed atomic.Bool
+}
+
+func (d *slowDispatcher) Dispatch(userID string, n Notification) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		time.Sleep(50 * time.Millisecond)
+		_ = d.s.DeliverToUser(userID, n)
+	}()
+}
+
+func (d *slowDispatcher) Close(ctx context.Context) error {
+	d.closed.Store(true)
+	return wait(ctx, &d.wg)
+}
+
+func TestShutdownWaitsForDispatcher(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	s := NewServer("")
+	d := &slowDispatcher{s: s}
+	s.Dispatcher = d
+	addr, errc := startServer(t, s)
+	client := register(t, addr, "user_a", "one-piece")
+
+	const published = 5
+	for i := 1; i <= published; i++ {
+		if err := s.Publish(Notification{MangaID: "one-piece", Title: "One Piece", Chapter: i}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	shutdown(t, s, errc)
+
+	if !d.closed.Load() {
+		t.Fatal("Shutdown did not close the dispatcher")
+	}
+	if got := len(untilShutdown(t, client)); got != published {
+		t.Fatalf("%d dispatched notification(s) before server_shutdown, want %d", got, published)
+	}
+	client.Close()
+
+	checkGoroutines(t, before)
+}
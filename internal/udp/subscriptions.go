@@ -0,0 +1,98 @@
+package udp
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultSubscriptionRefresh is how often subscriptions are reloaded from the
+// database to pick up changes made outside this process.
+const DefaultSubscriptionRefresh = time.Minute
+
+// LoadSubscriptions replaces the in-memory subscription table with the
+// contents of user_notifications.
+func (s *Server) LoadSubscriptions() error {
+	if s.DB == nil {
+		return nil
+	}
+	rows, err := s.DB.Query(`SELECT user_id, manga_id FROM user_notifications`)
+	if err != nil {
+		return fmt.Errorf("query subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := make(map[string][]string)
+	for rows.Next() {
+		var userID, mangaID string
+		if err := rows.Scan(&userID, &mangaID); err != nil {
+			continue
+		}
+		subs[userID] = append(subs[userID], mangaID)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query subscriptions: %w", err)
+	}
+
+	s.mu.Lock()
+	s.subs = subs
+	s.mu.Unlock()
+	return nil
+}
+
+// RefreshUser reloads one user's subscriptions, e.g. right after they
+// subscribed or unsubscribed over HTTP.
+func (s *Server) RefreshUser(userID string) {
+	if s.DB == nil {
+		return
+	}
+	rows, err := s.DB.Query(`SELECT manga_id FROM user_notifications WHERE user_id = ?`, userID)
+	if err != nil {
+		log.Printf("UDP: reload subscriptions for %s: %v\n", userID, err)
+		return
+	}
+	defer rows.Close()
+
+	var mangaIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			mangaIDs = append(mangaIDs, id)
+		}
+	}
+
+	s.mu.Lock()
+	if len(mangaIDs) == 0 {
+		delete(s.subs, userID)
+	} else {
+		s.subs[userID] = mangaIDs
+	}
+	s.mu.Unlock()
+}
+
+// subscriptionLoop periodically reloads subscriptions from the database.
+func (s *Server) subscriptionLoop() {
+	ticker := time.NewTicker(s.SubscriptionRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+		}
+		if err := s.LoadSubscriptions(); err != nil {
+			log.Println("UDP:", err)
+		}
+	}
+}
+
+// mangaFor returns the manga a client follows: the user's database
+// subscriptions when a DB is configured, otherwise what the device registered.
+// Callers must hold s.mu.
+func (s *Server) mangaFor(c *clientInfo) []string {
+	if s.DB != nil {
+		return s.subs[c.UserID]
+	}
+	return c.MangaIDs
+}
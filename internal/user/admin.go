@@ -0,0 +1,82 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes registers the progress outbox endpoints. The router
+// group is expected to be restricted to admins.
+func RegisterAdminRoutes(r *gin.RouterGroup, svc *Service) {
+	h := &Handler{Service: svc}
+
+	r.GET("/outbox", h.HandleListOutbox)
+	r.POST("/outbox/:id/retry", h.HandleRetryOutbox)
+}
+
+// HandleListOutbox lists undelivered progress broadcasts with counts per
+// state. Optional query params: state (pending|failed), limit.
+func (h *Handler) HandleListOutbox(c *gin.Context) {
+	state := c.Query("state")
+	if state != "" && state != OutboxPending && state != OutboxFailed {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "state must be pending or failed",
+			"type":  "validation_error",
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	stats, err := h.Service.OutboxStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	entries, err := h.Service.ListOutbox(state, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pending": stats.Pending,
+		"failed":  stats.Failed,
+		"entries": entries,
+	})
+}
+
+// HandleRetryOutbox requeues an outbox entry for immediate delivery.
+func (h *Handler) HandleRetryOutbox(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid outbox entry id",
+			"type":  "validation_error",
+		})
+		return
+	}
+
+	if err := h.Service.RetryOutbox(id); err != nil {
+		if err.Error() == "not_found" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Outbox entry not found",
+				"type":  "not_found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Outbox entry queued for retry"})
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Conflict policies for progress updates that arrive out of order, e.g.
+// from a device that was offline:
+//   - lww: the update with the latest client timestamp wins.
+//   - max_chapter: the furthest chapter wins.
+//   - reject_stale: updates based on an old version, or older than the
+//     stored one, are rejected (HTTP 409).
+const (
+	ConflictLastWriterWins = "lww"
+	ConflictMaxChapter     = "max_chapter"
+	ConflictRejectStale    = "reject_stale"
+)
+
+// MaxClientClockSkew is how far ahead of the server's clock a client
+// timestamp may be. Later ones are clamped, so a device with a wrong clock
+// can't make every following update look stale.
+const MaxClientClockSkew = 5 * time.Minute
+
+// ProgressState is the authoritative progress for one manga, returned with
+// every update so clients can reconcile.
+type ProgressState struct {
+	MangaID         string `json:"manga_id"`
+	CurrentChapter  int    `json:"current_chapter"`
+	Status          string `json:"status"`
+	Version         int64  `json:"version"`
+	ClientTimestamp int64  `json:"client_timestamp"` // unix ms of the winning update
+	UpdatedAt       int64  `json:"updated_at"`       // unix seconds, server time
+}
+
+// validConflictPolicy reports whether p names a known policy.
+func validConflictPolicy(p string) bool {
+	switch p {
+	case ConflictLastWriterWins, ConflictMaxChapter, ConflictRejectStale:
+		return true
+	}
+	return false
+}
+
+// clampClientTimestamp limits ts (unix ms) to now plus MaxClientClockSkew.
+func clampClientTimestamp(ts int64, now time.Time) int64 {
+	if max := now.Add(MaxClientClockSkew).UnixMilli(); ts > max {
+		return max
+	}
+	return ts
+}
+
+// isStale reports whether req loses against the stored state under policy.
+// The stored timestamp is clamped too, for rows saved before clamping.
+func isStale(policy string, cur *ProgressState, req UpdateProgressRequest) bool {
+	stored := clampClientTimestamp(cur.ClientTimestamp, time.Now())
+	switch policy {
+	case ConflictMaxChapter:
+		return req.CurrentChapter < cur.CurrentChapter
+	case ConflictRejectStale:
+		if req.BaseVersion != 0 && req.BaseVersion != cur.Version {
+			return true
+		}
+		return req.ClientTimestamp < stored
+	default:
+		return req.ClientTimestamp < stored
+	}
+}
+
+// queryer is satisfied by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadProgress reads the stored progress for a library entry; it returns
+// sql.ErrNoRows if the manga is not in the library.
+func loadProgress(q queryer, userID, mangaID string) (*ProgressState, error) {
+	st := &ProgressState{MangaID: mangaID}
+	var status sql.NullString
+	var chapter sql.NullInt64
+	var updated sql.NullTime
+	err := q.QueryRow(
+		`SELECT current_chapter, status, version, client_updated_at, updated_at
+		FROM user_progress WHERE user_id = ? AND manga_id = ?`,
+		userID, mangaID,
+	).Scan(&chapter, &status, &st.Version, &st.ClientTimestamp, &updated)
+	if err != nil {
+		return nil, err
+	}
+	st.CurrentChapter = int(chapter.Int64)
+	st.Status = status.String
+	if updated.Valid {
+		st.UpdatedAt = updated.Time.Unix()
+	}
+	return st, nil
+}
+
+// nowMillis is the default client timestamp for updates that carry none.
+func nowMillis() int64 {
+	return time.Now().UnixMilli()
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,9 +18,11 @@ type libraryRequest struct {
 }
 
 type progressRequest struct {
-	MangaID        string `json:"manga_id" binding:"required"`
-	CurrentChapter int    `json:"current_chapter" binding:"gte=0"`
-	Status         string `json:"status"`
+	MangaID         string `json:"manga_id" binding:"required"`
+	CurrentChapter  int    `json:"current_chapter" binding:"gte=0"`
+	Status          string `json:"status"`
+	ClientTimestamp int64  `json:"client_timestamp"` // unix ms, optional
+	BaseVersion     int64  `json:"base_version"`     // optional
 }
 
 type notifyRequest struct {
@@ -37,6 +40,10 @@ func RegisterRoutes(r *gin.RouterGroup, svc *Service) {
 	r.POST("/users/notifications", h.HandleSubscribeNotifications)
 	r.GET("/users/notifications/:manga_id", h.HandleCheckNotificationSubscription)
 	r.DELETE("/users/notifications/:manga_id", h.HandleUnsubscribeNotifications)
+	r.GET("/users/notifications/inbox", h.HandleGetInbox)
+	r.GET("/users/notifications/inbox/unread-count", h.HandleUnreadCount)
+	r.POST("/users/notifications/inbox/read-all", h.HandleMarkAllRead)
+	r.POST("/users/notifications/inbox/:id/read", h.HandleMarkRead)
 }
 
 // HandleAddToLibrary implements UC-005: add manga to library.
@@ -158,9 +165,11 @@ func (h *Handler) HandleUpdateProgress(c *gin.Context) {
 	}
 
 	updateReq := UpdateProgressRequest{
-		MangaID:        req.MangaID,
-		CurrentChapter: req.CurrentChapter,
-		Status:         req.Status,
+		MangaID:         req.MangaID,
+		CurrentChapter:  req.CurrentChapter,
+		Status:          req.Status,
+		ClientTimestamp: req.ClientTimestamp,
+		BaseVersion:     req.BaseVersion,
 	}
 
 	result, err := h.Service.UpdateProgress(userID, updateReq)
@@ -198,15 +207,36 @@ func (h *Handler) HandleUpdateProgress(c *gin.Context) {
 		return
 	}
 
+	// Stale update: the stored progress wins and is returned for reconciling.
+	if result.Rejected {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "Progress update is stale",
+			"type":     "conflict",
+			"progress": result.Progress,
+		})
+		return
+	}
+	if !result.Applied {
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "A newer update is already stored; progress unchanged",
+			"applied":        false,
+			"progress":       result.Progress,
+			"broadcast_sent": false,
+		})
+		return
+	}
+
 	// UC-006 Main Success Scenario Step 5: Confirm update to user
 	response := gin.H{
 		"message":        "Progress updated successfully",
+		"applied":        true,
+		"progress":       result.Progress,
 		"broadcast_sent": result.BroadcastSent,
 	}
 
 	// UC-006 Alternative Flow A2: TCP server unavailable - inform user but confirm local update
 	if !result.BroadcastSent && result.BroadcastError != "" {
-		response["broadcast_error"] = "Progress updated locally, but broadcast failed (will be queued)"
+		response["broadcast_error"] = "Progress updated locally, but broadcast failed (queued for retry)"
 		response["warning"] = "TCP server unavailable. Progress saved locally."
 	}
 
@@ -334,3 +364,105 @@ func (h *Handler) HandleUnsubscribeNotifications(c *gin.Context) {
 		"message": "Notifications disabled for this manga",
 	})
 }
+
+// HandleGetInbox lists the user's stored notifications.
+// Query: page (default 1), limit (default 20, max 100), unread=true.
+func (h *Handler) HandleGetInbox(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	inbox, err := h.Service.GetInbox(userID, page, limit, unreadOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, inbox)
+}
+
+// HandleUnreadCount returns the number of unread notifications.
+func (h *Handler) HandleUnreadCount(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	count, err := h.Service.UnreadNotificationCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// HandleMarkRead marks a single notification as read.
+func (h *Handler) HandleMarkRead(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid notification id",
+			"type":  "validation_error",
+		})
+		return
+	}
+
+	if err := h.Service.MarkNotificationRead(userID, id); err != nil {
+		if err.Error() == "not_found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// HandleMarkAllRead marks all of the user's notifications as read.
+func (h *Handler) HandleMarkAllRead(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	updated, err := h.Service.MarkAllNotificationsRead(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error. Please try again.",
+			"type":  "database_error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "All notifications marked as read",
+		"updated": updated,
+	})
+}
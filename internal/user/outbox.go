@@ -0,0 +1,240 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"mangahub/internal/auth"
+	"mangahub/internal/events"
+)
+
+// Outbox retry defaults: 2s, 4s, 8s ... capped at 5m, failing after 10
+// attempts.
+const (
+	DefaultOutboxRetryBase   = 2 * time.Second
+	DefaultOutboxMaxAttempts = 10
+	DefaultOutboxInterval    = time.Second
+
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxClaim keeps the dispatcher away from a row while a delivery
+	// attempt for it is in flight.
+	outboxClaim = 30 * time.Second
+)
+
+// Outbox entry states.
+const (
+	OutboxPending = "pending"
+	OutboxFailed  = "failed"
+)
+
+// OutboxEntry is a progress broadcast that has not been delivered yet.
+type OutboxEntry struct {
+	ID            int64     `json:"id"`
+	UserID        string    `json:"user_id"`
+	MangaID       string    `json:"manga_id"`
+	Chapter       int       `json:"chapter"`
+	Status        string    `json:"status,omitempty"`
+	Version       int64     `json:"version"`
+	State         string    `json:"state"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+
+	ClientTimestamp int64 `json:"client_timestamp"` // unix ms
+}
+
+// OutboxStats counts outbox entries by state.
+type OutboxStats struct {
+	Pending int `json:"pending"`
+	Failed  int `json:"failed"`
+}
+
+// deliverProgress hands a progress update to the user's devices: directly
+// to the TCP server when it runs in-process, otherwise over TCP. Either way
+// an error means the update was not delivered.
+func (s *Service) deliverProgress(e *OutboxEntry) error {
+	if s.DeliverProgress != nil {
+		return s.DeliverProgress(events.Progress{
+			UserID:    e.UserID,
+			MangaID:   e.MangaID,
+			Chapter:   e.Chapter,
+			Status:    e.Status,
+			Timestamp: e.CreatedAt.Unix(),
+
+			Version:         e.Version,
+			ClientTimestamp: e.ClientTimestamp,
+		})
+	}
+
+	token, err := auth.GenerateServiceJWT(s.JWTSecret, "user-service", time.Minute)
+	if err != nil {
+		return err
+	}
+	return BroadcastProgress(s.TCPAddr, s.TCPTLS, token, ProgressUpdate{
+		UserID:          e.UserID,
+		MangaID:         e.MangaID,
+		Chapter:         e.Chapter,
+		Status:          e.Status,
+		Timestamp:       e.CreatedAt.Unix(),
+		Version:         e.Version,
+		ClientTimestamp: e.ClientTimestamp,
+	})
+}
+
+// deliverOutbox attempts one delivery of e, removing it on success and
+// scheduling a retry (or marking it failed) otherwise.
+func (s *Service) deliverOutbox(e *OutboxEntry) error {
+	err := s.deliverProgress(e)
+	if err == nil {
+		if _, dbErr := s.DB.Exec(`DELETE FROM progress_outbox WHERE id = ?`, e.ID); dbErr != nil {
+			log.Printf("[Outbox] remove delivered entry %d: %v", e.ID, dbErr)
+		}
+		return nil
+	}
+
+	attempts := e.Attempts + 1
+	state := OutboxPending
+	if attempts >= s.OutboxMaxAttempts {
+		state = OutboxFailed
+		log.Printf("[Outbox] giving up on progress for user=%s manga=%s after %d attempt(s): %v",
+			e.UserID, e.MangaID, attempts, err)
+	}
+	backoff := s.OutboxRetryBase << uint(attempts-1)
+	if backoff <= 0 || backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	_, dbErr := s.DB.Exec(
+		`UPDATE progress_outbox SET state = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		state, attempts, time.Now().Add(backoff).Unix(), err.Error(), e.ID,
+	)
+	if dbErr != nil {
+		log.Printf("[Outbox] update entry %d: %v", e.ID, dbErr)
+	}
+	return err
+}
+
+// RunOutbox retries pending progress broadcasts every OutboxInterval until
+// ctx is cancelled.
+func (s *Service) RunOutbox(ctx context.Context) {
+	ticker := time.NewTicker(s.OutboxInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flushOutbox()
+		}
+	}
+}
+
+// flushOutbox retries every due entry, oldest first.
+func (s *Service) flushOutbox() {
+	now := time.Now()
+	due, err := s.queryOutbox(
+		`WHERE state = ? AND next_attempt_at <= ? ORDER BY id LIMIT 100`,
+		OutboxPending, now.Unix(),
+	)
+	if err != nil {
+		log.Printf("[Outbox] query due entries: %v", err)
+		return
+	}
+
+	for i := range due {
+		e := &due[i]
+		_, _ = s.DB.Exec(`UPDATE progress_outbox SET next_attempt_at = ? WHERE id = ?`,
+			now.Add(outboxClaim).Unix(), e.ID)
+		if err := s.deliverOutbox(e); err == nil {
+			log.Printf("[Outbox] delivered progress for user=%s manga=%s on attempt %d",
+				e.UserID, e.MangaID, e.Attempts+1)
+		}
+	}
+}
+
+// ListOutbox returns up to limit undelivered entries, optionally filtered by
+// state, oldest first.
+func (s *Service) ListOutbox(state string, limit int) ([]OutboxEntry, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var entries []OutboxEntry
+	var err error
+	if state != "" {
+		entries, err = s.queryOutbox(`WHERE state = ? ORDER BY id LIMIT ?`, state, limit)
+	} else {
+		entries, err = s.queryOutbox(`ORDER BY id LIMIT ?`, limit)
+	}
+	if err != nil {
+		log.Printf("Error querying outbox: %v", err)
+		return nil, errors.New("database_error: failed to query outbox")
+	}
+	return entries, nil
+}
+
+// OutboxStats counts the undelivered entries.
+func (s *Service) OutboxStats() (*OutboxStats, error) {
+	var stats OutboxStats
+	err := s.DB.QueryRow(
+		`SELECT
+			COALESCE(SUM(CASE WHEN state = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN state = ? THEN 1 ELSE 0 END), 0)
+		FROM progress_outbox`,
+		OutboxPending, OutboxFailed,
+	).Scan(&stats.Pending, &stats.Failed)
+	if err != nil {
+		log.Printf("Error counting outbox: %v", err)
+		return nil, errors.New("database_error: failed to count outbox")
+	}
+	return &stats, nil
+}
+
+// RetryOutbox puts a failed entry back in the queue for immediate delivery.
+func (s *Service) RetryOutbox(id int64) error {
+	result, err := s.DB.Exec(
+		`UPDATE progress_outbox SET state = ?, attempts = 0, next_attempt_at = ? WHERE id = ?`,
+		OutboxPending, time.Now().Unix(), id,
+	)
+	if err != nil {
+		log.Printf("Error requeueing outbox entry: %v", err)
+		return errors.New("database_error: failed to requeue outbox entry")
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errors.New("not_found")
+	}
+	return nil
+}
+
+func (s *Service) queryOutbox(where string, args ...interface{}) ([]OutboxEntry, error) {
+	rows, err := s.DB.Query(
+		`SELECT id, user_id, manga_id, chapter, status, version, client_updated_at, state, attempts,
+			last_error, created_at, next_attempt_at
+		FROM progress_outbox `+where,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []OutboxEntry{}
+	for rows.Next() {
+		var e OutboxEntry
+		var status, lastError sql.NullString
+		var created, next int64
+		if err := rows.Scan(&e.ID, &e.UserID, &e.MangaID, &e.Chapter, &status, &e.Version,
+			&e.ClientTimestamp, &e.State, &e.Attempts, &lastError, &created, &next); err != nil {
+			continue
+		}
+		e.Status = status.String
+		e.LastError = lastError.String
+		e.CreatedAt = time.Unix(created, 0).UTC()
+		e.NextAttemptAt = time.Unix(next, 0).UTC()
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -1,12 +1,16 @@
 package user
 
 import (
+	"crypto/tls"
 	"database/sql"
 	"errors"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
+	"mangahub/internal/events"
+	"mangahub/internal/tlsutil"
 	"mangahub/pkg/models"
 )
 
@@ -14,7 +18,31 @@ import (
 type Service struct {
 	DB       *sql.DB
 	TCPAddr  string       // TCP server address for broadcasting progress updates
+	TCPTLS   *tls.Config  // TLS for the TCP connection; nil means plaintext
 	MangaSvc MangaService // Interface to get manga metadata for validation
+
+	// ConflictPolicy resolves progress updates that arrive out of order;
+	// see the Conflict* constants.
+	ConflictPolicy string
+
+	// Outbox retry policy for progress broadcasts that fail.
+	OutboxRetryBase   time.Duration
+	OutboxMaxAttempts int
+	OutboxInterval    time.Duration
+
+	// JWTSecret signs the short-lived service token used to authenticate
+	// with the TCP server; it must match the TCP server's secret.
+	JWTSecret []byte
+
+	// Bus, if set, receives library and subscription events for the TCP,
+	// UDP and SSE servers running in the same process.
+	Bus *events.Bus
+
+	// DeliverProgress, if set, hands progress updates to a TCP server in the
+	// same process; an error keeps the update in the outbox for retry.
+	// Without it progress is sent to the TCP server at TCPAddr over the
+	// network.
+	DeliverProgress func(events.Progress) error
 }
 
 // MangaService interface for getting manga metadata.
@@ -22,15 +50,50 @@ type MangaService interface {
 	GetMangaByID(mangaID string) (*models.Manga, error)
 }
 
+// NewService creates a Service configured from environment variables:
+// - MANGAHUB_TCP_ADDR (default localhost:9090)
+// - MANGAHUB_OUTBOX_RETRY_BASE (Go duration, default 2s)
+// - MANGAHUB_OUTBOX_MAX_ATTEMPTS (default 10)
+// - MANGAHUB_PROGRESS_CONFLICT_POLICY (lww, max_chapter or reject_stale; default lww)
 func NewService(db *sql.DB) *Service {
 	tcpAddr := os.Getenv("MANGAHUB_TCP_ADDR")
 	if tcpAddr == "" {
 		tcpAddr = "localhost:9090" // Default TCP server port
 	}
-	return &Service{
-		DB:      db,
-		TCPAddr: tcpAddr,
+	svc := &Service{
+		DB:                db,
+		TCPAddr:           tcpAddr,
+		OutboxRetryBase:   DefaultOutboxRetryBase,
+		OutboxMaxAttempts: DefaultOutboxMaxAttempts,
+		OutboxInterval:    DefaultOutboxInterval,
+		ConflictPolicy:    ConflictLastWriterWins,
+	}
+	if v := os.Getenv("MANGAHUB_PROGRESS_CONFLICT_POLICY"); v != "" {
+		if validConflictPolicy(v) {
+			svc.ConflictPolicy = v
+		} else {
+			log.Printf("Unknown MANGAHUB_PROGRESS_CONFLICT_POLICY %q, using %s", v, svc.ConflictPolicy)
+		}
+	}
+	if v := os.Getenv("MANGAHUB_OUTBOX_RETRY_BASE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			svc.OutboxRetryBase = d
+		}
+	}
+	if v := os.Getenv("MANGAHUB_OUTBOX_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			svc.OutboxMaxAttempts = n
+		}
+	}
+	if cfg := tlsutil.ClientFromEnv("TCP"); cfg != nil {
+		tlsConfig, err := cfg.TLSConfig()
+		if err != nil {
+			log.Printf("TCP TLS client config invalid, falling back to system roots: %v", err)
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		svc.TCPTLS = tlsConfig
 	}
+	return svc
 }
 
 // SetMangaService sets the manga service for validation.
@@ -40,8 +103,8 @@ func (s *Service) SetMangaService(mangaSvc MangaService) {
 
 // -------------------- Notification Subscriptions (UC-009) --------------------
 
-// SubscribeToMangaNotifications subscribes the user to notifications for a manga
-// and registers them with the UDP notification server.
+// SubscribeToMangaNotifications subscribes the user to notifications for a manga.
+// The UDP server routes by these rows; devices only attach an address.
 func (s *Service) SubscribeToMangaNotifications(userID, mangaID string) error {
 	if userID == "" || mangaID == "" {
 		return errors.New("validation_error: user_id and manga_id are required")
@@ -57,18 +120,9 @@ func (s *Service) SubscribeToMangaNotifications(userID, mangaID string) error {
 		return errors.New("database_error: failed to save notification subscription")
 	}
 
-	// Register client with UDP notification server based on userID and mangaID.
-	// For this project we use localhost:9091; this can be moved to config if needed.
-	_, udpErr := RegisterForUDPNotifications(UDPRegisterOptions{
-		ServerAddr: "localhost:9091",
-		UserID:     userID,
-		MangaIDs:   []string{mangaID},
+	s.publish(events.SubscriptionChanged, userID, events.SubscriptionChange{
+		UserID: userID, MangaID: mangaID, Subscribed: true,
 	})
-	if udpErr != nil {
-		log.Printf("UDP registration failed for user %s manga %s: %v", userID, mangaID, udpErr)
-		// We still treat subscription as successful in DB; frontend can show a warning if needed.
-	}
-
 	return nil
 }
 
@@ -90,18 +144,28 @@ func (s *Service) IsSubscribedToMangaNotifications(userID, mangaID string) (bool
 	return exists, nil
 }
 
-// ReRegisterNotification is a best-effort UDP registration, useful when the UDP server was down.
-func (s *Service) ReRegisterNotification(userID, mangaID string) {
-	if userID == "" || mangaID == "" {
-		return
+// ListSubscriptions returns the IDs of the manga the user gets chapter
+// notifications for.
+func (s *Service) ListSubscriptions(userID string) ([]string, error) {
+	rows, err := s.DB.Query(
+		`SELECT manga_id FROM user_notifications WHERE user_id = ? ORDER BY manga_id`,
+		userID,
+	)
+	if err != nil {
+		log.Printf("Error querying notification subscriptions: %v", err)
+		return nil, errors.New("database_error: failed to query notification subscriptions")
 	}
-	if _, err := RegisterForUDPNotifications(UDPRegisterOptions{
-		ServerAddr: "localhost:9091",
-		UserID:     userID,
-		MangaIDs:   []string{mangaID},
-	}); err != nil {
-		log.Printf("UDP re-register failed for user %s manga %s: %v", userID, mangaID, err)
+	defer rows.Close()
+
+	mangaIDs := []string{}
+	for rows.Next() {
+		var mangaID string
+		if err := rows.Scan(&mangaID); err != nil {
+			continue
+		}
+		mangaIDs = append(mangaIDs, mangaID)
 	}
+	return mangaIDs, rows.Err()
 }
 
 // UnsubscribeFromMangaNotifications removes a user's subscription for a manga.
@@ -119,18 +183,134 @@ func (s *Service) UnsubscribeFromMangaNotifications(userID, mangaID string) erro
 		return errors.New("database_error: failed to delete notification subscription")
 	}
 
-	// Send unregister to UDP server (best effort)
-	if udpErr := UnregisterForUDPNotifications(UDPUnregisterOptions{
-		ServerAddr: "localhost:9091",
-		UserID:     userID,
-		MangaIDs:   []string{mangaID},
-	}); udpErr != nil {
-		log.Printf("UDP unregister failed for user %s manga %s: %v", userID, mangaID, udpErr)
+	s.publish(events.SubscriptionChanged, userID, events.SubscriptionChange{
+		UserID: userID, MangaID: mangaID, Subscribed: false,
+	})
+	return nil
+}
+
+// publish sends an event to the bus, if there is one.
+func (s *Service) publish(eventType, userID string, payload interface{}) {
+	if s.Bus != nil {
+		s.Bus.Publish(events.Event{Type: eventType, UserID: userID, Source: "user", Payload: payload})
 	}
+}
 
+// -------------------- Notification Inbox --------------------
+
+// InboxPage is one page of a user's notification inbox.
+type InboxPage struct {
+	Data        []models.InboxNotification `json:"data"`
+	Total       int                        `json:"total"`
+	UnreadCount int                        `json:"unread_count"`
+	Page        int                        `json:"page"`
+	Limit       int                        `json:"limit"`
+	TotalPages  int                        `json:"total_pages"`
+}
+
+// GetInbox returns a page of the user's stored notifications, newest first.
+// With unreadOnly only notifications not yet marked read are returned.
+func (s *Service) GetInbox(userID string, page, limit int, unreadOnly bool) (*InboxPage, error) {
+	where := `WHERE user_id = ?`
+	if unreadOnly {
+		where += ` AND read_at IS NULL`
+	}
+
+	var total int
+	if err := s.DB.QueryRow(`SELECT COUNT(*) FROM notifications `+where, userID).Scan(&total); err != nil {
+		log.Printf("Error counting notifications: %v", err)
+		return nil, errors.New("database_error: failed to query notifications")
+	}
+	unread, err := s.UnreadNotificationCount(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.Query(
+		`SELECT id, type, manga_id, title, chapter, message, created_at, read_at
+		FROM notifications `+where+`
+		ORDER BY id DESC LIMIT ? OFFSET ?`,
+		userID, limit, (page-1)*limit,
+	)
+	if err != nil {
+		log.Printf("Error querying notifications: %v", err)
+		return nil, errors.New("database_error: failed to query notifications")
+	}
+	defer rows.Close()
+
+	items := []models.InboxNotification{}
+	for rows.Next() {
+		n := models.InboxNotification{UserID: userID}
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.Type, &n.MangaID, &n.Title, &n.Chapter, &n.Message, &n.CreatedAt, &readAt); err != nil {
+			log.Printf("Error scanning notification row: %v", err)
+			continue
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+			n.Read = true
+		}
+		items = append(items, n)
+	}
+
+	return &InboxPage{
+		Data:        items,
+		Total:       total,
+		UnreadCount: unread,
+		Page:        page,
+		Limit:       limit,
+		TotalPages:  (total + limit - 1) / limit,
+	}, nil
+}
+
+// UnreadNotificationCount returns how many inbox notifications are unread.
+func (s *Service) UnreadNotificationCount(userID string) (int, error) {
+	var count int
+	err := s.DB.QueryRow(
+		`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL`,
+		userID,
+	).Scan(&count)
+	if err != nil {
+		log.Printf("Error counting unread notifications: %v", err)
+		return 0, errors.New("database_error: failed to query notifications")
+	}
+	return count, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications as read.
+// Marking an already read notification is a no-op.
+func (s *Service) MarkNotificationRead(userID string, id int64) error {
+	result, err := s.DB.Exec(
+		`UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = ? AND user_id = ?`,
+		id, userID,
+	)
+	if err != nil {
+		log.Printf("Error marking notification read: %v", err)
+		return errors.New("database_error: failed to update notification")
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errors.New("not_found")
+	}
 	return nil
 }
 
+// MarkAllNotificationsRead marks every unread notification of the user as
+// read and returns how many were updated.
+func (s *Service) MarkAllNotificationsRead(userID string) (int64, error) {
+	result, err := s.DB.Exec(
+		`UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND read_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		log.Printf("Error marking notifications read: %v", err)
+		return 0, errors.New("database_error: failed to update notifications")
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
 // AddToLibraryRequest represents a request to add manga to user's library.
 type AddToLibraryRequest struct {
 	MangaID        string
@@ -147,26 +327,23 @@ type AddToLibraryResult struct {
 // Returns AddToLibraryResult indicating whether manga was newly added or already existed.
 func (s *Service) AddToLibrary(userID string, req AddToLibraryRequest) (*AddToLibraryResult, error) {
 	// UC-005 Alternative Flow A1: Check if manga already exists in user's library
-	var alreadyInLibrary bool
-	err := s.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM user_progress WHERE user_id = ? AND manga_id = ?)",
-		userID, req.MangaID,
-	).Scan(&alreadyInLibrary)
+	oldStatus, alreadyInLibrary, err := s.libraryStatus(userID, req.MangaID)
 	if err != nil {
-		log.Printf("Error checking library entry: %v", err)
-		return nil, errors.New("database_error: failed to check library entry")
+		return nil, err
 	}
 
 	// UC-005 Main Success Scenario: Create user_progress record
 	// Works for any manga_id (local DB manga or MangaDex manga)
 	_, err = s.DB.Exec(
-		`INSERT INTO user_progress (user_id, manga_id, current_chapter, status) 
-		 VALUES (?, ?, ?, ?)
+		`INSERT INTO user_progress (user_id, manga_id, current_chapter, status, version, client_updated_at) 
+		 VALUES (?, ?, ?, ?, 1, ?)
 		 ON CONFLICT(user_id, manga_id) DO UPDATE SET 
 			current_chapter = excluded.current_chapter, 
 			status = excluded.status, 
+			version = user_progress.version + 1,
+			client_updated_at = excluded.client_updated_at,
 			updated_at = CURRENT_TIMESTAMP`,
-		userID, req.MangaID, req.CurrentChapter, req.Status,
+		userID, req.MangaID, req.CurrentChapter, req.Status, nowMillis(),
 	)
 	if err != nil {
 		log.Printf("Error adding to library: %v", err)
@@ -177,17 +354,43 @@ func (s *Service) AddToLibrary(userID string, req AddToLibraryRequest) (*AddToLi
 	result := &AddToLibraryResult{}
 	if alreadyInLibrary {
 		result.Status = "already_exists"
+		if req.Status != oldStatus {
+			s.publish(events.LibraryChanged, userID, events.LibraryChange{
+				UserID: userID, MangaID: req.MangaID, Action: events.LibraryStatusChanged, Status: req.Status,
+			})
+		}
 	} else {
 		result.Status = "newly_added"
+		s.publish(events.LibraryChanged, userID, events.LibraryChange{
+			UserID: userID, MangaID: req.MangaID, Action: events.LibraryAdded,
+			Chapter: req.CurrentChapter, Status: req.Status,
+		})
 	}
 
 	return result, nil
 }
 
+// libraryStatus returns the status of a library entry and whether it exists.
+func (s *Service) libraryStatus(userID, mangaID string) (string, bool, error) {
+	var status sql.NullString
+	err := s.DB.QueryRow(
+		"SELECT status FROM user_progress WHERE user_id = ? AND manga_id = ?",
+		userID, mangaID,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		log.Printf("Error checking library entry: %v", err)
+		return "", false, errors.New("database_error: failed to check library entry")
+	}
+	return status.String, true, nil
+}
+
 // GetLibrary retrieves all manga in user's library.
 func (s *Service) GetLibrary(userID string) ([]models.UserProgress, error) {
 	rows, err := s.DB.Query(
-		`SELECT manga_id, current_chapter, status, updated_at 
+		`SELECT manga_id, current_chapter, status, updated_at, version 
 		FROM user_progress 
 		WHERE user_id = ? 
 		ORDER BY updated_at DESC`,
@@ -203,7 +406,7 @@ func (s *Service) GetLibrary(userID string) ([]models.UserProgress, error) {
 	for rows.Next() {
 		var p models.UserProgress
 		p.UserID = userID
-		if err := rows.Scan(&p.MangaID, &p.CurrentChapter, &p.Status, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.MangaID, &p.CurrentChapter, &p.Status, &p.UpdatedAt, &p.Version); err != nil {
 			log.Printf("Error scanning library row: %v", err)
 			continue
 		}
@@ -218,7 +421,7 @@ func (s *Service) RemoveFromLibrary(userID, mangaID string) error {
 		return errors.New("validation_error: user_id and manga_id are required")
 	}
 
-	_, err := s.DB.Exec(
+	result, err := s.DB.Exec(
 		`DELETE FROM user_progress WHERE user_id = ? AND manga_id = ?`,
 		userID, mangaID,
 	)
@@ -227,6 +430,11 @@ func (s *Service) RemoveFromLibrary(userID, mangaID string) error {
 		return errors.New("database_error: failed to remove library entry")
 	}
 
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		s.publish(events.LibraryChanged, userID, events.LibraryChange{
+			UserID: userID, MangaID: mangaID, Action: events.LibraryRemoved,
+		})
+	}
 	return nil
 }
 
@@ -235,28 +443,38 @@ type UpdateProgressRequest struct {
 	MangaID        string
 	CurrentChapter int
 	Status         string
+	// ClientTimestamp is when the device made the change, in unix
+	// milliseconds; 0 means now. Timestamps more than MaxClientClockSkew
+	// ahead of the server are clamped.
+	ClientTimestamp int64
+	// BaseVersion is the progress version the device last saw, 0 if unknown.
+	// Only the reject_stale policy looks at it.
+	BaseVersion int64
 }
 
 // UpdateProgressResult represents the result of updating progress (UC-006).
 type UpdateProgressResult struct {
-	BroadcastSent  bool   // Whether TCP broadcast was successfully sent
-	BroadcastError string // Error message if broadcast failed (for queuing)
+	Applied        bool           // False if the update lost a conflict and was not stored
+	Rejected       bool           // The reject_stale policy refused the update
+	Progress       *ProgressState // Authoritative state after the update
+	BroadcastSent  bool           // Whether the update was published to connected devices
+	BroadcastError string         // Error message if broadcast failed; it stays in the outbox for retry
 }
 
 // UpdateProgress updates user's reading progress for a manga (UC-006).
 // Precondition: Manga must be in user's library.
 // Validates chapter number against manga metadata.
-// Updates progress with timestamp and broadcasts via TCP.
+// Updates progress with timestamp and broadcasts it to the user's devices:
+// directly when the TCP server runs in-process, otherwise over a TCP
+// connection. Broadcasts that fail are retried from the outbox.
+//
+// Updates that arrive out of order are resolved by ConflictPolicy; one that
+// loses is not stored or broadcast, and the result carries the stored state.
 func (s *Service) UpdateProgress(userID string, req UpdateProgressRequest) (*UpdateProgressResult, error) {
 	// UC-006 Precondition: Check if manga is in user's library
-	var existsInLibrary bool
-	err := s.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM user_progress WHERE user_id = ? AND manga_id = ?)",
-		userID, req.MangaID,
-	).Scan(&existsInLibrary)
+	oldStatus, existsInLibrary, err := s.libraryStatus(userID, req.MangaID)
 	if err != nil {
-		log.Printf("Error checking library entry: %v", err)
-		return nil, errors.New("database_error: failed to check library entry")
+		return nil, err
 	}
 	if !existsInLibrary {
 		return nil, errors.New("validation_error: manga is not in user's library")
@@ -277,50 +495,144 @@ func (s *Service) UpdateProgress(userID string, req UpdateProgressRequest) (*Upd
 		// If manga not found in local DB (e.g., MangaDex manga), allow update
 		// The frontend dropdown already limits selection to valid range
 	}
+	if req.ClientTimestamp == 0 {
+		req.ClientTimestamp = nowMillis()
+	} else if ts := clampClientTimestamp(req.ClientTimestamp, time.Now()); ts != req.ClientTimestamp {
+		log.Printf("Progress for user=%s manga=%s claims client time %d, clamped to %d",
+			userID, req.MangaID, req.ClientTimestamp, ts)
+		req.ClientTimestamp = ts
+	}
 
-	// UC-006 Main Success Scenario Step 3: Update user_progress record with timestamp
-	result, err := s.DB.Exec(
+	// UC-006 Main Success Scenario Step 3: Update user_progress record with
+	// timestamp, queueing the broadcast in the same transaction so it can't
+	// be lost if delivery fails.
+	entry := &OutboxEntry{
+		UserID:          userID,
+		MangaID:         req.MangaID,
+		Chapter:         req.CurrentChapter,
+		Status:          req.Status,
+		State:           OutboxPending,
+		ClientTimestamp: req.ClientTimestamp,
+		CreatedAt:       time.Now(),
+	}
+	state, applied, err := s.saveProgress(entry, req)
+	if err != nil {
+		return nil, err
+	}
+	broadcastResult := &UpdateProgressResult{Applied: applied, Progress: state}
+	if !applied {
+		broadcastResult.Rejected = s.ConflictPolicy == ConflictRejectStale
+		log.Printf("Stale progress for user=%s manga=%s not applied (%s): chapter %d, stored chapter %d version %d",
+			userID, req.MangaID, s.ConflictPolicy, req.CurrentChapter, state.CurrentChapter, state.Version)
+		return broadcastResult, nil
+	}
+	if req.Status != "" && req.Status != oldStatus {
+		s.publish(events.LibraryChanged, userID, events.LibraryChange{
+			UserID: userID, MangaID: req.MangaID, Action: events.LibraryStatusChanged, Status: req.Status,
+		})
+	}
+
+	// UC-006 Main Success Scenario Step 4: Broadcast to connected clients
+	if err := s.deliverOutbox(entry); err != nil {
+		// UC-006 Alternative Flow A2: TCP server unavailable - the outbox
+		// dispatcher retries the broadcast.
+		log.Printf("TCP broadcast failed (queued for retry): %v", err)
+		broadcastResult.BroadcastError = err.Error()
+		// Still return success since local update succeeded
+	} else {
+		broadcastResult.BroadcastSent = true
+	}
+
+	return broadcastResult, nil
+}
+
+// saveProgress applies req to the user_progress row unless it loses against
+// the stored state under the conflict policy, and records e in the outbox in
+// the same transaction. Older undelivered updates for the same manga are
+// superseded and dropped. It returns the authoritative state and whether
+// req was applied.
+func (s *Service) saveProgress(e *OutboxEntry, req UpdateProgressRequest) (*ProgressState, bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting progress transaction: %v", err)
+		return nil, false, errors.New("database_error: failed to update progress")
+	}
+	defer tx.Rollback()
+
+	cur, err := loadProgress(tx, e.UserID, e.MangaID)
+	if err == sql.ErrNoRows {
+		return nil, false, errors.New("validation_error: manga is not in user's library")
+	}
+	if err != nil {
+		log.Printf("Error loading progress: %v", err)
+		return nil, false, errors.New("database_error: failed to update progress")
+	}
+	if isStale(s.ConflictPolicy, cur, req) {
+		return cur, false, nil
+	}
+
+	e.Version = cur.Version + 1
+	result, err := tx.Exec(
 		`UPDATE user_progress 
 		SET current_chapter = ?, 
 			status = COALESCE(NULLIF(?, ''), status), 
+			version = ?,
+			client_updated_at = ?,
 			updated_at = CURRENT_TIMESTAMP 
-		WHERE user_id = ? AND manga_id = ?`,
-		req.CurrentChapter, req.Status, userID, req.MangaID,
+		WHERE user_id = ? AND manga_id = ? AND version = ?`,
+		e.Chapter, e.Status, e.Version, e.ClientTimestamp, e.UserID, e.MangaID, cur.Version,
 	)
 	if err != nil {
 		log.Printf("Error updating progress: %v", err)
-		return nil, errors.New("database_error: failed to update progress")
+		return nil, false, errors.New("database_error: failed to update progress")
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Printf("Error getting rows affected: %v", err)
 	} else if rowsAffected == 0 {
-		return nil, errors.New("validation_error: manga is not in user's library")
+		// Another update got in first; it is newer than the one we checked.
+		return cur, false, nil
 	}
 
-	// UC-006 Main Success Scenario Step 4: Trigger TCP broadcast to connected clients
-	update := ProgressUpdate{
-		UserID:    userID,
-		MangaID:   req.MangaID,
-		Chapter:   req.CurrentChapter,
-		Timestamp: time.Now().Unix(),
+	if _, err := tx.Exec(
+		`DELETE FROM progress_outbox WHERE user_id = ? AND manga_id = ? AND state = ?`,
+		e.UserID, e.MangaID, OutboxPending,
+	); err != nil {
+		log.Printf("Error clearing superseded outbox entries: %v", err)
+		return nil, false, errors.New("database_error: failed to update progress")
 	}
-
-	broadcastResult := &UpdateProgressResult{
-		BroadcastSent:  false,
-		BroadcastError: "",
+	// The claim keeps the dispatcher off the row during the first attempt.
+	res, err := tx.Exec(
+		`INSERT INTO progress_outbox (user_id, manga_id, chapter, status, version, client_updated_at,
+			created_at, state, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.UserID, e.MangaID, e.Chapter, e.Status, e.Version, e.ClientTimestamp,
+		e.CreatedAt.Unix(), OutboxPending, e.CreatedAt.Add(outboxClaim).Unix(),
+	)
+	if err != nil {
+		log.Printf("Error queueing progress broadcast: %v", err)
+		return nil, false, errors.New("database_error: failed to update progress")
+	}
+	if e.ID, err = res.LastInsertId(); err != nil {
+		return nil, false, errors.New("database_error: failed to update progress")
 	}
 
-	err = BroadcastProgress(s.TCPAddr, update)
-	if err != nil {
-		// UC-006 Alternative Flow A2: TCP server unavailable - update locally, queue broadcast
-		log.Printf("TCP broadcast failed (will be queued/retried): %v", err)
-		broadcastResult.BroadcastError = err.Error()
-		// Still return success since local update succeeded
-	} else {
-		broadcastResult.BroadcastSent = true
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing progress update: %v", err)
+		return nil, false, errors.New("database_error: failed to update progress")
 	}
 
-	return broadcastResult, nil
+	state := &ProgressState{
+		MangaID:         e.MangaID,
+		CurrentChapter:  e.Chapter,
+		Status:          cur.Status,
+		Version:         e.Version,
+		ClientTimestamp: e.ClientTimestamp,
+		UpdatedAt:       e.CreatedAt.Unix(),
+	}
+	if e.Status != "" {
+		state.Status = e.Status
+	}
+	return state, true, nil
 }
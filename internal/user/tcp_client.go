@@ -2,6 +2,7 @@ package user
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"log"
@@ -16,14 +17,20 @@ type ProgressUpdate struct {
 	UserID    string `json:"user_id"`
 	MangaID   string `json:"manga_id"`
 	Chapter   int    `json:"chapter"`
+	Status    string `json:"status,omitempty"`
 	Timestamp int64  `json:"timestamp"`
+
+	// Version and ClientTimestamp (unix ms) describe the stored progress
+	// this update produced.
+	Version         int64 `json:"version,omitempty"`
+	ClientTimestamp int64 `json:"client_timestamp,omitempty"`
 }
 
 // authRequest is sent immediately after connecting to authenticate and register.
 type authRequest struct {
 	Type   string `json:"type"`            // "auth"
-	UserID string `json:"user_id"`         // required
-	Token  string `json:"token,omitempty"` // reserved for future JWT-based auth
+	UserID string `json:"user_id"` // user the connection acts for
+	Token  string `json:"token"`   // JWT; service tokens may name any user
 }
 
 // authResponse is returned by the TCP server after auth.
@@ -35,23 +42,26 @@ type authResponse struct {
 
 // BroadcastProgress sends a progress update to the TCP server following UC-007:
 // 1) Client initiates TCP connection.
-// 2) Client sends authentication message with user ID.
-// 3) Server validates and registers connection.
+// 2) Client sends authentication message with a JWT and user ID.
+// 3) Server validates the token and registers connection.
 // 4) Server confirms registration.
 // 5) Client sends progress update for broadcast.
 //
+// A non-nil tlsConfig connects over TLS.
+//
 // Returns an error if the TCP server is unavailable or authentication fails.
-func BroadcastProgress(tcpAddr string, update ProgressUpdate) error {
+func BroadcastProgress(tcpAddr string, tlsConfig *tls.Config, token string, update ProgressUpdate) error {
 	if tcpAddr == "" {
 		tcpAddr = "localhost:9090" // Default TCP server port
 	}
 
-	conn, err := net.DialTimeout("tcp", tcpAddr, 2*time.Second)
+	conn, err := dialTCP(tcpAddr, tlsConfig)
 	if err != nil {
 		log.Printf("TCP server unavailable at %s: %v", tcpAddr, err)
 		return err // Return error so caller can queue/retry
 	}
 	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
 
 	reader := bufio.NewReader(conn)
 
@@ -59,6 +69,7 @@ func BroadcastProgress(tcpAddr string, update ProgressUpdate) error {
 	auth := authRequest{
 		Type:   "auth",
 		UserID: update.UserID,
+		Token:  token,
 	}
 	if auth.UserID == "" {
 		return errors.New("missing user ID for TCP auth")
@@ -97,6 +108,15 @@ func BroadcastProgress(tcpAddr string, update ProgressUpdate) error {
 	return nil
 }
 
+// dialTCP connects to the TCP server, over TLS when tlsConfig is set.
+func dialTCP(addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	if tlsConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	}
+	return dialer.Dial("tcp", addr)
+}
+
 // writeJSONLine marshals v to JSON and writes it followed by '\n'.
 func writeJSONLine(conn net.Conn, v interface{}) error {
 	data, err := json.Marshal(v)
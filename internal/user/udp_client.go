@@ -1,15 +1,20 @@
 package user
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"time"
+
+	"mangahub/internal/udp"
 )
 
 // UDPRegisterOptions defines parameters for registering for UDP notifications.
+// Token is the caller's JWT; UserID is only needed with service tokens.
 type UDPRegisterOptions struct {
 	ServerAddr  string   // e.g. "localhost:9091"
+	Token       string
 	UserID      string
 	MangaIDs    []string
 	Preferences []string
@@ -17,8 +22,10 @@ type UDPRegisterOptions struct {
 }
 
 // UDPNotification represents a chapter release notification to send via UDP.
+// Token must belong to an admin or service account.
 type UDPNotification struct {
 	ServerAddr string
+	Token      string
 	MangaID    string
 	Title      string
 	Chapter    int
@@ -28,6 +35,7 @@ type UDPNotification struct {
 // Unregister options for UDP.
 type UDPUnregisterOptions struct {
 	ServerAddr string // e.g. "localhost:9091"
+	Token      string
 	UserID     string
 	MangaIDs   []string // optional: if empty, remove all for user
 }
@@ -35,7 +43,8 @@ type UDPUnregisterOptions struct {
 // internal types mirror internal/udp structures.
 type udpRegisterMessage struct {
 	Type        string   `json:"type"`
-	UserID      string   `json:"user_id"`
+	Token       string   `json:"token"`
+	UserID      string   `json:"user_id,omitempty"`
 	MangaIDs    []string `json:"manga_ids,omitempty"`
 	Preferences []string `json:"preferences,omitempty"`
 	ClientLabel string   `json:"client_label,omitempty"`
@@ -45,10 +54,12 @@ type udpRegisterResponse struct {
 	Type    string `json:"type"`
 	Status  string `json:"status"`
 	Message string `json:"message"`
+	UserID  string `json:"user_id,omitempty"`
 }
 
 type udpNotification struct {
 	Type      string `json:"type"`
+	Token     string `json:"token"`
 	MangaID   string `json:"manga_id"`
 	Title     string `json:"title"`
 	Chapter   int    `json:"chapter"`
@@ -61,8 +72,8 @@ func RegisterForUDPNotifications(opts UDPRegisterOptions) (string, error) {
 	if opts.ServerAddr == "" {
 		opts.ServerAddr = "localhost:9091"
 	}
-	if opts.UserID == "" {
-		return "", fmt.Errorf("user ID is required for UDP registration")
+	if opts.Token == "" {
+		return "", fmt.Errorf("token is required for UDP registration")
 	}
 
 	serverAddr, err := net.ResolveUDPAddr("udp", opts.ServerAddr)
@@ -78,6 +89,7 @@ func RegisterForUDPNotifications(opts UDPRegisterOptions) (string, error) {
 
 	msg := udpRegisterMessage{
 		Type:        "register",
+		Token:       opts.Token,
 		UserID:      opts.UserID,
 		MangaIDs:    opts.MangaIDs,
 		Preferences: opts.Preferences,
@@ -132,6 +144,7 @@ func SendUDPNotification(n UDPNotification) error {
 
 	notif := udpNotification{
 		Type:      "chapter_release",
+		Token:     n.Token,
 		MangaID:   n.MangaID,
 		Title:     n.Title,
 		Chapter:   n.Chapter,
@@ -146,6 +159,21 @@ func SendUDPNotification(n UDPNotification) error {
 	if _, err := conn.Write(data); err != nil {
 		return err
 	}
+
+	// The server answers with a publish_response saying whether it accepted.
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	nr, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	var resp udpRegisterResponse
+	if err := json.Unmarshal(buf[:nr], &resp); err != nil {
+		return err
+	}
+	if resp.Status != "ok" {
+		return fmt.Errorf("udp publish error: %s", resp.Message)
+	}
 	return nil
 }
 
@@ -155,8 +183,8 @@ func UnregisterForUDPNotifications(opts UDPUnregisterOptions) error {
 	if addr == "" {
 		addr = "localhost:9091"
 	}
-	if opts.UserID == "" {
-		return fmt.Errorf("user ID is required for UDP unregister")
+	if opts.Token == "" {
+		return fmt.Errorf("token is required for UDP unregister")
 	}
 
 	serverAddr, err := net.ResolveUDPAddr("udp", addr)
@@ -172,10 +200,12 @@ func UnregisterForUDPNotifications(opts UDPUnregisterOptions) error {
 
 	msg := struct {
 		Type     string   `json:"type"`
-		UserID   string   `json:"user_id"`
+		Token    string   `json:"token"`
+		UserID   string   `json:"user_id,omitempty"`
 		MangaIDs []string `json:"manga_ids,omitempty"`
 	}{
 		Type:     "unregister",
+		Token:    opts.Token,
 		UserID:   opts.UserID,
 		MangaIDs: opts.MangaIDs,
 	}
@@ -191,5 +221,218 @@ func UnregisterForUDPNotifications(opts UDPUnregisterOptions) error {
 	return nil
 }
 
+// UDPNotificationMessage is a chapter release notification received from the
+// UDP server.
+type UDPNotificationMessage struct {
+	Type      string   `json:"type"`
+	MangaID   string   `json:"manga_id"`
+	Title     string   `json:"title"`
+	Chapter   int      `json:"chapter"`
+	Message   string   `json:"message"`
+	Timestamp int64    `json:"timestamp"`
+	Genres    []string `json:"genres,omitempty"`
+	Seq       uint64   `json:"seq,omitempty"`
+	Sig       string   `json:"sig,omitempty"`
+}
+
+// UDPListenOptions configures a long-lived notification listener.
+type UDPListenOptions struct {
+	UDPRegisterOptions
+	HeartbeatInterval time.Duration // default 30s; must be below the server's client TTL
+
+	// SigningKey, when set, drops notifications whose signature doesn't
+	// verify and signs acks (MANGAHUB_UDP_SIGNING_KEY on the server).
+	SigningKey []byte
+}
+
+// ListenForUDPNotifications registers a device with the UDP server and
+// delivers notifications to handle until ctx is cancelled. It keeps the
+// registration alive with heartbeats and re-registers if the server has
+// forgotten it (e.g. after a restart or expiry). Every notification is
+// acknowledged; retransmitted duplicates are acked again but not redelivered.
+// With a SigningKey, forged notifications are discarded without an ack.
+// A server_shutdown message is passed to handle as well; listening goes on.
+func ListenForUDPNotifications(ctx context.Context, opts UDPListenOptions, handle func(UDPNotificationMessage)) error {
+	if opts.ServerAddr == "" {
+		opts.ServerAddr = "localhost:9091"
+	}
+	if opts.Token == "" {
+		return fmt.Errorf("token is required for UDP registration")
+	}
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = 30 * time.Second
+	}
+
+	serverAddr, err := net.ResolveUDPAddr("udp", opts.ServerAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp", nil, serverAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock the read loop on cancellation.
+	go func() {
+		<-ctx.Done()
+		_ = conn.SetReadDeadline(time.Now())
+	}()
+
+	register := func() error {
+		return writeUDPJSON(conn, udpRegisterMessage{
+			Type:        "register",
+			Token:       opts.Token,
+			UserID:      opts.UserID,
+			MangaIDs:    opts.MangaIDs,
+			Preferences: opts.Preferences,
+			ClientLabel: opts.ClientLabel,
+		})
+	}
+	if err := register(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(opts.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = writeUDPJSON(conn, udpHeartbeatMessage{
+					Type:        "heartbeat",
+					Token:       opts.Token,
+					UserID:      opts.UserID,
+					ClientLabel: opts.ClientLabel,
+				})
+			}
+		}
+	}()
+
+	// The server echoes the user it authenticated; acks are keyed on it.
+	userID := opts.UserID
+	seen := newSeqWindow(256)
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		var envelope struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			UserID  string `json:"user_id"`
+		}
+		if err := json.Unmarshal(buf[:n], &envelope); err != nil {
+			continue
+		}
+
+		switch envelope.Type {
+		case "register_response":
+			if envelope.Status != "ok" {
+				return fmt.Errorf("udp register error: %s", envelope.Message)
+			}
+			if envelope.UserID != "" {
+				userID = envelope.UserID
+			}
+		case "heartbeat_ack":
+			if envelope.Status != "ok" && envelope.Message == "not_registered" {
+				if err := register(); err != nil {
+					return err
+				}
+			}
+		case "server_shutdown":
+			// Pass it on but keep listening: the heartbeat re-registers
+			// once the server is back.
+			var notif UDPNotificationMessage
+			if err := json.Unmarshal(buf[:n], &notif); err != nil {
+				continue
+			}
+			handle(notif)
+		case "chapter_release", "digest":
+			var notif UDPNotificationMessage
+			if err := json.Unmarshal(buf[:n], &notif); err != nil {
+				continue
+			}
+			if len(opts.SigningKey) > 0 && !udp.VerifyNotification(opts.SigningKey, udp.Notification{
+				Type:      notif.Type,
+				MangaID:   notif.MangaID,
+				Title:     notif.Title,
+				Chapter:   notif.Chapter,
+				Message:   notif.Message,
+				Timestamp: notif.Timestamp,
+				Genres:    notif.Genres,
+				Seq:       notif.Seq,
+				Sig:       notif.Sig,
+			}) {
+				continue
+			}
+			if notif.Seq != 0 {
+				// Ack even duplicates: the previous ack may have been lost.
+				ack := udp.AckMessage{
+					Type:        "ack",
+					Seq:         notif.Seq,
+					UserID:      userID,
+					ClientLabel: opts.ClientLabel,
+				}
+				if len(opts.SigningKey) > 0 {
+					ack.Sig = udp.SignAck(opts.SigningKey, ack)
+				}
+				_ = writeUDPJSON(conn, ack)
+				if !seen.add(notif.Seq) {
+					continue
+				}
+			}
+			handle(notif)
+		}
+	}
+}
+
+type udpHeartbeatMessage struct {
+	Type        string `json:"type"`
+	Token       string `json:"token"`
+	UserID      string `json:"user_id,omitempty"`
+	ClientLabel string `json:"client_label,omitempty"`
+}
+
+// seqWindow remembers the most recent notification sequence IDs so that
+// retransmissions are not handled twice.
+type seqWindow struct {
+	seen  map[uint64]bool
+	order []uint64
+	size  int
+}
+
+func newSeqWindow(size int) *seqWindow {
+	return &seqWindow{seen: make(map[uint64]bool), size: size}
+}
 
+// add records seq and reports whether it was new.
+func (w *seqWindow) add(seq uint64) bool {
+	if w.seen[seq] {
+		return false
+	}
+	if len(w.order) >= w.size {
+		delete(w.seen, w.order[0])
+		w.order = w.order[1:]
+	}
+	w.seen[seq] = true
+	w.order = append(w.order, seq)
+	return true
+}
 
+func writeUDPJSON(conn *net.UDPConn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
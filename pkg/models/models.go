@@ -9,6 +9,7 @@ type User struct {
 	Username     string    `json:"username"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Role         string    `json:"role,omitempty"` // "admin" or empty; set out of band
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -21,6 +22,7 @@ type Manga struct {
 	TotalChapters int      `json:"total_chapters"`
 	Description   string   `json:"description"`
 	CoverURL      string   `json:"cover_url"`
+	AltIDs        []string `json:"alt_ids,omitempty"` // IDs of the same series at other providers
 }
 
 type UserProgress struct {
@@ -29,6 +31,7 @@ type UserProgress struct {
 	CurrentChapter int       `json:"current_chapter"`
 	Status         string    `json:"status"`
 	UpdatedAt      time.Time `json:"updated_at"`
+	Version        int64     `json:"version"`
 }
 
 // UserNotification represents a user's subscription to notifications for a manga.
@@ -37,3 +40,18 @@ type UserNotification struct {
 	MangaID   string    `json:"manga_id"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// InboxNotification is a notification stored for one recipient, shown in the
+// web app's notification center regardless of UDP delivery.
+type InboxNotification struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id"`
+	Type      string     `json:"type"`
+	MangaID   string     `json:"manga_id"`
+	Title     string     `json:"title"`
+	Chapter   int        `json:"chapter"`
+	Message   string     `json:"message"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	Read      bool       `json:"read"`
+}
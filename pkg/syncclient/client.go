@@ -0,0 +1,409 @@
+// Package syncclient is a Go client for the MangaHub TCP sync server. A
+// Client stays connected in the background: it answers heartbeats,
+// reconnects with exponential backoff and resumes from the last sequence
+// number it delivered, so no update is lost or delivered twice while the
+// server still has it in its replay log.
+//
+//	c, err := syncclient.Dial(ctx, syncclient.Config{Token: token})
+//	if err != nil { ... }
+//	for u := range c.Updates() {
+//		fmt.Println(u.Type, u.MangaID, u.Chapter)
+//	}
+//	if err := c.Err(); err != nil { ... }
+package syncclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client defaults.
+const (
+	DefaultAddr         = "localhost:9090"
+	DefaultVersion      = 2
+	DefaultMinBackoff   = 500 * time.Millisecond
+	DefaultMaxBackoff   = 30 * time.Second
+	DefaultDialTimeout  = 10 * time.Second
+	DefaultReadTimeout  = 90 * time.Second // the server pings every 30s
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultBufferSize   = 256
+	DefaultMaxFrameSize = 1 << 20
+)
+
+// ErrNotConnected is returned by sends while the client is reconnecting.
+var ErrNotConnected = errors.New("syncclient: not connected")
+
+// AuthError is a rejected auth handshake. Retrying with the same token can't
+// succeed, so the client stops.
+type AuthError struct {
+	Reason string // e.g. invalid_token
+}
+
+func (e *AuthError) Error() string { return "syncclient: auth rejected: " + e.Reason }
+
+// transientAuthErrors are auth failures worth retrying.
+var transientAuthErrors = map[string]bool{
+	"server_at_capacity":  true,
+	"registration_failed": true,
+	"server_shutdown":     true,
+}
+
+// Config configures a Client. Only Token is required.
+type Config struct {
+	Addr      string      // host:port, default localhost:9090
+	Token     string      // JWT from POST /auth/login
+	UserID    string      // user to act for; admin and service tokens only
+	TLSConfig *tls.Config // nil connects in plaintext
+
+	// Version is the protocol version requested, default 2.
+	Version int
+	// Framing is FramingJSON (default) or FramingProtobuf. A server that
+	// doesn't know protobuf framing answers in JSON, and the client follows.
+	Framing string
+	// LastSeq resumes from a sequence number saved by an earlier client;
+	// 0 starts with live updates only.
+	LastSeq uint64
+
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration // reconnect when the server is silent this long
+	WriteTimeout time.Duration
+	BufferSize   int // capacity of the Updates channel
+	MaxFrameSize int // largest line or frame accepted from the server
+
+	// Logf receives connection state changes; default log.Printf.
+	Logf func(format string, args ...interface{})
+}
+
+// Client is a long-lived, self-healing connection to the TCP sync server.
+type Client struct {
+	cfg     Config
+	updates chan Update
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex // guards conn and serializes writes
+	conn    net.Conn   // nil while reconnecting
+	userID  string
+	framing string // negotiated for conn
+
+	seqMu   sync.Mutex
+	lastSeq uint64
+
+	err  error // terminal error, set before updates is closed
+	done chan struct{}
+}
+
+// Dial connects and authenticates, then keeps the connection alive in the
+// background until ctx is cancelled or Close is called. It fails if the
+// first connection attempt does.
+func Dial(ctx context.Context, cfg Config) (*Client, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("syncclient: token is required")
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultAddr
+	}
+	if cfg.Version <= 0 {
+		cfg.Version = DefaultVersion
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = DefaultReadTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	if cfg.MaxFrameSize <= 0 {
+		cfg.MaxFrameSize = DefaultMaxFrameSize
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = log.Printf
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Client{
+		cfg:     cfg,
+		updates: make(chan Update, cfg.BufferSize),
+		cancel:  cancel,
+		lastSeq: cfg.LastSeq,
+		done:    make(chan struct{}),
+	}
+
+	r, err := c.connect(ctx, cfg.LastSeq != 0)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go c.run(ctx, r)
+	return c, nil
+}
+
+// Updates delivers the server's messages in order. It is closed when the
+// client stops; Err then reports why. A consumer that stops reading stalls
+// the connection until the server drops it, after which the client resumes.
+func (c *Client) Updates() <-chan Update { return c.updates }
+
+// Err returns the error that stopped the client, or nil if it was stopped
+// by Close or its context. It is only meaningful once Updates is closed.
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Close disconnects and stops reconnecting. Updates is closed shortly after.
+func (c *Client) Close() {
+	c.cancel()
+	<-c.done
+}
+
+// UserID returns the user the server authenticated.
+func (c *Client) UserID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userID
+}
+
+// LastSeq returns the sequence number of the last update delivered. Saving
+// it as Config.LastSeq lets a later client resume where this one stopped.
+func (c *Client) LastSeq() uint64 {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	return c.lastSeq
+}
+
+// SendProgress sends a progress update. The stored update comes back on
+// Updates (as progress, or progress_conflict if a newer one is stored).
+func (c *Client) SendProgress(p Progress) error {
+	if p.ClientTimestamp == 0 {
+		p.ClientTimestamp = time.Now().UnixMilli()
+	}
+	return c.send(progressMessage{
+		Type:            TypeProgress,
+		UserID:          c.UserID(),
+		MangaID:         p.MangaID,
+		Chapter:         p.Chapter,
+		Status:          p.Status,
+		Timestamp:       time.Now().Unix(),
+		ClientTimestamp: p.ClientTimestamp,
+		BaseVersion:     p.BaseVersion,
+	})
+}
+
+// RequestSnapshot asks for the user's whole library; it arrives on Updates
+// as a full_snapshot with the given requestID. Needs protocol version 2.
+func (c *Client) RequestSnapshot(requestID string) error {
+	return c.send(snapshotRequest{Type: "full_snapshot_request", RequestID: requestID})
+}
+
+// send writes v on the current connection in its framing.
+func (c *Client) send(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	data, err := encodeMessage(c.framing, v)
+	if err != nil {
+		return err
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// connect dials and authenticates, resuming from lastSeq when resume is set.
+func (c *Client) connect(ctx context.Context, resume bool) (*bufio.Reader, error) {
+	dialer := &net.Dialer{Timeout: c.cfg.DialTimeout}
+	var conn net.Conn
+	var err error
+	if c.cfg.TLSConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.cfg.TLSConfig}).DialContext(ctx, "tcp", c.cfg.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msg := authMessage{Type: "auth", UserID: c.cfg.UserID, Token: c.cfg.Token,
+		Version: c.cfg.Version, Framing: c.cfg.Framing}
+	if resume {
+		seq := c.LastSeq()
+		msg.LastSeq = &seq
+	}
+	data, _ := json.Marshal(msg)
+	_ = conn.SetDeadline(time.Now().Add(c.cfg.DialTimeout))
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	line, err := readLine(r, c.cfg.MaxFrameSize)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var resp authResponse
+	if err := json.Unmarshal(line, &resp); err != nil || resp.Type != "auth_response" {
+		conn.Close()
+		return nil, fmt.Errorf("syncclient: unexpected auth response %q", line)
+	}
+	if resp.Status != "ok" {
+		conn.Close()
+		if transientAuthErrors[resp.Error] {
+			return nil, errors.New("syncclient: " + resp.Error)
+		}
+		return nil, &AuthError{Reason: resp.Error}
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c.seqMu.Lock()
+	switch {
+	case !resume:
+		c.lastSeq = resp.LastSeq // live updates only, from here on
+	case resp.Truncated:
+		c.lastSeq = 0 // the replay restarts below our seq or skips some
+	}
+	c.seqMu.Unlock()
+
+	c.mu.Lock()
+	c.conn = conn
+	c.userID = resp.UserID
+	c.framing = resp.Framing
+	c.mu.Unlock()
+
+	if resume {
+		c.cfg.Logf("syncclient: connected to %s as %s, %d update(s) replayed", c.cfg.Addr, resp.UserID, resp.Replayed)
+	} else {
+		c.cfg.Logf("syncclient: connected to %s as %s", c.cfg.Addr, resp.UserID)
+	}
+	if resp.Truncated && resp.Version >= 2 {
+		// Some updates are gone; a snapshot brings the client back in sync.
+		_ = c.RequestSnapshot("resync")
+	}
+	return r, nil
+}
+
+// run serves the connection and reconnects until ctx is done or auth fails.
+func (c *Client) run(ctx context.Context, r *bufio.Reader) {
+	defer close(c.done)
+	defer close(c.updates)
+
+	backoff := c.cfg.MinBackoff
+	for {
+		err := c.serve(ctx, r)
+		c.mu.Lock()
+		c.conn.Close()
+		c.conn = nil
+		c.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		c.cfg.Logf("syncclient: connection lost: %v", err)
+
+		for {
+			// Full jitter keeps a fleet of clients from reconnecting in step.
+			wait := time.Duration(rand.Int63n(int64(backoff)) + 1)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			r, err = c.connect(ctx, true)
+			if err == nil {
+				backoff = c.cfg.MinBackoff
+				break
+			}
+			var authErr *AuthError
+			if errors.As(err, &authErr) {
+				c.err = err
+				return
+			}
+			c.cfg.Logf("syncclient: reconnect failed: %v", err)
+			if backoff *= 2; backoff > c.cfg.MaxBackoff {
+				backoff = c.cfg.MaxBackoff
+			}
+		}
+	}
+}
+
+// serve reads from the current connection until it fails or ctx is done.
+func (c *Client) serve(ctx context.Context, r *bufio.Reader) error {
+	c.mu.Lock()
+	conn, framing := c.conn, c.framing
+	c.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(c.cfg.ReadTimeout))
+		line, err := readMessage(r, framing, c.cfg.MaxFrameSize)
+		if err != nil {
+			return err
+		}
+
+		var u Update
+		if err := json.Unmarshal(line, &u); err != nil {
+			continue
+		}
+		switch u.Type {
+		case "ping":
+			_ = c.send(heartbeat{Type: "pong", Timestamp: time.Now().Unix()})
+			continue
+		case "pong":
+			continue
+		case TypeSnapshot:
+			var snap Snapshot
+			if err := json.Unmarshal(line, &snap); err != nil {
+				continue
+			}
+			u.Snapshot = &snap
+			c.seqMu.Lock()
+			if snap.LastSeq > c.lastSeq {
+				c.lastSeq = snap.LastSeq
+			}
+			c.seqMu.Unlock()
+		default:
+			if u.Seq != 0 {
+				c.seqMu.Lock()
+				dup := u.Seq <= c.lastSeq
+				if !dup {
+					c.lastSeq = u.Seq
+				}
+				c.seqMu.Unlock()
+				if dup {
+					continue
+				}
+			}
+		}
+
+		select {
+		case c.updates <- u:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
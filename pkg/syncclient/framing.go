@@ -0,0 +1,88 @@
+package syncclient
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "mangahub/proto"
+)
+
+// Framings for Config.Framing. The auth exchange is always a JSON line;
+// with protobuf both sides then switch to frames of a 4-byte big-endian
+// length followed by a SyncMessage from mangahub/proto.
+const (
+	FramingJSON     = "json"
+	FramingProtobuf = "protobuf"
+)
+
+// ErrFrameTooLarge is returned when the server sends a line or frame over
+// Config.MaxFrameSize; the client reconnects.
+var ErrFrameTooLarge = errors.New("syncclient: frame too large")
+
+// readLine reads one newline-terminated line of at most max bytes.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return nil, ErrFrameTooLarge
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// readMessage reads the next message in the given framing and returns it as
+// JSON.
+func readMessage(r *bufio.Reader, framing string, max int) ([]byte, error) {
+	if framing != FramingProtobuf {
+		return readLine(r, max)
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > uint32(max) {
+		return nil, ErrFrameTooLarge
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	var msg pb.SyncMessage
+	if err := proto.Unmarshal(buf, &msg); err != nil {
+		return nil, fmt.Errorf("syncclient: decode frame: %w", err)
+	}
+	return json.Marshal(&msg)
+}
+
+// encodeMessage encodes v for the wire in the given framing.
+func encodeMessage(framing string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if framing != FramingProtobuf {
+		return append(data, '\n'), nil
+	}
+	var msg pb.SyncMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	body, err := proto.Marshal(&msg)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	return append(frame, body...), nil
+}
@@ -0,0 +1,113 @@
+package syncclient
+
+// Message types delivered on Client.Updates.
+const (
+	TypeProgress           = "progress"
+	TypeLibraryAdd         = "library_add"
+	TypeLibraryRemove      = "library_remove"
+	TypeStatusChange       = "status_change"
+	TypeSubscriptionChange = "subscription_change"
+	TypeSnapshot           = "full_snapshot"
+	TypeProgressConflict   = "progress_conflict"
+	TypeError              = "error"
+	// TypeServerShutdown announces that the server is going away; the
+	// Client reconnects on its own.
+	TypeServerShutdown = "server_shutdown"
+)
+
+// Update is one message from the server. Which fields are set depends on
+// Type; see the TCP Sync Protocol section of the README.
+type Update struct {
+	Type       string `json:"type"`
+	Seq        uint64 `json:"seq,omitempty"`
+	UserID     string `json:"user_id,omitempty"`
+	MangaID    string `json:"manga_id,omitempty"`
+	Chapter    int    `json:"chapter,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Subscribed bool   `json:"subscribed,omitempty"`
+	Timestamp  int64  `json:"timestamp,omitempty"`
+
+	// Stored progress version and the client time (unix ms) that produced
+	// it, on progress and progress_conflict.
+	Version         int64 `json:"version,omitempty"`
+	ClientTimestamp int64 `json:"client_timestamp,omitempty"`
+
+	Rejected bool   `json:"rejected,omitempty"` // progress_conflict
+	Error    string `json:"error,omitempty"`    // error
+
+	// Snapshot is set for full_snapshot.
+	Snapshot *Snapshot `json:"-"`
+}
+
+// Snapshot is the user's whole library, sent in answer to RequestSnapshot.
+type Snapshot struct {
+	RequestID     string         `json:"request_id,omitempty"`
+	LastSeq       uint64         `json:"last_seq"`
+	Library       []LibraryEntry `json:"library"`
+	Subscriptions []string       `json:"subscriptions"`
+	Timestamp     int64          `json:"timestamp"`
+}
+
+// LibraryEntry is one manga in a Snapshot.
+type LibraryEntry struct {
+	MangaID   string `json:"manga_id"`
+	Chapter   int    `json:"chapter"`
+	Status    string `json:"status"`
+	Version   int64  `json:"version"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Progress is a progress update sent with SendProgress.
+type Progress struct {
+	MangaID string
+	Chapter int
+	Status  string // optional
+	// ClientTimestamp is when the change was made, in unix milliseconds;
+	// 0 means now.
+	ClientTimestamp int64
+	// BaseVersion is the progress version this device last saw, 0 if
+	// unknown.
+	BaseVersion int64
+}
+
+type authMessage struct {
+	Type    string  `json:"type"` // "auth"
+	UserID  string  `json:"user_id,omitempty"`
+	Token   string  `json:"token"`
+	LastSeq *uint64 `json:"last_seq,omitempty"`
+	Version int     `json:"version"`
+	Framing string  `json:"framing,omitempty"`
+}
+
+type authResponse struct {
+	Type      string `json:"type"` // "auth_response"
+	Status    string `json:"status"`
+	UserID    string `json:"user_id"`
+	Version   int    `json:"version"`
+	Framing   string `json:"framing"`
+	LastSeq   uint64 `json:"last_seq"`
+	Replayed  int    `json:"replayed"`
+	Truncated bool   `json:"truncated"`
+	Error     string `json:"error"`
+}
+
+type progressMessage struct {
+	Type            string `json:"type"` // "progress"
+	UserID          string `json:"user_id"`
+	MangaID         string `json:"manga_id"`
+	Chapter         int    `json:"chapter"`
+	Status          string `json:"status,omitempty"`
+	Timestamp       int64  `json:"timestamp"`
+	ClientTimestamp int64  `json:"client_timestamp"`
+	BaseVersion     int64  `json:"base_version,omitempty"`
+}
+
+type snapshotRequest struct {
+	Type      string `json:"type"` // "full_snapshot_request"
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type heartbeat struct {
+	Type      string `json:"type"` // "ping" | "pong"
+	Timestamp int64  `json:"timestamp"`
+}
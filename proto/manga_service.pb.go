@@ -124,14 +124,16 @@ func (x *Manga) GetCoverUrl() string {
 
 // UserProgress represents user reading progress
 type UserProgress struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	UserId         string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	MangaId        string                 `protobuf:"bytes,2,opt,name=manga_id,json=mangaId,proto3" json:"manga_id,omitempty"`
-	CurrentChapter int32                  `protobuf:"varint,3,opt,name=current_chapter,json=currentChapter,proto3" json:"current_chapter,omitempty"`
-	Status         string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
-	UpdatedAt      int64                  `protobuf:"varint,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	MangaId         string                 `protobuf:"bytes,2,opt,name=manga_id,json=mangaId,proto3" json:"manga_id,omitempty"`
+	CurrentChapter  int32                  `protobuf:"varint,3,opt,name=current_chapter,json=currentChapter,proto3" json:"current_chapter,omitempty"`
+	Status          string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	UpdatedAt       int64                  `protobuf:"varint,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Version         int64                  `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`                                        // incremented on every stored change
+	ClientTimestamp int64                  `protobuf:"varint,7,opt,name=client_timestamp,json=clientTimestamp,proto3" json:"client_timestamp,omitempty"` // unix ms of the update that produced it
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *UserProgress) Reset() {
@@ -199,6 +201,20 @@ func (x *UserProgress) GetUpdatedAt() int64 {
 	return 0
 }
 
+func (x *UserProgress) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *UserProgress) GetClientTimestamp() int64 {
+	if x != nil {
+		return x.ClientTimestamp
+	}
+	return 0
+}
+
 // GetMangaRequest for UC-014: Retrieve Manga via gRPC
 type GetMangaRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -248,6 +264,7 @@ func (x *GetMangaRequest) GetMangaId() string {
 type GetMangaResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Manga         *Manga                 `protobuf:"bytes,1,opt,name=manga,proto3" json:"manga,omitempty"`
+	Degraded      bool                   `protobuf:"varint,2,opt,name=degraded,proto3" json:"degraded,omitempty"` // MangaDex unavailable; served from local data
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -289,6 +306,13 @@ func (x *GetMangaResponse) GetManga() *Manga {
 	return nil
 }
 
+func (x *GetMangaResponse) GetDegraded() bool {
+	if x != nil {
+		return x.Degraded
+	}
+	return false
+}
+
 // SearchMangaRequest for UC-015: Search Manga via gRPC
 type SearchMangaRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -374,6 +398,7 @@ type SearchMangaResponse struct {
 	Page          int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
 	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
 	TotalPages    int32                  `protobuf:"varint,5,opt,name=total_pages,json=totalPages,proto3" json:"total_pages,omitempty"`
+	Degraded      bool                   `protobuf:"varint,6,opt,name=degraded,proto3" json:"degraded,omitempty"` // MangaDex unavailable; results come from local data
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -443,15 +468,24 @@ func (x *SearchMangaResponse) GetTotalPages() int32 {
 	return 0
 }
 
+func (x *SearchMangaResponse) GetDegraded() bool {
+	if x != nil {
+		return x.Degraded
+	}
+	return false
+}
+
 // UpdateProgressRequest for UC-016: Update Progress via gRPC
 type UpdateProgressRequest struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	UserId         string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	MangaId        string                 `protobuf:"bytes,2,opt,name=manga_id,json=mangaId,proto3" json:"manga_id,omitempty"`
-	CurrentChapter int32                  `protobuf:"varint,3,opt,name=current_chapter,json=currentChapter,proto3" json:"current_chapter,omitempty"`
-	Status         string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"` // Optional: "reading", "completed", "on_hold", etc.
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	MangaId         string                 `protobuf:"bytes,2,opt,name=manga_id,json=mangaId,proto3" json:"manga_id,omitempty"`
+	CurrentChapter  int32                  `protobuf:"varint,3,opt,name=current_chapter,json=currentChapter,proto3" json:"current_chapter,omitempty"`
+	Status          string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`                                           // Optional: "reading", "completed", "on_hold", etc.
+	ClientTimestamp int64                  `protobuf:"varint,5,opt,name=client_timestamp,json=clientTimestamp,proto3" json:"client_timestamp,omitempty"` // Optional: when the device made the change, unix ms
+	BaseVersion     int64                  `protobuf:"varint,6,opt,name=base_version,json=baseVersion,proto3" json:"base_version,omitempty"`             // Optional: progress version the device last saw
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *UpdateProgressRequest) Reset() {
@@ -512,12 +546,29 @@ func (x *UpdateProgressRequest) GetStatus() string {
 	return ""
 }
 
+func (x *UpdateProgressRequest) GetClientTimestamp() int64 {
+	if x != nil {
+		return x.ClientTimestamp
+	}
+	return 0
+}
+
+func (x *UpdateProgressRequest) GetBaseVersion() int64 {
+	if x != nil {
+		return x.BaseVersion
+	}
+	return 0
+}
+
 // UpdateProgressResponse for UC-016
 type UpdateProgressResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	BroadcastSent bool                   `protobuf:"varint,3,opt,name=broadcast_sent,json=broadcastSent,proto3" json:"broadcast_sent,omitempty"` // Whether TCP broadcast was sent
+	Applied       bool                   `protobuf:"varint,4,opt,name=applied,proto3" json:"applied,omitempty"`                                  // False if a newer update was already stored
+	Conflict      bool                   `protobuf:"varint,5,opt,name=conflict,proto3" json:"conflict,omitempty"`                                // Rejected as stale (reject_stale policy)
+	Progress      *UserProgress          `protobuf:"bytes,6,opt,name=progress,proto3" json:"progress,omitempty"`                                 // Authoritative progress after the update
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -573,6 +624,279 @@ func (x *UpdateProgressResponse) GetBroadcastSent() bool {
 	return false
 }
 
+func (x *UpdateProgressResponse) GetApplied() bool {
+	if x != nil {
+		return x.Applied
+	}
+	return false
+}
+
+func (x *UpdateProgressResponse) GetConflict() bool {
+	if x != nil {
+		return x.Conflict
+	}
+	return false
+}
+
+func (x *UpdateProgressResponse) GetProgress() *UserProgress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+// SyncMessage is one TCP sync protocol message in the length-prefixed
+// protobuf framing. Field names match the keys of the JSON framing; which
+// fields are set depends on type.
+type SyncMessage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Type            string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Seq             uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	UserId          string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	MangaId         string                 `protobuf:"bytes,4,opt,name=manga_id,json=mangaId,proto3" json:"manga_id,omitempty"`
+	Chapter         int32                  `protobuf:"varint,5,opt,name=chapter,proto3" json:"chapter,omitempty"`
+	Status          string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	Timestamp       int64                  `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Version         int64                  `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"` // stored progress version
+	BaseVersion     int64                  `protobuf:"varint,9,opt,name=base_version,json=baseVersion,proto3" json:"base_version,omitempty"`
+	ClientTimestamp int64                  `protobuf:"varint,10,opt,name=client_timestamp,json=clientTimestamp,proto3" json:"client_timestamp,omitempty"`
+	Subscribed      bool                   `protobuf:"varint,11,opt,name=subscribed,proto3" json:"subscribed,omitempty"`               // subscription_change
+	Rejected        bool                   `protobuf:"varint,12,opt,name=rejected,proto3" json:"rejected,omitempty"`                   // progress_conflict
+	Error           string                 `protobuf:"bytes,13,opt,name=error,proto3" json:"error,omitempty"`                          // error
+	RequestId       string                 `protobuf:"bytes,14,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // full_snapshot_request, full_snapshot
+	LastSeq         uint64                 `protobuf:"varint,15,opt,name=last_seq,json=lastSeq,proto3" json:"last_seq,omitempty"`      // full_snapshot
+	Library         []*SyncLibraryEntry    `protobuf:"bytes,16,rep,name=library,proto3" json:"library,omitempty"`
+	Subscriptions   []string               `protobuf:"bytes,17,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SyncMessage) Reset() {
+	*x = SyncMessage{}
+	mi := &file_proto_manga_service_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncMessage) ProtoMessage() {}
+
+func (x *SyncMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_manga_service_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncMessage.ProtoReflect.Descriptor instead.
+func (*SyncMessage) Descriptor() ([]byte, []int) {
+	return file_proto_manga_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SyncMessage) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *SyncMessage) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SyncMessage) GetMangaId() string {
+	if x != nil {
+		return x.MangaId
+	}
+	return ""
+}
+
+func (x *SyncMessage) GetChapter() int32 {
+	if x != nil {
+		return x.Chapter
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SyncMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetBaseVersion() int64 {
+	if x != nil {
+		return x.BaseVersion
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetClientTimestamp() int64 {
+	if x != nil {
+		return x.ClientTimestamp
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetSubscribed() bool {
+	if x != nil {
+		return x.Subscribed
+	}
+	return false
+}
+
+func (x *SyncMessage) GetRejected() bool {
+	if x != nil {
+		return x.Rejected
+	}
+	return false
+}
+
+func (x *SyncMessage) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SyncMessage) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SyncMessage) GetLastSeq() uint64 {
+	if x != nil {
+		return x.LastSeq
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetLibrary() []*SyncLibraryEntry {
+	if x != nil {
+		return x.Library
+	}
+	return nil
+}
+
+func (x *SyncMessage) GetSubscriptions() []string {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+// SyncLibraryEntry is one manga in a full_snapshot SyncMessage.
+type SyncLibraryEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MangaId       string                 `protobuf:"bytes,1,opt,name=manga_id,json=mangaId,proto3" json:"manga_id,omitempty"`
+	Chapter       int32                  `protobuf:"varint,2,opt,name=chapter,proto3" json:"chapter,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Version       int64                  `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	UpdatedAt     int64                  `protobuf:"varint,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncLibraryEntry) Reset() {
+	*x = SyncLibraryEntry{}
+	mi := &file_proto_manga_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncLibraryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncLibraryEntry) ProtoMessage() {}
+
+func (x *SyncLibraryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_manga_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncLibraryEntry.ProtoReflect.Descriptor instead.
+func (*SyncLibraryEntry) Descriptor() ([]byte, []int) {
+	return file_proto_manga_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SyncLibraryEntry) GetMangaId() string {
+	if x != nil {
+		return x.MangaId
+	}
+	return ""
+}
+
+func (x *SyncLibraryEntry) GetChapter() int32 {
+	if x != nil {
+		return x.Chapter
+	}
+	return 0
+}
+
+func (x *SyncLibraryEntry) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SyncLibraryEntry) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *SyncLibraryEntry) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
 var File_proto_manga_service_proto protoreflect.FileDescriptor
 
 const file_proto_manga_service_proto_rawDesc = "" +
@@ -586,40 +910,78 @@ const file_proto_manga_service_proto_rawDesc = "" +
 	"\x06status\x18\x05 \x01(\tR\x06status\x12%\n" +
 	"\x0etotal_chapters\x18\x06 \x01(\x05R\rtotalChapters\x12 \n" +
 	"\vdescription\x18\a \x01(\tR\vdescription\x12\x1b\n" +
-	"\tcover_url\x18\b \x01(\tR\bcoverUrl\"\xa2\x01\n" +
+	"\tcover_url\x18\b \x01(\tR\bcoverUrl\"\xe7\x01\n" +
 	"\fUserProgress\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x19\n" +
 	"\bmanga_id\x18\x02 \x01(\tR\amangaId\x12'\n" +
 	"\x0fcurrent_chapter\x18\x03 \x01(\x05R\x0ecurrentChapter\x12\x16\n" +
 	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1d\n" +
 	"\n" +
-	"updated_at\x18\x05 \x01(\x03R\tupdatedAt\",\n" +
+	"updated_at\x18\x05 \x01(\x03R\tupdatedAt\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\x03R\aversion\x12)\n" +
+	"\x10client_timestamp\x18\a \x01(\x03R\x0fclientTimestamp\",\n" +
 	"\x0fGetMangaRequest\x12\x19\n" +
-	"\bmanga_id\x18\x01 \x01(\tR\amangaId\"9\n" +
+	"\bmanga_id\x18\x01 \x01(\tR\amangaId\"U\n" +
 	"\x10GetMangaResponse\x12%\n" +
-	"\x05manga\x18\x01 \x01(\v2\x0f.mangahub.MangaR\x05manga\"\x82\x01\n" +
+	"\x05manga\x18\x01 \x01(\v2\x0f.mangahub.MangaR\x05manga\x12\x1a\n" +
+	"\bdegraded\x18\x02 \x01(\bR\bdegraded\"\x82\x01\n" +
 	"\x12SearchMangaRequest\x12\x14\n" +
 	"\x05query\x18\x01 \x01(\tR\x05query\x12\x14\n" +
 	"\x05genre\x18\x02 \x01(\tR\x05genre\x12\x16\n" +
 	"\x06status\x18\x03 \x01(\tR\x06status\x12\x12\n" +
 	"\x04page\x18\x04 \x01(\x05R\x04page\x12\x14\n" +
-	"\x05limit\x18\x05 \x01(\x05R\x05limit\"\x9b\x01\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"\xb7\x01\n" +
 	"\x13SearchMangaResponse\x12#\n" +
 	"\x04data\x18\x01 \x03(\v2\x0f.mangahub.MangaR\x04data\x12\x14\n" +
 	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x12\n" +
 	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x14\n" +
 	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x1f\n" +
 	"\vtotal_pages\x18\x05 \x01(\x05R\n" +
-	"totalPages\"\x8c\x01\n" +
+	"totalPages\x12\x1a\n" +
+	"\bdegraded\x18\x06 \x01(\bR\bdegraded\"\xda\x01\n" +
 	"\x15UpdateProgressRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x19\n" +
 	"\bmanga_id\x18\x02 \x01(\tR\amangaId\x12'\n" +
 	"\x0fcurrent_chapter\x18\x03 \x01(\x05R\x0ecurrentChapter\x12\x16\n" +
-	"\x06status\x18\x04 \x01(\tR\x06status\"s\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12)\n" +
+	"\x10client_timestamp\x18\x05 \x01(\x03R\x0fclientTimestamp\x12!\n" +
+	"\fbase_version\x18\x06 \x01(\x03R\vbaseVersion\"\xdd\x01\n" +
 	"\x16UpdateProgressResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
-	"\x0ebroadcast_sent\x18\x03 \x01(\bR\rbroadcastSent2\xf2\x01\n" +
+	"\x0ebroadcast_sent\x18\x03 \x01(\bR\rbroadcastSent\x12\x18\n" +
+	"\aapplied\x18\x04 \x01(\bR\aapplied\x12\x1a\n" +
+	"\bconflict\x18\x05 \x01(\bR\bconflict\x122\n" +
+	"\bprogress\x18\x06 \x01(\v2\x16.mangahub.UserProgressR\bprogress\"\x87\x04\n" +
+	"\vSyncMessage\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x19\n" +
+	"\bmanga_id\x18\x04 \x01(\tR\amangaId\x12\x18\n" +
+	"\achapter\x18\x05 \x01(\x05R\achapter\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\x12\x1c\n" +
+	"\ttimestamp\x18\a \x01(\x03R\ttimestamp\x12\x18\n" +
+	"\aversion\x18\b \x01(\x03R\aversion\x12!\n" +
+	"\fbase_version\x18\t \x01(\x03R\vbaseVersion\x12)\n" +
+	"\x10client_timestamp\x18\n" +
+	" \x01(\x03R\x0fclientTimestamp\x12\x1e\n" +
+	"\n" +
+	"subscribed\x18\v \x01(\bR\n" +
+	"subscribed\x12\x1a\n" +
+	"\brejected\x18\f \x01(\bR\brejected\x12\x14\n" +
+	"\x05error\x18\r \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x0e \x01(\tR\trequestId\x12\x19\n" +
+	"\blast_seq\x18\x0f \x01(\x04R\alastSeq\x124\n" +
+	"\alibrary\x18\x10 \x03(\v2\x1a.mangahub.SyncLibraryEntryR\alibrary\x12$\n" +
+	"\rsubscriptions\x18\x11 \x03(\tR\rsubscriptions\"\x98\x01\n" +
+	"\x10SyncLibraryEntry\x12\x19\n" +
+	"\bmanga_id\x18\x01 \x01(\tR\amangaId\x12\x18\n" +
+	"\achapter\x18\x02 \x01(\x05R\achapter\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\x03R\aversion\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\x03R\tupdatedAt2\xf2\x01\n" +
 	"\fMangaService\x12A\n" +
 	"\bGetManga\x12\x19.mangahub.GetMangaRequest\x1a\x1a.mangahub.GetMangaResponse\x12J\n" +
 	"\vSearchManga\x12\x1c.mangahub.SearchMangaRequest\x1a\x1d.mangahub.SearchMangaResponse\x12S\n" +
@@ -637,7 +999,7 @@ func file_proto_manga_service_proto_rawDescGZIP() []byte {
 	return file_proto_manga_service_proto_rawDescData
 }
 
-var file_proto_manga_service_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_manga_service_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_proto_manga_service_proto_goTypes = []any{
 	(*Manga)(nil),                  // 0: mangahub.Manga
 	(*UserProgress)(nil),           // 1: mangahub.UserProgress
@@ -647,21 +1009,25 @@ var file_proto_manga_service_proto_goTypes = []any{
 	(*SearchMangaResponse)(nil),    // 5: mangahub.SearchMangaResponse
 	(*UpdateProgressRequest)(nil),  // 6: mangahub.UpdateProgressRequest
 	(*UpdateProgressResponse)(nil), // 7: mangahub.UpdateProgressResponse
+	(*SyncMessage)(nil),            // 8: mangahub.SyncMessage
+	(*SyncLibraryEntry)(nil),       // 9: mangahub.SyncLibraryEntry
 }
 var file_proto_manga_service_proto_depIdxs = []int32{
 	0, // 0: mangahub.GetMangaResponse.manga:type_name -> mangahub.Manga
 	0, // 1: mangahub.SearchMangaResponse.data:type_name -> mangahub.Manga
-	2, // 2: mangahub.MangaService.GetManga:input_type -> mangahub.GetMangaRequest
-	4, // 3: mangahub.MangaService.SearchManga:input_type -> mangahub.SearchMangaRequest
-	6, // 4: mangahub.MangaService.UpdateProgress:input_type -> mangahub.UpdateProgressRequest
-	3, // 5: mangahub.MangaService.GetManga:output_type -> mangahub.GetMangaResponse
-	5, // 6: mangahub.MangaService.SearchManga:output_type -> mangahub.SearchMangaResponse
-	7, // 7: mangahub.MangaService.UpdateProgress:output_type -> mangahub.UpdateProgressResponse
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	1, // 2: mangahub.UpdateProgressResponse.progress:type_name -> mangahub.UserProgress
+	9, // 3: mangahub.SyncMessage.library:type_name -> mangahub.SyncLibraryEntry
+	2, // 4: mangahub.MangaService.GetManga:input_type -> mangahub.GetMangaRequest
+	4, // 5: mangahub.MangaService.SearchManga:input_type -> mangahub.SearchMangaRequest
+	6, // 6: mangahub.MangaService.UpdateProgress:input_type -> mangahub.UpdateProgressRequest
+	3, // 7: mangahub.MangaService.GetManga:output_type -> mangahub.GetMangaResponse
+	5, // 8: mangahub.MangaService.SearchManga:output_type -> mangahub.SearchMangaResponse
+	7, // 9: mangahub.MangaService.UpdateProgress:output_type -> mangahub.UpdateProgressResponse
+	7, // [7:10] is the sub-list for method output_type
+	4, // [4:7] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_proto_manga_service_proto_init() }
@@ -675,7 +1041,7 @@ func file_proto_manga_service_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_manga_service_proto_rawDesc), len(file_proto_manga_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   1,
 		},